@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"dooqiniu/internal/config"
+	"dooqiniu/internal/service"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// 命令的退出码，便于脚本区分失败原因
+const (
+	exitFailure  = 1 // 其他错误，例如网络或存储后端错误
+	exitUsage    = 2 // 参数错误或配置无效
+	exitNotFound = 3 // 对象或本地文件不存在，或通配符没有匹配
+	exitExists   = 4 // 目标对象已存在且未指定 --force
+)
+
+// exitError 指定退出码的错误
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// exitWith 为 err 指定退出码
+func exitWith(code int, err error) error {
+	return &exitError{code: code, err: err}
+}
+
+// exitCode 返回错误对应的退出码，未指定退出码时按存储后端的错误类型判断
+func exitCode(err error) int {
+	var e *exitError
+	switch {
+	case errors.As(err, &e):
+		return e.code
+	case errors.Is(err, service.ErrObjectNotFound), errors.Is(err, os.ErrNotExist):
+		return exitNotFound
+	case errors.Is(err, service.ErrObjectExists):
+		return exitExists
+	case errors.Is(err, service.ErrUnknownBucket), errors.Is(err, service.ErrUnknownProfile):
+		return exitUsage
+	default:
+		return exitFailure
+	}
+}
+
+// usageArgs 将位置参数校验失败转换为用法错误
+func usageArgs(validate cobra.PositionalArgs) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := validate(cmd, args); err != nil {
+			return exitWith(exitUsage, err)
+		}
+		return nil
+	}
+}
+
+// addStorageFlags 添加选择账号和存储空间的参数
+func addStorageFlags(cmd *cobra.Command) {
+	cmd.Flags().String("bucket", "", "bucket profile name from QINIU_BUCKETS or the selected profile, defaults to its default bucket")
+	cmd.Flags().String("profile", "", "account profile name from QINIU_PROFILES, defaults to QINIU_ACCESSKEY")
+}
+
+// commandStorage 按 --profile 和 --bucket 创建存储后端
+func commandStorage(cmd *cobra.Command) (service.Storage, error) {
+	bucket, _ := cmd.Flags().GetString("bucket")
+	profile, _ := cmd.Flags().GetString("profile")
+	return service.NewProfileStorage(config.Current(), profile, bucket)
+}
+
+// addOutputFlags 添加 --json 参数，默认输出便于阅读的表格
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("json", false, "print one JSON object per line instead of a table")
+}
+
+// output 按 --json 输出表格或 NDJSON，表格的列宽在 Flush 时统一计算
+type output struct {
+	json  bool
+	enc   *json.Encoder
+	table *tabwriter.Writer
+	rows  int
+}
+
+func newOutput(cmd *cobra.Command, w io.Writer) *output {
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+	if jsonOutput {
+		return &output{json: true, enc: json.NewEncoder(w)}
+	}
+	return &output{table: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)}
+}
+
+// header 输出表头，只在表格模式的第一行之前输出一次
+func (o *output) header(columns ...string) {
+	if o.json || o.rows > 0 {
+		return
+	}
+	fmt.Fprintln(o.table, strings.Join(columns, "\t"))
+	o.rows++
+}
+
+// row 输出一行，v 为 JSON 模式下输出的对象，columns 为表格模式下的各列
+func (o *output) row(v any, columns ...string) {
+	if o.json {
+		o.enc.Encode(v)
+		return
+	}
+	fmt.Fprintln(o.table, strings.Join(columns, "\t"))
+	o.rows++
+}
+
+func (o *output) flush() {
+	if o.table != nil {
+		o.table.Flush()
+	}
+}
+
+// failures 记录多个对象的操作中失败的对象，单个对象失败时继续处理其余对象
+type failures struct {
+	count int
+	total int
+	code  int
+}
+
+// add 在标准错误输出中打印失败的对象
+func (f *failures) add(name string, err error) {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+	code := exitCode(err)
+	if f.count > 0 && f.code != code {
+		code = exitFailure
+	}
+	f.code = code
+	f.count++
+}
+
+// err 所有对象都成功时返回 nil，所有失败的原因相同时使用对应的退出码
+func (f *failures) err(action string) error {
+	if f.count == 0 {
+		return nil
+	}
+	return exitWith(f.code, fmt.Errorf("%s failed for %d of %d objects", action, f.count, f.total))
+}
+
+// hasGlob 判断参数是否包含通配符
+func hasGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// globBase 返回通配符之前到最后一个 / 为止的部分，展开后的对象以相对该部分的路径作为目标文件名或目标 key
+func globBase(pattern string) string {
+	literal := pattern
+	if i := strings.IndexAny(pattern, "*?["); i >= 0 {
+		literal = pattern[:i]
+	}
+	if i := strings.LastIndex(literal, "/"); i >= 0 {
+		return literal[:i+1]
+	}
+	return ""
+}
+
+// expandRemote 展开对象 key 中的通配符，规则与 path.Match 相同，* 不匹配 /。
+// 按通配符之前的部分列举对象后逐个匹配，没有通配符时原样返回
+func expandRemote(s service.Storage, pattern string) ([]string, error) {
+	if !hasGlob(pattern) {
+		return []string{pattern}, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, exitWith(exitUsage, fmt.Errorf("invalid pattern %q: %w", pattern, err))
+	}
+
+	prefix := pattern[:strings.IndexAny(pattern, "*?[")]
+	var keys []string
+	it := service.NewListingIterator(s, prefix)
+	for {
+		files, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+		for _, file := range files {
+			if ok, _ := path.Match(pattern, file.Key); ok {
+				keys = append(keys, file.Key)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, exitWith(exitNotFound, fmt.Errorf("no objects match %q", pattern))
+	}
+	return keys, nil
+}
+
+// formatSize 将字节数转换为便于阅读的大小，例如 1.5 MiB
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// formatTime 以本地时间输出，零值输出 -
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"dooqiniu/internal/service"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// exportCmd 导出完整的文件清单，供审计使用
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the full object inventory as NDJSON or CSV",
+	Args:  usageArgs(cobra.NoArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		prefix, _ := cmd.Flags().GetString("prefix")
+		format, _ := cmd.Flags().GetString("format")
+		compress, _ := cmd.Flags().GetBool("gzip")
+		output, _ := cmd.Flags().GetString("output")
+
+		storage, err := commandStorage(cmd)
+		if err != nil {
+			return err
+		}
+
+		// 默认输出到标准输出，便于通过管道处理
+		var w io.Writer = os.Stdout
+		if output != "" && output != "-" {
+			file, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("failed to create output file: %w", err)
+			}
+			defer file.Close()
+			w = file
+		}
+
+		writer, err := service.NewListingWriter(w, format, compress)
+		if err != nil {
+			return err
+		}
+		count, err := service.ExportListing(storage, prefix, writer, nil)
+		if err != nil {
+			return fmt.Errorf("export failed after %d objects: %w", count, err)
+		}
+
+		fmt.Fprintf(os.Stderr, "Exported %d objects\n", count)
+		return nil
+	},
+}
+
+func init() {
+	exportCmd.Flags().String("prefix", "", "only export objects with this key prefix")
+	exportCmd.Flags().String("format", service.ExportFormatNDJSON, "output format: ndjson or csv")
+	exportCmd.Flags().Bool("gzip", false, "gzip-compress the output")
+	exportCmd.Flags().StringP("output", "o", "", "output file, defaults to stdout")
+	addStorageFlags(exportCmd)
+	rootCmd.AddCommand(exportCmd)
+}
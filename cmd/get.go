@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"dooqiniu/internal/service"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// getCmd 下载对象到本地文件或标准输出
+var getCmd = &cobra.Command{
+	Use:   "get <key|pattern>...",
+	Short: "Download objects to local files or stdout",
+	Long: `Download objects from the bucket.
+
+A single key is saved under its file name in the current directory, or to the
+file given by --output. With several keys or a glob pattern --output is a
+directory; objects matched by a pattern keep their path relative to the part
+before the first wildcard. Use --output - to write a single object to stdout.
+Existing local files are overwritten.`,
+	Example: `  dooqiniu get docs/report.pdf
+  dooqiniu get 'logs/2024-*/*.gz' -o ./logs
+  dooqiniu get config/app.json -o - | jq .`,
+	Args: usageArgs(cobra.MinimumNArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dest, _ := cmd.Flags().GetString("output")
+
+		storage, err := commandStorage(cmd)
+		if err != nil {
+			return err
+		}
+
+		var downloads []objectFile
+		globbed := false
+		for _, arg := range args {
+			globbed = globbed || hasGlob(arg)
+			keys, err := expandRemote(storage, arg)
+			if err != nil {
+				return err
+			}
+			for _, key := range keys {
+				file := path.Base(key)
+				if hasGlob(arg) {
+					file = strings.TrimPrefix(key, globBase(arg))
+				}
+				downloads = append(downloads, objectFile{file: filepath.FromSlash(file), key: key})
+			}
+		}
+
+		if dest == "-" {
+			if len(downloads) != 1 {
+				return exitWith(exitUsage, fmt.Errorf("--output - requires exactly one object, got %d", len(downloads)))
+			}
+			return downloadTo(storage, downloads[0].key, os.Stdout)
+		}
+
+		// 只有一个对象且 --output 不是目录时，--output 为保存的文件名
+		info, statErr := os.Stat(dest)
+		isDir := dest == "" || strings.HasSuffix(dest, string(filepath.Separator)) || strings.HasSuffix(dest, "/") ||
+			(statErr == nil && info.IsDir())
+		if len(downloads) > 1 || globbed {
+			if !isDir && statErr == nil {
+				return exitWith(exitUsage, fmt.Errorf("--output %q must be a directory when downloading several objects", dest))
+			}
+			isDir = true
+		}
+		for i := range downloads {
+			if !isDir {
+				downloads[i].file = dest
+				continue
+			}
+			// 对象 key 中的 .. 等路径不能写到目标目录之外
+			if !filepath.IsLocal(downloads[i].file) {
+				return fmt.Errorf("refusing to write %s outside the output directory", downloads[i].key)
+			}
+			downloads[i].file = filepath.Join(dest, downloads[i].file)
+		}
+
+		out := newOutput(cmd, os.Stdout)
+		defer out.flush()
+		failed := &failures{total: len(downloads)}
+		for _, d := range downloads {
+			size, err := service.DownloadFile(storage, d.key, d.file)
+			if err != nil {
+				failed.add(d.key, err)
+				continue
+			}
+			out.header("SIZE", "KEY", "FILE")
+			out.row(struct {
+				Key  string `json:"key"`
+				File string `json:"file"`
+				Size int64  `json:"size"`
+			}{d.key, d.file, size}, formatSize(size), d.key, d.file)
+		}
+		return failed.err("download")
+	},
+}
+
+// downloadTo 将对象内容写入 w
+func downloadTo(s service.Storage, key string, w io.Writer) error {
+	reader, err := s.Open(key, 0, -1)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if _, err := io.Copy(w, reader); err != nil {
+		return fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	return nil
+}
+
+func init() {
+	getCmd.Flags().StringP("output", "o", "", "output file or directory, - for stdout, defaults to the current directory")
+	addStorageFlags(getCmd)
+	addOutputFlags(getCmd)
+	rootCmd.AddCommand(getCmd)
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"dooqiniu/internal/model"
+	"dooqiniu/internal/service"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// lsCmd 列举对象，与 list 接口相同，默认按 / 归并目录
+var lsCmd = &cobra.Command{
+	Use:   "ls [prefix|pattern]",
+	Short: "List objects under a prefix or matching a glob pattern",
+	Example: `  dooqiniu ls docs/
+  dooqiniu ls -r --json logs/2024-
+  dooqiniu ls 'images/*.png'`,
+	Args: usageArgs(cobra.MaximumNArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recursive, _ := cmd.Flags().GetBool("recursive")
+		limit, _ := cmd.Flags().GetInt("limit")
+		if limit < 0 {
+			return exitWith(exitUsage, fmt.Errorf("--limit must not be negative"))
+		}
+
+		storage, err := commandStorage(cmd)
+		if err != nil {
+			return err
+		}
+
+		prefix := ""
+		if len(args) == 1 {
+			prefix = args[0]
+		}
+		out := newOutput(cmd, os.Stdout)
+		defer out.flush()
+
+		// 通配符展开后逐个输出，不按目录归并
+		if hasGlob(prefix) {
+			keys, err := expandRemote(storage, prefix)
+			if err != nil {
+				return err
+			}
+			for i, key := range keys {
+				if limit > 0 && i >= limit {
+					break
+				}
+				info, err := storage.Stat(key)
+				if err != nil {
+					return fmt.Errorf("failed to stat %s: %w", key, err)
+				}
+				printFileRow(out, *info)
+			}
+			return nil
+		}
+
+		delimiter := "/"
+		if recursive {
+			delimiter = ""
+		}
+		count := 0
+		marker := ""
+		for {
+			pageSize := 1000
+			if limit > 0 && limit-count < pageSize {
+				pageSize = limit - count
+			}
+			files, prefixes, nextMarker, err := storage.ListFiles(prefix, delimiter, marker, pageSize)
+			if err != nil {
+				return fmt.Errorf("failed to list files: %w", err)
+			}
+			for _, p := range prefixes {
+				out.header("SIZE", "LAST MODIFIED", "TYPE", "KEY")
+				out.row(struct {
+					Prefix string `json:"prefix"`
+				}{p}, "-", "-", "DIR", p)
+			}
+			for _, file := range files {
+				printFileRow(out, file)
+			}
+			count += len(files) + len(prefixes)
+			if nextMarker == "" || (limit > 0 && count >= limit) {
+				return nil
+			}
+			marker = nextMarker
+		}
+	},
+}
+
+// printFileRow 输出 ls 的一行，JSON 模式下输出 list 接口返回的文件信息
+func printFileRow(out *output, file model.FileInfo) {
+	out.header("SIZE", "LAST MODIFIED", "TYPE", "KEY")
+	out.row(file, formatSize(file.ContentLength), formatTime(file.LastModified), service.StorageTypeName(file.StorageType), file.Key)
+}
+
+func init() {
+	lsCmd.Flags().BoolP("recursive", "r", false, "list all objects under the prefix instead of grouping by /")
+	lsCmd.Flags().Int("limit", 0, "maximum number of entries to print, 0 for no limit")
+	addStorageFlags(lsCmd)
+	addOutputFlags(lsCmd)
+	rootCmd.AddCommand(lsCmd)
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"dooqiniu/internal/model"
+	"dooqiniu/internal/service"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// putCmd 上传本地文件，与上传接口相同
+var putCmd = &cobra.Command{
+	Use:   "put <file|pattern|->... [key|prefix/]",
+	Short: "Upload local files, glob patterns or stdin",
+	Long: `Upload local files to the bucket.
+
+With a single source the last argument is the object key; a key ending in /
+is a prefix and the file name is appended. With several sources or a glob
+pattern the last argument must be a prefix ending in /, or omitted to upload
+under the file names. Use - to read from stdin, which requires a key.`,
+	Example: `  dooqiniu put report.pdf docs/report-2024.pdf
+  dooqiniu put 'dist/*.js' static/js/
+  tar cz build | dooqiniu put - backups/build.tar.gz`,
+	Args: usageArgs(cobra.MinimumNArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		contentType, _ := cmd.Flags().GetString("content-type")
+		metaData, _ := cmd.Flags().GetStringToString("meta")
+
+		sources, dest := args, ""
+		if len(args) > 1 {
+			sources, dest = args[:len(args)-1], args[len(args)-1]
+		}
+		uploads, err := planUploads(sources, dest)
+		if err != nil {
+			return err
+		}
+
+		storage, err := commandStorage(cmd)
+		if err != nil {
+			return err
+		}
+
+		out := newOutput(cmd, os.Stdout)
+		defer out.flush()
+		failed := &failures{total: len(uploads)}
+		for _, u := range uploads {
+			resp, err := uploadFile(storage, u.file, u.key, contentType, metaData)
+			if err != nil {
+				failed.add(u.file, err)
+				continue
+			}
+			out.header("SIZE", "ETAG", "KEY")
+			out.row(struct {
+				Key string `json:"key"`
+				*model.UploadResponse
+			}{u.key, resp}, formatSize(resp.ContentLength), resp.ETag, u.key)
+		}
+		return failed.err("upload")
+	},
+}
+
+// objectFile 本地文件与对象 key 的对应关系
+type objectFile struct {
+	file string
+	key  string
+}
+
+// planUploads 展开本地通配符并确定每个文件的 key，在上传任何文件之前检查参数
+func planUploads(sources []string, dest string) ([]objectFile, error) {
+	var uploads []objectFile
+	multiple := len(sources) > 1
+	for _, source := range sources {
+		if source == "-" {
+			if len(sources) > 1 || dest == "" || strings.HasSuffix(dest, "/") {
+				return nil, exitWith(exitUsage, fmt.Errorf("uploading from stdin requires a single source and an object key"))
+			}
+			uploads = append(uploads, objectFile{file: "-", key: dest})
+			continue
+		}
+
+		files := []string{source}
+		base := "."
+		if hasGlob(source) {
+			matches, err := filepath.Glob(source)
+			if err != nil {
+				return nil, exitWith(exitUsage, fmt.Errorf("invalid pattern %q: %w", source, err))
+			}
+			if len(matches) == 0 {
+				return nil, exitWith(exitNotFound, fmt.Errorf("no files match %q", source))
+			}
+			files, multiple = matches, true
+			if dir := globBase(filepath.ToSlash(source)); dir != "" {
+				base = filepath.FromSlash(dir)
+			}
+		}
+
+		for _, file := range files {
+			info, err := os.Stat(file)
+			if err != nil {
+				return nil, err
+			}
+			if info.IsDir() {
+				// 通配符匹配到的目录跳过，明确指定的目录需要使用 sync 上传
+				if hasGlob(source) {
+					continue
+				}
+				return nil, exitWith(exitUsage, fmt.Errorf("%s is a directory, use sync to upload directories", file))
+			}
+			// 通配符展开后保留相对通配符之前部分的路径，例如 dist/*/*.js 上传为 <prefix>js/app.js
+			key := filepath.Base(file)
+			if hasGlob(source) {
+				if rel, err := filepath.Rel(base, file); err == nil {
+					key = rel
+				}
+			}
+			uploads = append(uploads, objectFile{file: file, key: filepath.ToSlash(key)})
+		}
+	}
+
+	if multiple && dest != "" && !strings.HasSuffix(dest, "/") {
+		return nil, exitWith(exitUsage, fmt.Errorf("destination %q must end with / when uploading several files", dest))
+	}
+	for i := range uploads {
+		switch {
+		case uploads[i].file == "-":
+		case dest == "" || strings.HasSuffix(dest, "/"):
+			uploads[i].key = dest + uploads[i].key
+		default:
+			uploads[i].key = dest
+		}
+	}
+	return uploads, nil
+}
+
+// uploadFile 上传一个本地文件，file 为 - 时读取标准输入，未指定 MIME 类型时按 key 的扩展名推断
+func uploadFile(s service.Storage, file, key, contentType string, metaData map[string]string) (*model.UploadResponse, error) {
+	var reader io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		reader = f
+	}
+	return s.Upload(reader, key, contentType, metaData)
+}
+
+func init() {
+	putCmd.Flags().String("content-type", "", "MIME type of the uploaded objects, detected from the key by default")
+	putCmd.Flags().StringToString("meta", nil, "custom metadata as key=value, may be repeated")
+	addStorageFlags(putCmd)
+	addOutputFlags(putCmd)
+	rootCmd.AddCommand(putCmd)
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"dooqiniu/internal/service"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// rmCmd 删除对象，--recursive 时按前缀删除
+var rmCmd = &cobra.Command{
+	Use:   "rm <key|pattern>...",
+	Short: "Delete objects, glob patterns or whole prefixes",
+	Example: `  dooqiniu rm docs/old.txt
+  dooqiniu rm 'tmp/*.part'
+  dooqiniu rm -r build/2023/`,
+	Args: usageArgs(cobra.MinimumNArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recursive, _ := cmd.Flags().GetBool("recursive")
+
+		storage, err := commandStorage(cmd)
+		if err != nil {
+			return err
+		}
+
+		out := newOutput(cmd, os.Stdout)
+		defer out.flush()
+
+		// 按前缀删除，与按前缀删除接口相同，不允许删除整个存储空间
+		if recursive {
+			for _, prefix := range args {
+				if prefix == "" || hasGlob(prefix) {
+					return exitWith(exitUsage, fmt.Errorf("--recursive requires non-empty prefixes without wildcards, got %q", prefix))
+				}
+			}
+
+			var deleted, failed int64
+			for _, prefix := range args {
+				result, err := service.DeletePrefix(cmd.Context(), storage, prefix, nil, nil)
+				deleted += result.Deleted
+				failed += result.Failed
+				for _, e := range result.Errors {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", e.Key, e.Error)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to delete %s after %d objects: %w", prefix, result.Deleted, err)
+				}
+				out.header("DELETED", "FAILED", "PREFIX")
+				out.row(struct {
+					Prefix string `json:"prefix"`
+					*service.PrefixDeleteProgress
+				}{prefix, result}, strconv.FormatInt(result.Deleted, 10), strconv.FormatInt(result.Failed, 10), prefix)
+			}
+			if failed > 0 {
+				return fmt.Errorf("delete failed for %d of %d objects", failed, deleted+failed)
+			}
+			return nil
+		}
+
+		var keys []string
+		for _, arg := range args {
+			expanded, err := expandRemote(storage, arg)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, expanded...)
+		}
+
+		failed := &failures{total: len(keys)}
+		for _, key := range keys {
+			if err := storage.Delete(key); err != nil {
+				failed.add(key, err)
+				continue
+			}
+			out.header("DELETED")
+			out.row(struct {
+				Key string `json:"key"`
+			}{key}, key)
+		}
+		return failed.err("delete")
+	},
+}
+
+func init() {
+	rmCmd.Flags().BoolP("recursive", "r", false, "treat arguments as prefixes and delete every object under them")
+	addStorageFlags(rmCmd)
+	addOutputFlags(rmCmd)
+	rootCmd.AddCommand(rmCmd)
+}
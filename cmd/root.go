@@ -1,52 +1,69 @@
 package cmd
 
 import (
-	_ "dooqiniu/docs"
 	"dooqiniu/internal/config"
-	"dooqiniu/router"
 	"fmt"
 	"os"
 
-	"github.com/gin-gonic/gin"
 	"github.com/spf13/cobra"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
 // rootCmd 代表基本命令
 var rootCmd = &cobra.Command{
 	Use:   "dooqiniu",
-	Short: "A brief description of your application",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		// 加载配置
-		cfg := config.LoadQiniuConfig()
+	Short: "Qiniu Kodo object storage API server and command-line client",
+	Long: `dooqiniu serves an HTTP API in front of Qiniu Kodo (or a local/memory backend)
+and provides commands that call the storage backend directly, without the server.
 
-		// 创建 Gin 引擎
-		r := gin.Default()
-
-		// 设置路由
-		router.SetupRoutes(r)
-
-		r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+Running dooqiniu without a command starts the server, same as "dooqiniu serve".`,
+	// 不带子命令时启动服务，兼容原来的启动方式
+	Args: usageArgs(cobra.NoArgs),
+	// 所有子命令执行前加载并校验配置，之后通过 config.Current 读取
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if !needsConfig(cmd) {
+			return nil
+		}
+		// 配置错误不是用法错误，不打印用法
+		cmd.SilenceUsage = true
 
-		// 启动服务器
-		fmt.Println("Starting server on :" + cfg.Port)
-		if err := r.Run(":" + cfg.Port); err != nil {
-			fmt.Println("Error starting server:", err)
-			return err
+		path, _ := cmd.Flags().GetString("config")
+		if path == "" {
+			path = os.Getenv(config.ConfigFileEnv)
+		}
+		if err := config.Init(path, cmd.Flags()); err != nil {
+			return exitWith(exitUsage, fmt.Errorf("invalid configuration:\n%w", err))
 		}
 		return nil
 	},
+	RunE: runServer,
 }
 
+// needsConfig 判断命令是否需要加载配置，生成补全脚本等不访问存储的命令不校验配置
+func needsConfig(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		switch c.Name() {
+		case "completion", "help", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+			return false
+		}
+	}
+	return true
+}
+
+// Execute 执行命令，按错误类型设置退出码，见 exitCode
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
 func init() {
-	// 添加全局标志
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	// 命令行参数错误属于用法错误，退出码为 2
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return exitWith(exitUsage, err)
+	})
+
+	// 配置项可以通过配置文件、环境变量或命令行参数设置，所有子命令共用
+	rootCmd.PersistentFlags().String("config", "", "YAML or TOML config file, overrides "+config.ConfigFileEnv)
+	config.RegisterFlags(rootCmd.PersistentFlags())
 }
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	_ "dooqiniu/docs"
+	"dooqiniu/internal/config"
+	"dooqiniu/internal/s3gateway"
+	"dooqiniu/internal/service"
+	"dooqiniu/router"
+	"fmt"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cobra"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+// serveCmd 启动 HTTP 服务，不带子命令运行 dooqiniu 时同样启动服务
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Start the HTTP API server",
+	Args:  usageArgs(cobra.NoArgs),
+	RunE:  runServer,
+}
+
+func runServer(cmd *cobra.Command, args []string) error {
+	cfg := config.Current()
+
+	// 配置了 INDEX_PATH 时启用本地元数据索引
+	if cfg.IndexPath != "" {
+		if _, err := service.EnableMetadataIndex(cfg); err != nil {
+			return err
+		}
+	}
+
+	// 配置了 STATS_SNAPSHOT_INTERVAL 时定期保存用量统计快照
+	if cfg.StatsSnapshotInterval > 0 {
+		service.StartStatsSnapshots(service.NewStatsStore(cfg.StatsDir), cfg.StatsSnapshotInterval, config.Current)
+	}
+
+	// 收到 SIGHUP 或配置文件修改后重新加载配置，旧配置上的请求完成后删除配置已变化的七牛云客户端，
+	// 并按新配置重新创建索引对账使用的存储后端
+	config.OnReload(service.PruneClientCache)
+	config.OnReload(service.ReloadMetadataIndexStorage)
+	config.Watch()
+
+	// 创建 Gin 引擎
+	r := gin.Default()
+
+	// 设置路由
+	router.SetupRoutes(r)
+
+	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	// 配置了 S3_GATEWAY_ADDR 时额外启动 S3 兼容网关
+	if cfg.S3GatewayAddr != "" {
+		s3 := gin.Default()
+		router.SetupS3Routes(s3, s3gateway.NewGateway(cfg))
+
+		go func() {
+			fmt.Println("Starting S3 gateway on " + cfg.S3GatewayAddr)
+			if err := s3.Run(cfg.S3GatewayAddr); err != nil {
+				fmt.Println("Error starting S3 gateway:", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	// 启动服务器
+	fmt.Println("Starting server on :" + cfg.Port)
+	if err := r.Run(":" + cfg.Port); err != nil {
+		fmt.Println("Error starting server:", err)
+		return err
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"dooqiniu/internal/service"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// statCmd 获取对象信息，与获取文件信息接口相同
+var statCmd = &cobra.Command{
+	Use:   "stat <key|pattern>...",
+	Short: "Show object size, etag, MIME type and metadata",
+	Args:  usageArgs(cobra.MinimumNArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		storage, err := commandStorage(cmd)
+		if err != nil {
+			return err
+		}
+
+		var keys []string
+		for _, arg := range args {
+			expanded, err := expandRemote(storage, arg)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, expanded...)
+		}
+
+		out := newOutput(cmd, os.Stdout)
+		defer out.flush()
+		failed := &failures{total: len(keys)}
+		for _, key := range keys {
+			info, err := storage.Stat(key)
+			if err != nil {
+				failed.add(key, err)
+				continue
+			}
+			out.header("SIZE", "LAST MODIFIED", "TYPE", "ETAG", "MIME TYPE", "METADATA", "KEY")
+			out.row(info, strconv.FormatInt(info.ContentLength, 10), formatTime(info.LastModified),
+				service.StorageTypeName(info.StorageType), info.ETag, info.MimeType, formatMeta(info.MetaData), info.Key)
+		}
+		return failed.err("stat")
+	},
+}
+
+// formatMeta 将自定义元数据按键名排序输出为 k=v,k=v，没有元数据时输出 -
+func formatMeta(metaData map[string]string) string {
+	if len(metaData) == 0 {
+		return "-"
+	}
+	keys := slices.Sorted(maps.Keys(metaData))
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+metaData[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func init() {
+	addStorageFlags(statCmd)
+	addOutputFlags(statCmd)
+	rootCmd.AddCommand(statCmd)
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"dooqiniu/internal/config"
+	"dooqiniu/internal/service"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// remotePrefix 标记参数为存储空间中的前缀，用于指定同步方向
+const remotePrefix = "kodo:"
+
+// syncCmd 在本地目录和存储空间的前缀之间同步文件
+var syncCmd = &cobra.Command{
+	Use:   "sync <local-dir> <prefix> | sync kodo:<prefix> <local-dir>",
+	Short: "Sync a local directory with a bucket prefix, transferring only changes",
+	Long: `Sync a local directory with a bucket prefix in either direction.
+
+The source is the first argument. Prefix arguments may be written as
+kodo:<prefix>; a prefix given as the first argument must use this form, which
+downloads from the bucket into the local directory. The prefix is treated as a
+directory, so "site" and "site/" are the same.
+
+Files are compared by size and Qiniu etag, computed locally, and only new or
+changed files are transferred. --delete removes files that exist only on the
+destination; files excluded by --include/--exclude are never deleted. Patterns
+without / match file names, patterns with / match paths relative to the
+directory or prefix.`,
+	Example: `  dooqiniu sync ./dist static/site --delete --exclude '*.map'
+  dooqiniu sync kodo:backups/build-42 ./restore
+  dooqiniu sync ./logs logs/ --include '*.gz' --dry-run`,
+	Args: usageArgs(cobra.ExactArgs(2)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		deleteExtra, _ := cmd.Flags().GetBool("delete")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		workers, _ := cmd.Flags().GetInt("workers")
+		include, _ := cmd.Flags().GetStringArray("include")
+		exclude, _ := cmd.Flags().GetStringArray("exclude")
+
+		direction, dir, prefix := service.SyncUpload, args[0], strings.TrimPrefix(args[1], remotePrefix)
+		if strings.HasPrefix(args[0], remotePrefix) {
+			if strings.HasPrefix(args[1], remotePrefix) {
+				return exitWith(exitUsage, fmt.Errorf("one of the arguments must be a local directory"))
+			}
+			direction, dir, prefix = service.SyncDownload, args[1], strings.TrimPrefix(args[0], remotePrefix)
+		}
+		for _, pattern := range append(append([]string{}, include...), exclude...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return exitWith(exitUsage, fmt.Errorf("invalid pattern %q: %w", pattern, err))
+			}
+		}
+		if workers < 1 {
+			return exitWith(exitUsage, fmt.Errorf("--workers must be at least 1"))
+		}
+		// 与按前缀删除相同，删除整个存储空间中多余的对象需要 ALLOW_ROOT_PREFIX_DELETE
+		if direction == service.SyncUpload && deleteExtra && strings.Trim(prefix, "/") == "" && !config.Current().AllowRootPrefixDelete {
+			return exitWith(exitUsage, fmt.Errorf("--delete with an empty prefix requires ALLOW_ROOT_PREFIX_DELETE=true"))
+		}
+		if direction == service.SyncUpload {
+			info, err := os.Stat(dir)
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				return exitWith(exitUsage, fmt.Errorf("%s is not a directory", dir))
+			}
+		}
+
+		storage, err := commandStorage(cmd)
+		if err != nil {
+			return err
+		}
+
+		out := newOutput(cmd, os.Stdout)
+		opts := service.SyncOptions{
+			Delete:  deleteExtra,
+			DryRun:  dryRun,
+			Workers: workers,
+			Include: include,
+			Exclude: exclude,
+		}
+		summary, err := service.Sync(storage, direction, dir, prefix, opts, func(action service.SyncAction) {
+			if action.Error != "" {
+				fmt.Fprintf(os.Stderr, "%s %s: %s\n", action.Action, action.Key, action.Error)
+				return
+			}
+			out.header("ACTION", "REASON", "SIZE", "KEY", "PATH")
+			out.row(action, action.Action, action.Reason, formatSize(action.Size), action.Key, action.Path)
+		})
+		out.flush()
+		if err != nil {
+			return err
+		}
+
+		printSyncSummary(cmd, summary, out.rows > 0)
+		if summary.Failed > 0 {
+			return fmt.Errorf("sync failed for %d files", summary.Failed)
+		}
+		return nil
+	},
+}
+
+// printSyncSummary 输出同步结果，separate 为 true 时与之前输出的操作之间空一行，JSON 模式下最后一行为 {"summary": {...}}
+func printSyncSummary(cmd *cobra.Command, summary *service.SyncSummary, separate bool) {
+	out := newOutput(cmd, os.Stdout)
+	defer out.flush()
+	if out.json {
+		out.row(struct {
+			Summary *service.SyncSummary `json:"summary"`
+		}{summary})
+		return
+	}
+
+	transferred := "Uploaded"
+	if summary.Direction == service.SyncDownload {
+		transferred = "Downloaded"
+	}
+	if separate {
+		fmt.Fprintln(out.table)
+	}
+	if summary.DryRun {
+		fmt.Fprintln(out.table, "Dry run, nothing was changed.")
+	}
+	rows := [][2]string{
+		{transferred, fmt.Sprintf("%d (%s)", summary.Transferred, formatSize(summary.Bytes))},
+		{"Deleted", strconv.Itoa(summary.Deleted)},
+		{"Unchanged", strconv.Itoa(summary.Unchanged)},
+		{"Excluded", strconv.Itoa(summary.Excluded)},
+		{"Failed", strconv.Itoa(summary.Failed)},
+		{"Duration", summary.Duration.Round(time.Millisecond).String()},
+	}
+	for _, row := range rows {
+		out.row(nil, row[0]+":", row[1])
+	}
+}
+
+func init() {
+	syncCmd.Flags().Bool("delete", false, "delete destination files that do not exist in the source")
+	syncCmd.Flags().Bool("dry-run", false, "only report what would be transferred or deleted")
+	syncCmd.Flags().IntP("workers", "w", 4, "number of files compared and transferred in parallel")
+	syncCmd.Flags().StringArray("include", nil, "only sync files matching this glob, may be repeated")
+	syncCmd.Flags().StringArray("exclude", nil, "skip files matching this glob, may be repeated")
+	addStorageFlags(syncCmd)
+	addOutputFlags(syncCmd)
+	rootCmd.AddCommand(syncCmd)
+}
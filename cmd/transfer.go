@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"dooqiniu/internal/config"
+	"dooqiniu/internal/service"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// cpCmd 复制对象，与拷贝接口相同
+var cpCmd = &cobra.Command{
+	Use:   "cp <key|pattern>... <key|prefix/>",
+	Short: "Copy objects within a bucket or to another bucket",
+	Example: `  dooqiniu cp docs/a.txt docs/b.txt
+  dooqiniu cp 'images/*.png' archive/images/ --dest-bucket backup`,
+	Args: usageArgs(cobra.MinimumNArgs(2)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTransfer(cmd, args, false)
+	},
+}
+
+// mvCmd 移动对象，与移动接口相同
+var mvCmd = &cobra.Command{
+	Use:   "mv <key|pattern>... <key|prefix/>",
+	Short: "Move or rename objects within a bucket or to another bucket",
+	Example: `  dooqiniu mv tmp/upload.bin files/report.bin
+  dooqiniu mv 'inbox/*' processed/`,
+	Args: usageArgs(cobra.MinimumNArgs(2)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTransfer(cmd, args, true)
+	},
+}
+
+// runTransfer 复制或移动对象。只有一个源对象且目标不以 / 结尾时目标为 key，
+// 否则目标为前缀，通配符匹配的对象保留相对通配符之前部分的路径，其他对象使用文件名
+func runTransfer(cmd *cobra.Command, args []string, move bool) error {
+	force, _ := cmd.Flags().GetBool("force")
+	destBucket, _ := cmd.Flags().GetString("dest-bucket")
+	bucket, _ := cmd.Flags().GetString("bucket")
+	profile, _ := cmd.Flags().GetString("profile")
+	action := "copy"
+	if move {
+		action = "move"
+	}
+
+	src, err := commandStorage(cmd)
+	if err != nil {
+		return err
+	}
+	var dest service.Storage
+	if destBucket != "" && destBucket != bucket {
+		if dest, err = service.NewProfileStorage(config.Current(), profile, destBucket); err != nil {
+			return err
+		}
+	}
+
+	sources, target := args[:len(args)-1], args[len(args)-1]
+	var pairs [][2]string
+	for _, source := range sources {
+		keys, err := expandRemote(src, source)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			rel := path.Base(key)
+			if hasGlob(source) {
+				rel = strings.TrimPrefix(key, globBase(source))
+			}
+			pairs = append(pairs, [2]string{key, rel})
+		}
+	}
+	if !strings.HasSuffix(target, "/") {
+		if len(pairs) > 1 || hasGlob(sources[0]) {
+			return exitWith(exitUsage, fmt.Errorf("destination %q must end with / when the source matches several objects", target))
+		}
+		pairs[0][1] = target
+	} else {
+		for i := range pairs {
+			pairs[i][1] = target + pairs[i][1]
+		}
+	}
+
+	out := newOutput(cmd, os.Stdout)
+	defer out.flush()
+	failed := &failures{total: len(pairs)}
+	for _, pair := range pairs {
+		srcKey, destKey := pair[0], pair[1]
+		switch {
+		case dest != nil:
+			err = service.TransferObject(src, dest, srcKey, destKey, force, move)
+		case move:
+			err = src.Move(srcKey, destKey, force)
+		default:
+			err = src.Copy(srcKey, destKey, force)
+		}
+		if err != nil {
+			failed.add(srcKey, err)
+			continue
+		}
+		out.header("SOURCE", "DESTINATION")
+		out.row(struct {
+			Source      string `json:"source"`
+			Destination string `json:"destination"`
+		}{srcKey, destKey}, srcKey, destKey)
+	}
+	return failed.err(action)
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{cpCmd, mvCmd} {
+		cmd.Flags().BoolP("force", "f", false, "overwrite existing destination objects")
+		cmd.Flags().String("dest-bucket", "", "destination bucket profile name, defaults to the source bucket")
+		addStorageFlags(cmd)
+		addOutputFlags(cmd)
+		rootCmd.AddCommand(cmd)
+	}
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"dooqiniu/internal/config"
+	"dooqiniu/internal/model"
+	"dooqiniu/internal/service"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// urlCmd 生成下载链接，与生成文件下载链接接口相同
+var urlCmd = &cobra.Command{
+	Use:   "url <key|pattern>...",
+	Short: "Generate private or public download URLs",
+	Example: `  dooqiniu url docs/report.pdf --expires 24h --attname report.pdf
+  dooqiniu url --public 'images/*.png' --image-view2 1/w/200/h/200`,
+	Args: usageArgs(cobra.MinimumNArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		public, _ := cmd.Flags().GetBool("public")
+		expires, _ := cmd.Flags().GetDuration("expires")
+		attname, _ := cmd.Flags().GetString("attname")
+		imageView2, _ := cmd.Flags().GetString("image-view2")
+		imageMogr2, _ := cmd.Flags().GetString("image-mogr2")
+
+		maxExpires := config.Current().DownloadURLMaxExpires
+		// 未指定 --expires 时使用默认值，但不超过 DOWNLOAD_URL_MAX_EXPIRES
+		if !cmd.Flags().Changed("expires") {
+			expires = min(expires, maxExpires)
+		}
+		if !public && (expires < time.Second || expires > maxExpires) {
+			return exitWith(exitUsage, fmt.Errorf("--expires must be between 1s and %s", maxExpires))
+		}
+		fop, err := service.BuildImageFop(imageView2, imageMogr2)
+		if err != nil {
+			return exitWith(exitUsage, err)
+		}
+		opts := &model.URLOptions{AttName: attname, Fop: fop}
+
+		storage, err := commandStorage(cmd)
+		if err != nil {
+			return err
+		}
+
+		// 与下载接口相同，不检查对象是否存在，只展开通配符
+		var keys []string
+		for _, arg := range args {
+			expanded, err := expandRemote(storage, arg)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, expanded...)
+		}
+
+		out := newOutput(cmd, os.Stdout)
+		defer out.flush()
+		for _, key := range keys {
+			var data struct {
+				Key         string `json:"key"`
+				DownloadURL string `json:"downloadURL"`
+				ExpiresAt   int64  `json:"expiresAt,omitempty"`
+			}
+			data.Key = key
+			expiresAt := "-"
+			if public {
+				data.DownloadURL = storage.GeneratePublicURL(key, opts)
+			} else {
+				expiry := time.Now().Add(expires)
+				data.ExpiresAt = expiry.Unix()
+				data.DownloadURL = storage.GeneratePrivateURL(key, data.ExpiresAt, opts)
+				expiresAt = formatTime(expiry)
+			}
+			out.header("KEY", "EXPIRES", "URL")
+			out.row(data, key, expiresAt, data.DownloadURL)
+		}
+		return nil
+	},
+}
+
+func init() {
+	urlCmd.Flags().Bool("public", false, "generate public URLs instead of signed private URLs")
+	urlCmd.Flags().Duration("expires", 2*time.Hour, "validity of private URLs, at most DOWNLOAD_URL_MAX_EXPIRES, which also caps the default")
+	urlCmd.Flags().String("attname", "", "file name to save as when downloading")
+	urlCmd.Flags().String("image-view2", "", "basic image processing, for example 1/w/200/h/200/format/webp")
+	urlCmd.Flags().String("image-mogr2", "", "advanced image processing, for example auto-orient/thumbnail/!50p")
+	addStorageFlags(urlCmd)
+	addOutputFlags(urlCmd)
+	rootCmd.AddCommand(urlCmd)
+}
@@ -15,9 +15,168 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/api/v1/batch": {
+            "post": {
+                "description": "一次提交多个不同类型的操作（delete/copy/move/stat/chtype），七牛云后端使用批量接口按每批 1000 个执行。\n单个操作失败不影响其他操作，每个操作的状态码和错误信息在 results 中按顺序返回",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "文件管理"
+                ],
+                "summary": "批量删除、复制、移动、查询文件",
+                "parameters": [
+                    {
+                        "description": "操作列表",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.BatchRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "执行完成，返回每个操作的结果",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "请求格式错误或操作参数无效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "批量操作失败，中途失败时 data 中包含已执行的操作的结果，未执行的操作状态码为 503",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/chtype": {
+            "post": {
+                "description": "将指定文件或前缀下的所有文件转换为标准、低频、归档、深度归档或归档直读存储，七牛云后端使用批量接口执行",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "存储类型"
+                ],
+                "summary": "修改文件存储类型",
+                "parameters": [
+                    {
+                        "description": "目标文件和存储类型",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ChangeTypeRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "执行完成，返回成功和失败的数量",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "请求格式错误、未指定文件或存储类型无效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "修改失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/config": {
+            "get": {
+                "description": "返回配置版本号、摘要、加载时间、配置文件、处理中的请求数、需要重启才能生效的配置项以及最近一次重新加载的结果，不返回配置内容",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "配置"
+                ],
+                "summary": "获取当前生效的配置版本",
+                "responses": {
+                    "200": {
+                        "description": "配置状态",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/config/reload": {
+            "post": {
+                "description": "与发送 SIGHUP 相同，按启动时的配置文件、环境变量和命令行参数重新加载配置，配置无效时继续使用原来的版本",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "配置"
+                ],
+                "summary": "重新加载配置",
+                "responses": {
+                    "200": {
+                        "description": "重新加载完成，返回配置状态",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "配置无效，仍使用原来的版本",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
         "/api/v1/copy": {
             "post": {
-                "description": "将七牛云存储空间中的文件从一个位置复制到另一个位置",
+                "description": "将七牛云存储空间中的文件复制到同一存储空间或另一个已配置的存储空间中",
                 "consumes": [
                     "application/json"
                 ],
@@ -48,6 +207,18 @@ const docTemplate = `{
                         "description": "是否强制覆盖目标文件（true/false，默认为 false）",
                         "name": "force",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "源存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "目标存储空间，默认与源存储空间相同",
+                        "name": "destBucket",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -59,7 +230,7 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "srcKey、destKey 缺失或 force 参数无效",
+                        "description": "srcKey、destKey 缺失、force 参数无效或存储空间未配置",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -95,6 +266,12 @@ const docTemplate = `{
                         "name": "objectName",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -124,7 +301,7 @@ const docTemplate = `{
         },
         "/api/v1/download": {
             "get": {
-                "description": "根据文件名生成私有或公共的下载链接",
+                "description": "根据文件名生成私有或公共的下载链接，可指定有效期、下载文件名和图片处理参数",
                 "consumes": [
                     "application/json"
                 ],
@@ -148,18 +325,48 @@ const docTemplate = `{
                         "description": "访问类型 ('public' 或 'private')",
                         "name": "accessType",
                         "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "私有链接有效期（秒），默认 7200 与 DOWNLOAD_URL_MAX_EXPIRES 中较小的值，不能超过 DOWNLOAD_URL_MAX_EXPIRES",
+                        "name": "expires",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "下载时保存的文件名",
+                        "name": "attname",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "图片基本处理参数，例如 1/w/200/h/200/format/webp",
+                        "name": "imageView2",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "图片高级处理参数，例如 auto-orient/thumbnail/!50p/rotate/90",
+                        "name": "imageMogr2",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "生成下载链接成功，返回下载链接",
+                        "description": "生成下载链接成功，返回下载链接及过期时间",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
                     "400": {
-                        "description": "缺少必要参数 objectName",
+                        "description": "缺少必要参数 objectName 或参数无效",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -168,42 +375,53 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/list": {
+        "/api/v1/export": {
             "get": {
-                "description": "列出七牛云存储空间中的文件",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "在服务端遍历前缀下的所有文件，以 NDJSON 或 CSV 格式分块流式返回，\n字段为 key、size、hash、put_time、mime_type、storage_type。\n导出中途失败时响应会在 X-Export-Error trailer 中返回错误信息",
                 "produces": [
-                    "application/json"
+                    "application/x-ndjson",
+                    "text/csv",
+                    "application/gzip"
                 ],
                 "tags": [
                     "文件管理"
                 ],
-                "summary": "获取文件列表",
+                "summary": "导出文件清单",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "文件名前缀筛选条件",
+                        "description": "文件名前缀，留空导出整个存储空间",
                         "name": "prefix",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "游标，继续从上次读取的标记处开始列出",
-                        "name": "marker",
+                        "description": "导出格式 ndjson 或 csv，默认 ndjson",
+                        "name": "format",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "description": "每次列举的最大文件数量 (1-1000)",
-                        "name": "limit",
+                        "type": "boolean",
+                        "description": "为 true 时以 gzip 压缩导出文件",
+                        "name": "gzip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
                         "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "文件列表获取成功，返回文件信息及下一页游标",
+                        "description": "文件清单",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "导出格式无效",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -219,58 +437,68 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/move": {
-            "post": {
-                "description": "将七牛云存储空间中的文件从一个位置移动到另一个位置",
-                "consumes": [
-                    "application/json"
-                ],
+        "/api/v1/index": {
+            "get": {
+                "description": "返回本地元数据索引中的对象数量、总大小、最近一次对账时间以及是否正在对账",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "文件管理"
+                    "元数据索引"
                 ],
-                "summary": "移动文件",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "源文件名",
-                        "name": "srcObject",
-                        "in": "query",
-                        "required": true
+                "summary": "获取元数据索引状态",
+                "responses": {
+                    "200": {
+                        "description": "索引状态",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     },
-                    {
-                        "type": "string",
-                        "description": "目标文件名",
-                        "name": "destObject",
-                        "in": "query",
-                        "required": true
+                    "400": {
+                        "description": "未启用索引",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     },
-                    {
-                        "type": "boolean",
-                        "description": "是否强制覆盖目标文件（true/false，默认为 false）",
-                        "name": "force",
-                        "in": "query"
+                    "500": {
+                        "description": "读取索引失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     }
+                }
+            }
+        },
+        "/api/v1/index/reconcile": {
+            "post": {
+                "description": "在后台全量列举存储后端并更新索引，进度通过 GET /api/v1/index 查看",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "元数据索引"
                 ],
+                "summary": "立即对账元数据索引",
                 "responses": {
-                    "200": {
-                        "description": "文件移动成功",
+                    "202": {
+                        "description": "对账已开始",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
                     "400": {
-                        "description": "srcKey、destKey 缺失或 force 参数无效",
+                        "description": "未启用索引",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
-                    "500": {
-                        "description": "文件移动失败",
+                    "409": {
+                        "description": "已有对账任务正在执行",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -279,9 +507,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/upload": {
-            "get": {
-                "description": "根据文件路径和目标对象名称，将文件上传至七牛云存储",
+        "/api/v1/lifecycle": {
+            "post": {
+                "description": "为指定文件或前缀下的所有文件设置上传后多少天转为低频、归档直读、归档、深度归档存储或删除。\n天数为 0 的规则保持不变，为 -1 时取消已设置的规则。\n按前缀设置 deleteAfterDays 会删除前缀下的所有文件，与按前缀删除相同，需要先以 dryRun=true 预览并获取确认令牌，再携带 confirmToken 执行",
                 "consumes": [
                     "application/json"
                 ],
@@ -289,42 +517,57 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "文件管理"
+                    "存储类型"
                 ],
-                "summary": "上传文件至七牛云",
+                "summary": "设置文件生命周期",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "本地文件路径",
-                        "name": "filePath",
-                        "in": "query",
-                        "required": true
+                        "description": "目标文件和生命周期规则",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.LifecycleRequest"
+                        }
                     },
                     {
                         "type": "string",
-                        "description": "目标对象名称",
-                        "name": "objectName",
-                        "in": "query",
-                        "required": true
+                        "description": "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "上传成功，返回文件信息",
+                        "description": "执行完成，返回成功和失败的数量；dryRun 时返回预览结果",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
                     "400": {
-                        "description": "缺少必要参数 filePath 和 objectName",
+                        "description": "请求格式错误、未指定文件、规则无效或缺少确认令牌",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "不允许删除整个存储桶",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "确认令牌无效或已过期",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
                     "500": {
-                        "description": "上传失败",
+                        "description": "设置失败",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -332,6 +575,1559 @@ const docTemplate = `{
                     }
                 }
             }
+        },
+        "/api/v1/list": {
+            "get": {
+                "description": "列出七牛云存储空间中的文件。指定 delimiter（通常为 /）时按目录方式列举，\n前缀之后包含 delimiter 的文件归并为目录，通过 common_prefixes 返回。\n指定任一筛选或排序条件时在服务端跨页扫描，返回 next_token 用于继续获取，\n续传令牌中包含筛选条件，继续获取时只需传入 token 和 limit",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "文件管理"
+                ],
+                "summary": "获取文件列表",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "文件名前缀筛选条件",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "目录分隔符，例如 /，不能与筛选条件同时使用",
+                        "name": "delimiter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "游标，继续从上次读取的标记处开始列出",
+                        "name": "marker",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每次列举的最大文件数量 (1-1000)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "最小文件大小（字节）",
+                        "name": "minSize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "最大文件大小（字节）",
+                        "name": "maxSize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "最后修改时间不早于该时间，RFC 3339 或 2006-01-02 格式",
+                        "name": "modifiedAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "最后修改时间早于该时间，RFC 3339 或 2006-01-02 格式",
+                        "name": "modifiedBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "文件类型，例如 application/pdf 或 image/*",
+                        "name": "mimeType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "通配符，不包含 / 时匹配文件名，例如 *.pdf",
+                        "name": "glob",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "匹配完整文件名的正则表达式",
+                        "name": "regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "文件名后缀，不区分大小写",
+                        "name": "suffix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "排序字段 key、size 或 last_modified",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "排序方向 asc 或 desc，默认 asc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "单次请求最多扫描的文件数量，不能超过 LIST_SCAN_BUDGET",
+                        "name": "scanBudget",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "上一次筛选返回的 next_token",
+                        "name": "token",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "数据来源 live（默认，直接列举存储空间）或 index（本地元数据索引）",
+                        "name": "source",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "文件列表获取成功，返回文件信息、公共前缀及下一页游标",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "筛选条件或续传令牌无效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "422": {
+                        "description": "排序需要扫描的文件数量超过扫描预算",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "文件列表获取失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "索引尚未完成第一次全量同步",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/meta": {
+            "post": {
+                "description": "修改已上传文件的 MIME 类型，并合并或替换 x-qn-meta-* 自定义元数据，成功后返回修改后的文件信息。\n自定义元数据键名只能包含字母、数字、- 和 _，最长 50 个字符，所有键值总长度不超过 1024 字节",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "文件管理"
+                ],
+                "summary": "修改文件 MIME 类型和自定义元数据",
+                "parameters": [
+                    {
+                        "description": "修改内容",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ChangeMetaRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "修改成功，返回文件信息",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "请求格式错误、没有需要修改的内容或自定义元数据无效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "文件不存在",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "修改失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/move": {
+            "post": {
+                "description": "将七牛云存储空间中的文件移动到同一存储空间或另一个已配置的存储空间中",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "文件管理"
+                ],
+                "summary": "移动文件",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "源文件名",
+                        "name": "srcObject",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "目标文件名",
+                        "name": "destObject",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "是否强制覆盖目标文件（true/false，默认为 false）",
+                        "name": "force",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "源存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "目标存储空间，默认与源存储空间相同",
+                        "name": "destBucket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "文件移动成功",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "srcKey、destKey 缺失、force 参数无效或存储空间未配置",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "文件移动失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/objects/{key}": {
+            "get": {
+                "description": "由服务读取对象内容并流式返回，适用于无法访问七牛云 CDN 域名的客户端。\n支持 Range 断点续传，以及以七牛云 hash 为 ETag 的 If-None-Match/If-Modified-Since 条件请求",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "文件管理"
+                ],
+                "summary": "通过服务下载文件",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "对象名称",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "下载时保存的文件名，指定后以附件形式下载",
+                        "name": "filename",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "为 true 时在浏览器中直接打开，默认 false",
+                        "name": "inline",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "字节范围，例如 bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "文件内容",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "部分文件内容",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "文件未修改"
+                    },
+                    "404": {
+                        "description": "文件不存在",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "416": {
+                        "description": "Range 无效"
+                    },
+                    "500": {
+                        "description": "读取文件失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "head": {
+                "description": "只返回响应头，不返回文件内容。除 Content-Length、Content-Type、ETag、Last-Modified 外，\n还通过 X-Storage-Type、X-Object-Status、X-Restore-Status 返回存储类型、文件状态和解冻状态，\n自定义元数据以 X-Qn-Meta-\u003cname\u003e 响应头返回",
+                "tags": [
+                    "文件管理"
+                ],
+                "summary": "获取文件元数据",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "对象名称",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "文件存在"
+                    },
+                    "304": {
+                        "description": "文件未修改"
+                    },
+                    "404": {
+                        "description": "文件不存在"
+                    }
+                }
+            }
+        },
+        "/api/v1/prefix": {
+            "delete": {
+                "description": "先以 dryRun=true 预览前缀下的文件数量和总大小并获取确认令牌，再携带 confirmToken 执行删除。\n执行删除时以 NDJSON 流式返回进度，每删除一批文件输出一行，最后一行 done 为 true。\n空前缀（整个存储桶）默认被拒绝，需要配置 ALLOW_ROOT_PREFIX_DELETE=true",
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "文件管理"
+                ],
+                "summary": "按前缀删除文件",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "要删除的文件名前缀，例如 tmp/2024-10/",
+                        "name": "prefix",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "命名存储空间，默认使用账号的默认存储空间",
+                        "name": "bucket",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "为 true 时只统计不删除，返回确认令牌",
+                        "name": "dryRun",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "预览返回的确认令牌，10 分钟内有效且只能使用一次，账号、存储空间和前缀需要与预览时相同",
+                        "name": "confirmToken",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "预览结果或删除进度",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "前缀无效或缺少确认令牌",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "不允许删除整个存储桶",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "确认令牌无效或已过期",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "删除失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restore": {
+            "get": {
+                "description": "restore_state 为 not_archived（非归档文件）、frozen（冻结）、restoring（解冻中）或 restored（已解冻）",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "存储类型"
+                ],
+                "summary": "查询归档文件的解冻状态",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "文件名",
+                        "name": "objectName",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "解冻状态",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "缺少文件名",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "文件不存在",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "查询失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "对归档存储或深度归档存储的文件发起解冻，解冻通常需要数分钟到数小时，进度通过 GET /api/v1/restore 查看",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "存储类型"
+                ],
+                "summary": "解冻归档文件",
+                "parameters": [
+                    {
+                        "description": "目标文件和解冻有效期",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.RestoreRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "执行完成，返回成功和失败的数量",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "请求格式错误、未指定文件或有效期无效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "解冻失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stat": {
+            "get": {
+                "description": "返回文件大小、hash、MIME 类型、上传时间、存储类型、文件状态、解冻状态和自定义元数据",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "文件管理"
+                ],
+                "summary": "获取文件信息",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "文件名",
+                        "name": "objectName",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "文件信息",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "缺少文件名",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "文件不存在",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "获取文件信息失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stats": {
+            "get": {
+                "description": "统计前缀下的对象数量和总大小，并按第一级目录、文件类型、存储类型和最后修改时间分组。\n默认返回 STATS_CACHE_TTL 内的最近一次快照，refresh=true 时重新统计，每次统计都会保存为快照",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用量统计"
+                ],
+                "summary": "获取存储用量统计",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "统计的文件名前缀，留空统计整个存储空间",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "数据来源 live（默认，直接列举存储空间）或 index（本地元数据索引）",
+                        "name": "source",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "为 true 时忽略缓存的快照重新统计",
+                        "name": "refresh",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "用量统计，cached 表示是否来自缓存的快照",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "数据来源无效或未启用索引",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "统计失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "索引尚未完成第一次全量同步",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stats/history": {
+            "get": {
+                "description": "按时间顺序返回保存在本地的用量统计快照，用于绘制增长趋势",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "用量统计"
+                ],
+                "summary": "获取用量统计历史",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "统计的文件名前缀，与生成快照时一致",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "起始时间（含），RFC 3339 或 2006-01-02 格式",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "结束时间（不含），RFC 3339 或 2006-01-02 格式",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "快照列表",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "时间格式无效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "读取快照失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tus": {
+            "post": {
+                "description": "根据 Upload-Length 和 Upload-Metadata 创建上传任务，metadata 中的 objectName（缺省为 filename）作为目标对象名称",
+                "tags": [
+                    "tus 上传"
+                ],
+                "summary": "创建 tus 上传",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "tus 协议版本 1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "文件总大小",
+                        "name": "Upload-Length",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "tus 元数据，支持 objectName、filename、filetype",
+                        "name": "Upload-Metadata",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "命名存储空间，上传完成后写入该存储空间",
+                        "name": "bucket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "创建成功，Location 头为上传地址"
+                    },
+                    "400": {
+                        "description": "Upload-Length 或 Upload-Metadata 无效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "创建失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "options": {
+                "description": "返回服务端支持的 tus 版本、扩展及校验算法",
+                "tags": [
+                    "tus 上传"
+                ],
+                "summary": "tus 协议能力查询",
+                "responses": {
+                    "204": {
+                        "description": "协议信息在响应头中返回"
+                    }
+                }
+            }
+        },
+        "/api/v1/tus/{id}": {
+            "delete": {
+                "description": "删除上传任务及已接收的数据",
+                "tags": [
+                    "tus 上传"
+                ],
+                "summary": "终止 tus 上传",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "tus 协议版本 1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "上传 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "上传已终止"
+                    },
+                    "404": {
+                        "description": "上传不存在、已过期或属于其他账号",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "423": {
+                        "description": "上传正在被其他请求写入",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "终止失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "head": {
+                "description": "返回已接收的字节数，客户端据此继续上传",
+                "tags": [
+                    "tus 上传"
+                ],
+                "summary": "查询 tus 上传偏移量",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "tus 协议版本 1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "上传 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Upload-Offset 与 Upload-Length 在响应头中返回"
+                    },
+                    "404": {
+                        "description": "上传不存在、已过期或属于其他账号"
+                    }
+                }
+            },
+            "patch": {
+                "description": "从 Upload-Offset 处追加数据，全部接收后写入七牛云并返回文件信息",
+                "consumes": [
+                    "application/offset+octet-stream"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tus 上传"
+                ],
+                "summary": "上传 tus 分块",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "tus 协议版本 1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "本次数据的起始偏移量",
+                        "name": "Upload-Offset",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "分块摘要，格式为 算法 Base64值",
+                        "name": "Upload-Checksum",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "上传 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "上传完成，返回文件信息",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "204": {
+                        "description": "分块接收成功"
+                    },
+                    "400": {
+                        "description": "请求头无效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "上传不存在、已过期或属于其他账号",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Upload-Offset 与已接收的偏移量不一致",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "415": {
+                        "description": "Content-Type 无效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "423": {
+                        "description": "上传正在被其他请求写入",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "460": {
+                        "description": "分块校验失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "上传失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/upload": {
+            "post": {
+                "description": "以 multipart/form-data 的 file 字段或原始请求体上传文件，数据直接流式写入七牛云存储\n自定义元数据通过 x-qn-meta-\u003cname\u003e 查询参数、X-Qn-Meta-\u003cname\u003e 请求头或 file 之前的同名表单字段指定",
+                "consumes": [
+                    "multipart/form-data",
+                    "application/octet-stream"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "文件管理"
+                ],
+                "summary": "上传文件至七牛云",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "目标对象名称，multipart 上传时缺省为文件名",
+                        "name": "objectName",
+                        "in": "query"
+                    },
+                    {
+                        "type": "file",
+                        "description": "上传的文件（multipart/form-data 模式）",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET",
+                        "name": "bucket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "上传成功，返回文件信息",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "缺少上传文件或 objectName，表单字段超过 1024 字节，或自定义元数据无效",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "上传失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/uploads": {
+            "post": {
+                "description": "创建七牛云分片上传任务并持久化会话，服务重启后可继续上传",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "分片上传"
+                ],
+                "summary": "初始化分片上传会话",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "目标对象名称",
+                        "name": "objectName",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "文件 MIME 类型",
+                        "name": "contentType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "命名存储空间，会话之后的请求都使用该存储空间",
+                        "name": "bucket",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "会话创建成功，返回会话信息",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "缺少必要参数 objectName",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "会话创建失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/uploads/{id}": {
+            "get": {
+                "description": "返回会话信息及七牛云上已经上传成功的分片，用于断点续传",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "分片上传"
+                ],
+                "summary": "查询已上传的分片",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "会话 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "查询成功，返回会话及分片列表",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "会话不存在",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "查询失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "终止七牛云分片上传任务并删除会话",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "分片上传"
+                ],
+                "summary": "终止分片上传",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "会话 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "上传已终止",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "会话不存在",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "终止失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/uploads/{id}/complete": {
+            "post": {
+                "description": "按分片编号顺序合成文件，成功后删除会话",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "分片上传"
+                ],
+                "summary": "完成分片上传",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "会话 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "上传成功，返回文件信息",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "没有已上传的分片",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "会话不存在",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "合成文件失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/uploads/{id}/parts/{n}": {
+            "put": {
+                "description": "请求体为分片内容，同一分片编号可重复上传以覆盖之前的数据",
+                "consumes": [
+                    "application/octet-stream"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "分片上传"
+                ],
+                "summary": "上传分片",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "会话 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "分片编号 (1-10000)",
+                        "name": "n",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "分片上传成功，返回分片信息",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "分片编号无效或分片为空",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "会话不存在",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "分片上传失败",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/webdav/{path}": {
+            "get": {
+                "description": "支持 PROPFIND、GET、PUT、DELETE、MKCOL、COPY、MOVE、LOCK 等 WebDAV 方法，目录以 \"/\" 分隔的前缀表示",
+                "tags": [
+                    "WebDAV"
+                ],
+                "summary": "WebDAV 访问",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "文件或目录路径",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "按 WebDAV 协议返回"
+                    },
+                    "400": {
+                        "description": "不支持 bucket 参数",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.BatchRequest": {
+            "type": "object",
+            "properties": {
+                "operations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.BatchOperation"
+                    }
+                }
+            }
+        },
+        "api.ChangeMetaRequest": {
+            "type": "object",
+            "properties": {
+                "metadata": {
+                    "description": "MetaData 自定义元数据，键名可以省略 x-qn-meta- 前缀，合并模式下值为空字符串表示删除该键",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "mimeType": {
+                    "description": "MimeType 新的 MIME 类型，为空时保持不变",
+                    "type": "string"
+                },
+                "objectName": {
+                    "type": "string"
+                },
+                "replace": {
+                    "description": "Replace 为 true 时用 metadata 替换全部自定义元数据，默认与原有元数据合并",
+                    "type": "boolean"
+                }
+            }
+        },
+        "api.ChangeTypeRequest": {
+            "type": "object",
+            "properties": {
+                "objectNames": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Type 目标存储类型：0 标准存储，1 低频存储，2 归档存储，3 深度归档存储，4 归档直读存储",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.LifecycleRequest": {
+            "type": "object",
+            "properties": {
+                "confirmToken": {
+                    "type": "string"
+                },
+                "deleteAfterDays": {
+                    "type": "integer"
+                },
+                "dryRun": {
+                    "description": "DryRun 与 ConfirmToken 用于按前缀设置删除规则，与按前缀删除相同，需要先预览再携带确认令牌执行",
+                    "type": "boolean"
+                },
+                "objectNames": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "toArchiveAfterDays": {
+                    "type": "integer"
+                },
+                "toArchiveIRAfterDays": {
+                    "type": "integer"
+                },
+                "toDeepArchiveAfterDays": {
+                    "type": "integer"
+                },
+                "toIAAfterDays": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.RestoreRequest": {
+            "type": "object",
+            "properties": {
+                "freezeAfterDays": {
+                    "description": "FreezeAfterDays 解冻后的有效天数，1 到 7 天，到期后重新冻结",
+                    "type": "integer"
+                },
+                "objectNames": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "prefix": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.BatchOperation": {
+            "type": "object",
+            "properties": {
+                "destObject": {
+                    "type": "string"
+                },
+                "force": {
+                    "type": "boolean"
+                },
+                "freezeAfterDays": {
+                    "description": "FreezeAfterDays restore 解冻后的有效天数，1 到 7 天",
+                    "type": "integer"
+                },
+                "lifecycle": {
+                    "description": "Lifecycle lifecycle 操作设置的生命周期规则",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.LifecycleRule"
+                        }
+                    ]
+                },
+                "objectName": {
+                    "type": "string"
+                },
+                "op": {
+                    "type": "string"
+                },
+                "srcObject": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Type chtype 的目标存储类型：0 标准存储，1 低频存储，2 归档存储，3 深度归档存储，4 归档直读存储",
+                    "type": "integer"
+                }
+            }
+        },
+        "model.LifecycleRule": {
+            "type": "object",
+            "properties": {
+                "deleteAfterDays": {
+                    "type": "integer"
+                },
+                "toArchiveAfterDays": {
+                    "type": "integer"
+                },
+                "toArchiveIRAfterDays": {
+                    "type": "integer"
+                },
+                "toDeepArchiveAfterDays": {
+                    "type": "integer"
+                },
+                "toIAAfterDays": {
+                    "type": "integer"
+                }
+            }
         }
     }
 }`
@@ -0,0 +1,448 @@
+package api_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"dooqiniu/internal/config"
+	"dooqiniu/internal/model"
+	"dooqiniu/internal/service"
+	"dooqiniu/router"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// response 接口统一的 JSON 响应
+type response struct {
+	Code int             `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// newTestRouter 使用内存后端创建与服务相同的路由，不需要七牛云凭证。
+// 内存后端在进程内共用，测试结束时清空用到的存储空间
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	t.Setenv("STORAGE_BACKEND", "memory")
+	cfg, err := config.Load("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.UploadSessionDir = t.TempDir()
+	cfg.TusUploadDir = t.TempDir()
+	cfg.StatsDir = t.TempDir()
+	cfg.Buckets = map[string]model.BucketProfile{"staging": {Bucket: "acme-staging"}}
+	config.Set(cfg)
+	t.Cleanup(func() {
+		for _, bucket := range []string{"", "staging"} {
+			if s, err := service.NewBucketStorage(cfg, bucket); err == nil {
+				service.DeletePrefix(context.Background(), s, "", nil, nil)
+			}
+		}
+	})
+
+	r := gin.New()
+	router.SetupRoutes(r)
+	return r
+}
+
+func do(t *testing.T, r http.Handler, method, target string, body io.Reader, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, body)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder) response {
+	t.Helper()
+	var resp response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+	}
+	return resp
+}
+
+func upload(t *testing.T, r http.Handler, target, content string) {
+	t.Helper()
+	w := do(t, r, http.MethodPost, target, strings.NewReader(content), map[string]string{"Content-Type": "text/plain"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("upload %s: %d %s", target, w.Code, w.Body.String())
+	}
+}
+
+func TestObjectHandlers(t *testing.T) {
+	r := newTestRouter(t)
+	upload(t, r, "/api/v1/upload?objectName=handlers/a.txt&x-qn-meta-owner=alice", "hello")
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"stat", http.MethodGet, "/api/v1/stat?objectName=handlers/a.txt", http.StatusOK, `"owner":"alice"`},
+		{"stat missing", http.MethodGet, "/api/v1/stat?objectName=handlers/missing.txt", http.StatusNotFound, ""},
+		{"read object", http.MethodGet, "/api/v1/objects/handlers/a.txt", http.StatusOK, "hello"},
+		{"list", http.MethodGet, "/api/v1/list?prefix=handlers/", http.StatusOK, `"handlers/a.txt"`},
+		{"download url", http.MethodGet, "/api/v1/download?objectName=handlers/a.txt", http.StatusOK, `"downloadURL"`},
+		{"download url without objectName", http.MethodGet, "/api/v1/download", http.StatusBadRequest, ""},
+		{"unknown bucket", http.MethodGet, "/api/v1/stat?objectName=handlers/a.txt&bucket=nope", http.StatusBadRequest, "unknown bucket"},
+		{"upload without objectName", http.MethodPost, "/api/v1/upload", http.StatusBadRequest, ""},
+		{"copy", http.MethodPost, "/api/v1/copy?srcObject=handlers/a.txt&destObject=handlers/b.txt", http.StatusOK, ""},
+		{"copy existing", http.MethodPost, "/api/v1/copy?srcObject=handlers/a.txt&destObject=handlers/b.txt", http.StatusConflict, ""},
+		{"move", http.MethodPost, "/api/v1/move?srcObject=handlers/b.txt&destObject=handlers/c.txt", http.StatusOK, ""},
+		{"moved source", http.MethodGet, "/api/v1/stat?objectName=handlers/b.txt", http.StatusNotFound, ""},
+		{"copy to bucket", http.MethodPost, "/api/v1/copy?srcObject=handlers/a.txt&destObject=handlers/a.txt&destBucket=staging", http.StatusOK, ""},
+		{"stat in bucket", http.MethodGet, "/api/v1/stat?objectName=handlers/a.txt&bucket=staging", http.StatusOK, `"owner":"alice"`},
+		{"delete", http.MethodDelete, "/api/v1/delete?objectName=handlers/c.txt", http.StatusOK, ""},
+		{"delete missing", http.MethodDelete, "/api/v1/delete?objectName=handlers/c.txt", http.StatusNotFound, ""},
+	}
+	// 各用例依次执行，后面的用例依赖前面用例的结果
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(t, r, tt.method, tt.target, nil, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body %q does not contain %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestBatchHandler(t *testing.T) {
+	r := newTestRouter(t)
+	upload(t, r, "/api/v1/upload?objectName=batch/a.txt", "a")
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCodes  []int
+	}{
+		{"empty", `{"operations":[]}`, http.StatusBadRequest, nil},
+		{"invalid operation", `{"operations":[{"op":"delete"}]}`, http.StatusBadRequest, nil},
+		{"mixed", `{"operations":[
+			{"op":"stat","objectName":"batch/a.txt"},
+			{"op":"copy","srcObject":"batch/a.txt","destObject":"batch/b.txt"},
+			{"op":"delete","objectName":"batch/missing.txt"}
+		]}`, http.StatusOK, []int{http.StatusOK, http.StatusOK, http.StatusNotFound}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(t, r, http.MethodPost, "/api/v1/batch", strings.NewReader(tt.body), map[string]string{"Content-Type": "application/json"})
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantCodes == nil {
+				return
+			}
+			var data struct {
+				Results []model.BatchResult `json:"results"`
+			}
+			if err := json.Unmarshal(decode(t, w).Data, &data); err != nil {
+				t.Fatal(err)
+			}
+			if len(data.Results) != len(tt.wantCodes) {
+				t.Fatalf("got %d results, want %d", len(data.Results), len(tt.wantCodes))
+			}
+			for i, code := range tt.wantCodes {
+				if data.Results[i].Code != code {
+					t.Errorf("results[%d].code = %d, want %d", i, data.Results[i].Code, code)
+				}
+			}
+		})
+	}
+}
+
+func TestDeletePrefixHandler(t *testing.T) {
+	r := newTestRouter(t)
+	for _, key := range []string{"prefix/tmp/a.txt", "prefix/tmp/b.txt", "prefix/keep.txt"} {
+		upload(t, r, "/api/v1/upload?objectName="+key, key)
+	}
+
+	if w := do(t, r, http.MethodDelete, "/api/v1/prefix?prefix=prefix/tmp/", nil, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("delete without token: status = %d, want 400", w.Code)
+	}
+	if w := do(t, r, http.MethodDelete, "/api/v1/prefix?prefix=/&dryRun=true", nil, nil); w.Code != http.StatusForbidden {
+		t.Errorf("root prefix: status = %d, want 403", w.Code)
+	}
+
+	dryRun := func() string {
+		w := do(t, r, http.MethodDelete, "/api/v1/prefix?prefix=prefix/tmp/&dryRun=true", nil, nil)
+		var plan struct {
+			Count        int64  `json:"count"`
+			ConfirmToken string `json:"confirmToken"`
+		}
+		if err := json.Unmarshal(decode(t, w).Data, &plan); err != nil || plan.Count != 2 || plan.ConfirmToken == "" {
+			t.Fatalf("dry run = %s", w.Body.String())
+		}
+		return plan.ConfirmToken
+	}
+
+	// 令牌不能用于其他存储空间或前缀
+	token := dryRun()
+	if w := do(t, r, http.MethodDelete, "/api/v1/prefix?prefix=prefix/tmp/&bucket=staging&confirmToken="+token, nil, nil); w.Code != http.StatusConflict {
+		t.Errorf("token used on another bucket: status = %d, want 409", w.Code)
+	}
+	token = dryRun()
+	if w := do(t, r, http.MethodDelete, "/api/v1/prefix?prefix=prefix/&confirmToken="+token, nil, nil); w.Code != http.StatusConflict {
+		t.Errorf("token used on another prefix: status = %d, want 409", w.Code)
+	}
+
+	token = dryRun()
+	w := do(t, r, http.MethodDelete, "/api/v1/prefix?prefix=prefix/tmp/&confirmToken="+token, nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete: status = %d: %s", w.Code, w.Body.String())
+	}
+	var last map[string]any
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		last = nil
+		json.Unmarshal(scanner.Bytes(), &last)
+	}
+	if last["done"] != true || last["deleted"] != float64(2) {
+		t.Errorf("last progress line = %v", last)
+	}
+	if w := do(t, r, http.MethodGet, "/api/v1/stat?objectName=prefix/keep.txt", nil, nil); w.Code != http.StatusOK {
+		t.Errorf("object outside the prefix was deleted: %d", w.Code)
+	}
+}
+
+func TestUploadSessionUsesSessionBucket(t *testing.T) {
+	r := newTestRouter(t)
+
+	w := do(t, r, http.MethodPost, "/api/v1/uploads?objectName=session/big.bin&bucket=staging", nil, nil)
+	var session model.UploadSession
+	if err := json.Unmarshal(decode(t, w).Data, &session); err != nil || session.ID == "" {
+		t.Fatalf("initiate: %s", w.Body.String())
+	}
+	if session.Bucket != "staging" {
+		t.Errorf("session bucket = %q, want staging", session.Bucket)
+	}
+
+	base := "/api/v1/uploads/" + session.ID
+	if w := do(t, r, http.MethodPut, base+"/parts/1", strings.NewReader("part one"), nil); w.Code != http.StatusOK {
+		t.Fatalf("upload part: %d %s", w.Code, w.Body.String())
+	}
+	// 会话只能通过创建时的账号访问
+	if w := do(t, r, http.MethodGet, base, nil, map[string]string{"X-Qiniu-Profile": "acme"}); w.Code != http.StatusNotFound {
+		t.Errorf("other profile: status = %d, want 404", w.Code)
+	}
+	if w := do(t, r, http.MethodPost, base+"/complete", nil, nil); w.Code != http.StatusOK {
+		t.Fatalf("complete: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do(t, r, http.MethodGet, "/api/v1/stat?objectName=session/big.bin&bucket=staging", nil, nil); w.Code != http.StatusOK {
+		t.Errorf("object not in the session bucket: %d", w.Code)
+	}
+	if w := do(t, r, http.MethodGet, "/api/v1/stat?objectName=session/big.bin", nil, nil); w.Code != http.StatusNotFound {
+		t.Errorf("object written to the default bucket: %d", w.Code)
+	}
+}
+
+func TestConfigRevisionHeader(t *testing.T) {
+	r := newTestRouter(t)
+	w := do(t, r, http.MethodGet, "/api/v1/config", nil, nil)
+	if w.Code != http.StatusOK || w.Header().Get("X-Config-Revision") == "" {
+		t.Errorf("status = %d, revision header = %q", w.Code, w.Header().Get("X-Config-Revision"))
+	}
+}
+
+func TestUploadFormFieldLimit(t *testing.T) {
+	r := newTestRouter(t)
+
+	tests := []struct {
+		name       string
+		field      string
+		value      string
+		wantStatus int
+	}{
+		{"objectName at limit", "objectName", "form/" + strings.Repeat("a", 1019), http.StatusOK},
+		{"objectName too long", "objectName", "form/" + strings.Repeat("a", 1020), http.StatusBadRequest},
+		// 超长的元数据字段在读取时就被拒绝，而不是截断后上传
+		{"metadata too long", "x-qn-meta-note", strings.Repeat("n", 1025), http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			form := multipart.NewWriter(&body)
+			form.WriteField(tt.field, tt.value)
+			file, _ := form.CreateFormFile("file", fmt.Sprintf("form/%d.txt", i))
+			file.Write([]byte("content"))
+			form.Close()
+
+			w := do(t, r, http.MethodPost, "/api/v1/upload", &body, map[string]string{"Content-Type": form.FormDataContentType()})
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestLifecyclePrefixDeleteRequiresToken(t *testing.T) {
+	r := newTestRouter(t)
+	for _, key := range []string{"lifecycle/a.txt", "lifecycle/b.txt"} {
+		upload(t, r, "/api/v1/upload?objectName="+key, key)
+	}
+	lifecycle := func(body string) *httptest.ResponseRecorder {
+		return do(t, r, http.MethodPost, "/api/v1/lifecycle", strings.NewReader(body), map[string]string{"Content-Type": "application/json"})
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"without token", `{"prefix":"lifecycle/","deleteAfterDays":30}`, http.StatusBadRequest},
+		{"root prefix", `{"prefix":"/","deleteAfterDays":30,"dryRun":true}`, http.StatusForbidden},
+		{"invalid token", `{"prefix":"lifecycle/","deleteAfterDays":30,"confirmToken":"invalid"}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := lifecycle(tt.body); w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+
+	w := lifecycle(`{"prefix":"lifecycle/","deleteAfterDays":30,"dryRun":true}`)
+	var plan struct {
+		Count        int64  `json:"count"`
+		ConfirmToken string `json:"confirmToken"`
+	}
+	if err := json.Unmarshal(decode(t, w).Data, &plan); err != nil || plan.Count != 2 || plan.ConfirmToken == "" {
+		t.Fatalf("dry run = %s", w.Body.String())
+	}
+	// 令牌只能用于预览时的前缀，且只能使用一次
+	if w := lifecycle(`{"prefix":"lifecycle/a","deleteAfterDays":30,"confirmToken":"` + plan.ConfirmToken + `"}`); w.Code != http.StatusConflict {
+		t.Errorf("token used on another prefix: status = %d, want 409", w.Code)
+	}
+	w = lifecycle(`{"prefix":"lifecycle/","deleteAfterDays":30,"dryRun":true}`)
+	json.Unmarshal(decode(t, w).Data, &plan)
+	if w := lifecycle(`{"prefix":"lifecycle/","deleteAfterDays":30,"confirmToken":"` + plan.ConfirmToken + `"}`); w.Code == http.StatusBadRequest || w.Code == http.StatusConflict {
+		t.Errorf("confirmed: status = %d: %s", w.Code, w.Body.String())
+	}
+
+	// 立即删除与设置删除规则的令牌不能互相使用
+	w = do(t, r, http.MethodDelete, "/api/v1/prefix?prefix=lifecycle/&dryRun=true", nil, nil)
+	json.Unmarshal(decode(t, w).Data, &plan)
+	if w := lifecycle(`{"prefix":"lifecycle/","deleteAfterDays":30,"confirmToken":"` + plan.ConfirmToken + `"}`); w.Code != http.StatusConflict {
+		t.Errorf("prefix delete token used for lifecycle: status = %d, want 409", w.Code)
+	}
+	w = lifecycle(`{"prefix":"lifecycle/","deleteAfterDays":30,"dryRun":true}`)
+	json.Unmarshal(decode(t, w).Data, &plan)
+	if w := do(t, r, http.MethodDelete, "/api/v1/prefix?prefix=lifecycle/&confirmToken="+plan.ConfirmToken, nil, nil); w.Code != http.StatusConflict {
+		t.Errorf("lifecycle token used for prefix delete: status = %d, want 409", w.Code)
+	}
+
+	// 令牌绑定预览时的存储空间
+	upload(t, r, "/api/v1/upload?objectName=lifecycle/c.txt&bucket=staging", "c")
+	w = do(t, r, http.MethodPost, "/api/v1/lifecycle?bucket=staging", strings.NewReader(`{"prefix":"lifecycle/","deleteAfterDays":30,"dryRun":true}`), map[string]string{"Content-Type": "application/json"})
+	if err := json.Unmarshal(decode(t, w).Data, &plan); err != nil || plan.Count != 1 {
+		t.Fatalf("dry run in staging = %s", w.Body.String())
+	}
+	if w := lifecycle(`{"prefix":"lifecycle/","deleteAfterDays":30,"confirmToken":"` + plan.ConfirmToken + `"}`); w.Code != http.StatusConflict {
+		t.Errorf("staging token used on the default bucket: status = %d, want 409", w.Code)
+	}
+
+	// 不包含删除规则或只处理指定文件时不需要确认令牌
+	for _, body := range []string{`{"prefix":"lifecycle/","toIAAfterDays":30}`, `{"objectNames":["lifecycle/a.txt"],"deleteAfterDays":30}`} {
+		if w := lifecycle(body); w.Code == http.StatusBadRequest || w.Code == http.StatusConflict {
+			t.Errorf("%s: status = %d: %s", body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestTusUploadOwnedByProfile(t *testing.T) {
+	r := newTestRouter(t)
+	tus := func(method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+		h := map[string]string{"Tus-Resumable": "1.0.0"}
+		for name, value := range header {
+			h[name] = value
+		}
+		return do(t, r, method, target, strings.NewReader(body), h)
+	}
+
+	w := tus(http.MethodPost, "/api/v1/tus", "", map[string]string{
+		"Upload-Length":   "5",
+		"Upload-Metadata": "objectName " + base64.StdEncoding.EncodeToString([]byte("tus/owned.txt")),
+	})
+	if w.Code != http.StatusCreated || w.Header().Get("Upload-Expires") == "" {
+		t.Fatalf("create: %d %v", w.Code, w.Header())
+	}
+	location := w.Header().Get("Location")
+
+	// 其他账号不能查询、追加或终止该上传
+	other := map[string]string{"X-Qiniu-Profile": "acme", "Upload-Offset": "0", "Content-Type": "application/offset+octet-stream"}
+	for _, method := range []string{http.MethodHead, http.MethodPatch, http.MethodDelete} {
+		if w := tus(method, location, "hello", other); w.Code != http.StatusNotFound {
+			t.Errorf("%s from another profile: status = %d, want 404", method, w.Code)
+		}
+	}
+
+	w = tus(http.MethodPatch, location, "hello", map[string]string{"Upload-Offset": "0", "Content-Type": "application/offset+octet-stream"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("patch: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(t, r, http.MethodGet, "/api/v1/stat?objectName=tus/owned.txt", nil, nil); w.Code != http.StatusOK {
+		t.Errorf("stat: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandlersUseBucketParameter(t *testing.T) {
+	r := newTestRouter(t)
+	upload(t, r, "/api/v1/upload?objectName=bucket/a.txt&bucket=staging", "staging")
+	header := map[string]string{"Content-Type": "application/json"}
+
+	// 对象只存在于 staging，忽略 bucket 参数时会访问默认存储空间而返回 404
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+	}{
+		{"batch", http.MethodPost, "/api/v1/batch?bucket=staging", `{"operations":[{"op":"stat","objectName":"bucket/a.txt"}]}`},
+		{"meta", http.MethodPost, "/api/v1/meta?bucket=staging", `{"objectName":"bucket/a.txt","mimeType":"text/markdown"}`},
+		{"restore status", http.MethodGet, "/api/v1/restore?objectName=bucket/a.txt&bucket=staging", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(t, r, tt.method, tt.target, strings.NewReader(tt.body), header)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			if strings.Contains(w.Body.String(), `"code":404`) {
+				t.Errorf("operation ran against the default bucket: %s", w.Body.String())
+			}
+		})
+	}
+
+	w := do(t, r, http.MethodGet, "/api/v1/stat?objectName=bucket/a.txt&bucket=staging", nil, nil)
+	if !strings.Contains(w.Body.String(), "text/markdown") {
+		t.Errorf("metadata was not changed in staging: %s", w.Body.String())
+	}
+	if w := do(t, r, http.MethodPost, "/api/v1/meta?bucket=missing", strings.NewReader(`{"objectName":"bucket/a.txt","mimeType":"text/plain"}`), header); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown bucket: status = %d, want 400", w.Code)
+	}
+	if w := do(t, r, "PROPFIND", "/webdav/?bucket=staging", nil, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("webdav with bucket: status = %d, want 400", w.Code)
+	}
+}
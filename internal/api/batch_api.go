@@ -0,0 +1,103 @@
+package api
+
+import (
+	"dooqiniu/internal/model"
+	"dooqiniu/internal/service"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 单次请求允许的最大操作数，存储后端会再按自身的上限分批执行
+const maxBatchOperations = 10000
+
+// BatchRequest 批量操作请求
+type BatchRequest struct {
+	Operations []model.BatchOperation `json:"operations"`
+}
+
+// BatchHandler 批量操作接口
+// @Summary 批量删除、复制、移动、查询文件
+// @Description 一次提交多个不同类型的操作（delete/copy/move/stat/chtype），七牛云后端使用批量接口按每批 1000 个执行。
+// @Description 单个操作失败不影响其他操作，每个操作的状态码和错误信息在 results 中按顺序返回
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Param request body BatchRequest true "操作列表"
+// @Param bucket query string false "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
+// @Success 200 {object} map[string]interface{} "执行完成，返回每个操作的结果"
+// @Failure 400 {object} map[string]interface{} "请求格式错误或操作参数无效"
+// @Failure 500 {object} map[string]interface{} "批量操作失败，中途失败时 data 中包含已执行的操作的结果，未执行的操作状态码为 503"
+// @Router /api/v1/batch [post]
+func BatchHandler(c *gin.Context) {
+	var request BatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if len(request.Operations) == 0 || len(request.Operations) > maxBatchOperations {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  fmt.Sprintf("operations must contain 1 to %d items", maxBatchOperations),
+		})
+		return
+	}
+	for i, op := range request.Operations {
+		if err := service.ValidateBatchOperation(op); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": http.StatusBadRequest,
+				"msg":  fmt.Sprintf("operations[%d]: %v", i, err),
+			})
+			return
+		}
+	}
+
+	// 初始化存储后端
+	client, ok := newBucketStorage(c, c.Query("bucket"))
+	if !ok {
+		return
+	}
+
+	results, err := client.Batch(request.Operations)
+	if err != nil && len(results) != len(request.Operations) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "batch operation failed: " + err.Error(),
+		})
+		return
+	}
+
+	failed := 0
+	for i := range results {
+		results[i].Operation = request.Operations[i]
+		if results[i].Code != http.StatusOK {
+			failed++
+		}
+	}
+	data := gin.H{
+		"succeeded": len(results) - failed,
+		"failed":    failed,
+		"results":   results,
+	}
+
+	// 请求中途失败时，同时返回已执行的操作的结果，未执行的操作状态码为 503
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "batch operation failed: " + err.Error(),
+			"data": data,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": http.StatusOK,
+		"msg":  "批量操作完成",
+		"data": data,
+	})
+}
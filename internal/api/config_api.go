@@ -0,0 +1,69 @@
+package api
+
+import (
+	"dooqiniu/internal/config"
+	"dooqiniu/internal/model"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConfigRevisionHeader 返回处理请求时使用的配置版本的响应头
+const ConfigRevisionHeader = "X-Config-Revision"
+
+// TrackConfig 记录使用当前配置版本处理中的请求，配置切换后等待这些请求完成再释放旧的客户端。
+// 获取的配置保存在请求的 context 中，整个请求都使用同一个配置版本
+func TrackConfig(c *gin.Context) {
+	revision, cfg, release := config.Acquire()
+	defer release()
+
+	c.Request = c.Request.WithContext(config.NewContext(c.Request.Context(), cfg))
+	c.Header(ConfigRevisionHeader, strconv.FormatInt(revision.Number, 10))
+	c.Next()
+}
+
+// requestConfig 返回请求开始时获取的配置版本
+func requestConfig(c *gin.Context) *model.Config {
+	return config.FromContext(c.Request.Context())
+}
+
+// ConfigStatusHandler 配置状态接口
+// @Summary 获取当前生效的配置版本
+// @Description 返回配置版本号、摘要、加载时间、配置文件、处理中的请求数、需要重启才能生效的配置项以及最近一次重新加载的结果，不返回配置内容
+// @Tags 配置
+// @Produce json
+// @Success 200 {object} map[string]interface{} "配置状态"
+// @Router /api/v1/config [get]
+func ConfigStatusHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code": http.StatusOK,
+		"msg":  "获取配置状态成功",
+		"data": config.CurrentStatus(),
+	})
+}
+
+// ReloadConfigHandler 重新加载配置接口
+// @Summary 重新加载配置
+// @Description 与发送 SIGHUP 相同，按启动时的配置文件、环境变量和命令行参数重新加载配置，配置无效时继续使用原来的版本
+// @Tags 配置
+// @Produce json
+// @Success 200 {object} map[string]interface{} "重新加载完成，返回配置状态"
+// @Failure 500 {object} map[string]interface{} "配置无效，仍使用原来的版本"
+// @Router /api/v1/config/reload [post]
+func ReloadConfigHandler(c *gin.Context) {
+	if _, err := config.Reload(config.ReloadTriggerAPI); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "failed to reload config: " + err.Error(),
+			"data": config.CurrentStatus(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": http.StatusOK,
+		"msg":  "重新加载配置成功",
+		"data": config.CurrentStatus(),
+	})
+}
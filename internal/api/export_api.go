@@ -0,0 +1,95 @@
+package api
+
+import (
+	"dooqiniu/internal/service"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportContentTypes 各导出格式对应的 Content-Type
+var exportContentTypes = map[string]string{
+	service.ExportFormatNDJSON: "application/x-ndjson",
+	service.ExportFormatCSV:    "text/csv; charset=utf-8",
+}
+
+// ExportListHandler 导出完整文件清单接口
+// @Summary 导出文件清单
+// @Description 在服务端遍历前缀下的所有文件，以 NDJSON 或 CSV 格式分块流式返回，
+// @Description 字段为 key、size、hash、put_time、mime_type、storage_type。
+// @Description 导出中途失败时响应会在 X-Export-Error trailer 中返回错误信息
+// @Tags 文件管理
+// @Produce application/x-ndjson
+// @Produce text/csv
+// @Produce application/gzip
+// @Param prefix query string false "文件名前缀，留空导出整个存储空间"
+// @Param format query string false "导出格式 ndjson 或 csv，默认 ndjson"
+// @Param gzip query bool false "为 true 时以 gzip 压缩导出文件"
+// @Param bucket query string false "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
+// @Success 200 {file} file "文件清单"
+// @Failure 400 {object} map[string]interface{} "导出格式无效"
+// @Failure 500 {object} map[string]interface{} "文件列表获取失败"
+// @Router /api/v1/export [get]
+func ExportListHandler(c *gin.Context) {
+	prefix := c.Query("prefix")
+	format := c.DefaultQuery("format", service.ExportFormatNDJSON)
+	compress := c.Query("gzip") == "true"
+
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "format must be ndjson or csv",
+		})
+		return
+	}
+
+	// 初始化存储后端
+	client, ok := newBucketStorage(c, c.Query("bucket"))
+	if !ok {
+		return
+	}
+
+	filename := fmt.Sprintf("inventory-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+	if compress {
+		contentType = "application/gzip"
+		filename += ".gz"
+	}
+
+	writer, err := service.NewListingWriter(c.Writer, format, compress)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	// 第一页写出之前响应头不会发送，列举失败时仍可返回 JSON 错误
+	header := c.Writer.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	header.Set("Trailer", "X-Export-Count, X-Export-Error")
+
+	count, err := service.ExportListing(client, prefix, writer, c.Writer.Flush)
+	if err != nil && !c.Writer.Written() {
+		header.Del("Content-Disposition")
+		header.Del("Trailer")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "Error getting file list: " + err.Error(),
+		})
+		return
+	}
+
+	// 空清单也要写出响应头
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+	header.Set("X-Export-Count", strconv.FormatInt(count, 10))
+	if err != nil {
+		header.Set("X-Export-Error", err.Error())
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"dooqiniu/internal/service"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 列举和统计的数据来源
+const (
+	sourceLive  = "live"
+	sourceIndex = "index"
+)
+
+// listSource 根据 source 参数选择直接列举存储后端还是使用本地元数据索引，
+// 索引只覆盖默认账号的默认存储空间，不能与 bucket 参数或命名账号同时使用
+func listSource(c *gin.Context) (service.FileLister, bool) {
+	bucket := c.Query("bucket")
+	switch source := c.DefaultQuery("source", sourceLive); source {
+	case sourceLive:
+		return newBucketStorage(c, bucket)
+	case sourceIndex:
+		if bucket != "" || profileName(c) != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": http.StatusBadRequest,
+				"msg":  "source=index only covers the default bucket of the default profile",
+			})
+			return nil, false
+		}
+		idx, ok := readyIndex(c)
+		return idx, ok
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  fmt.Sprintf("invalid source: %s, must be live or index", source),
+		})
+		return nil, false
+	}
+}
+
+// readyIndex 返回已完成全量同步的索引
+func readyIndex(c *gin.Context) (*service.MetadataIndex, bool) {
+	idx := service.DefaultMetadataIndex()
+	if idx == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  service.ErrIndexNotEnabled.Error(),
+		})
+		return nil, false
+	}
+	if !idx.Ready() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"code": http.StatusServiceUnavailable,
+			"msg":  service.ErrIndexNotReady.Error(),
+		})
+		return nil, false
+	}
+	return idx, true
+}
+
+// IndexStatusHandler 元数据索引状态接口
+// @Summary 获取元数据索引状态
+// @Description 返回本地元数据索引中的对象数量、总大小、最近一次对账时间以及是否正在对账
+// @Tags 元数据索引
+// @Produce json
+// @Success 200 {object} map[string]interface{} "索引状态"
+// @Failure 400 {object} map[string]interface{} "未启用索引"
+// @Failure 500 {object} map[string]interface{} "读取索引失败"
+// @Router /api/v1/index [get]
+func IndexStatusHandler(c *gin.Context) {
+	idx := service.DefaultMetadataIndex()
+	if idx == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  service.ErrIndexNotEnabled.Error(),
+		})
+		return
+	}
+
+	status, err := idx.Status()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "failed to read metadata index: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": http.StatusOK,
+		"msg":  "获取索引状态成功",
+		"data": status,
+	})
+}
+
+// ReconcileIndexHandler 触发索引对账接口
+// @Summary 立即对账元数据索引
+// @Description 在后台全量列举存储后端并更新索引，进度通过 GET /api/v1/index 查看
+// @Tags 元数据索引
+// @Produce json
+// @Success 202 {object} map[string]interface{} "对账已开始"
+// @Failure 400 {object} map[string]interface{} "未启用索引"
+// @Failure 409 {object} map[string]interface{} "已有对账任务正在执行"
+// @Router /api/v1/index/reconcile [post]
+func ReconcileIndexHandler(c *gin.Context) {
+	idx := service.DefaultMetadataIndex()
+	if idx == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  service.ErrIndexNotEnabled.Error(),
+		})
+		return
+	}
+
+	if err := idx.StartReconcile(); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrIndexReconciling) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"code": status,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"code": http.StatusAccepted,
+		"msg":  "索引对账已开始",
+	})
+}
@@ -3,8 +3,13 @@ package api
 import (
 	"dooqiniu/internal/model"
 	"dooqiniu/internal/service"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,34 +17,62 @@ import (
 
 // UploadHandler 文件上传接口
 // @Summary 上传文件至七牛云
-// @Description 根据文件路径和目标对象名称，将文件上传至七牛云存储
+// @Description 以 multipart/form-data 的 file 字段或原始请求体上传文件，数据直接流式写入七牛云存储
+// @Description 自定义元数据通过 x-qn-meta-<name> 查询参数、X-Qn-Meta-<name> 请求头或 file 之前的同名表单字段指定
 // @Tags 文件管理
-// @Accept json
+// @Accept multipart/form-data,application/octet-stream
 // @Produce json
-// @Param filePath query string true "本地文件路径"
-// @Param objectName query string true "目标对象名称"
+// @Param objectName query string false "目标对象名称，multipart 上传时缺省为文件名"
+// @Param file formData file false "上传的文件（multipart/form-data 模式）"
+// @Param bucket query string false "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
 // @Success 200 {object} map[string]interface{} "上传成功，返回文件信息"
-// @Failure 400 {object} map[string]interface{} "缺少必要参数 filePath 和 objectName"
+// @Failure 400 {object} map[string]interface{} "缺少上传文件或 objectName，表单字段超过 1024 字节，或自定义元数据无效"
 // @Failure 500 {object} map[string]interface{} "上传失败"
-// @Router /api/v1/upload [get]
+// @Router /api/v1/upload [post]
 func UploadHandler(c *gin.Context) {
-	// Get parameters from request
-	filePath := c.Query("filePath")
 	objectName := c.Query("objectName")
+	metaData := uploadMetaData(c)
+
+	// 初始化存储后端
+	uploader, ok := newBucketStorage(c, c.Query("bucket"))
+	if !ok {
+		return
+	}
+
+	var (
+		uploadResponse *model.UploadResponse
+		err            error
+	)
+	if c.ContentType() == "multipart/form-data" {
+		uploadResponse, err = uploadMultipart(c, uploader, objectName, metaData)
+	} else {
+		// 原始请求体模式，Content-Type 即为文件 MIME 类型
+		if objectName == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": http.StatusBadRequest,
+				"msg":  "缺少objectName参数",
+			})
+			return
+		}
+		metaData, err = service.NormalizeMetaData(metaData)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": http.StatusBadRequest,
+				"msg":  err.Error(),
+			})
+			return
+		}
+		uploadResponse, err = uploader.Upload(c.Request.Body, objectName, c.ContentType(), metaData)
+	}
 
-	if filePath == "" || objectName == "" {
+	var badRequest *badRequestError
+	if errors.As(err, &badRequest) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code": http.StatusBadRequest,
-			"msg":  "缺少filePath 和 objectName参数",
+			"msg":  badRequest.msg,
 		})
 		return
 	}
-
-	// Initialize Qiniu uploader
-	uploader := service.NewQiniuClient()
-
-	// Perform the upload and get file info
-	uploadResponse, err := uploader.Upload(filePath, objectName)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": http.StatusInternalServerError,
@@ -55,16 +88,128 @@ func UploadHandler(c *gin.Context) {
 	})
 }
 
+// badRequestError 表示由客户端请求参数导致的错误
+type badRequestError struct {
+	msg string
+}
+
+func (e *badRequestError) Error() string {
+	return e.msg
+}
+
+// uploadMetaData 从查询参数和请求头中收集上传时附加的自定义元数据，请求头优先
+func uploadMetaData(c *gin.Context) map[string]string {
+	metaData := make(map[string]string)
+	for name, values := range c.Request.URL.Query() {
+		if key, ok := metaKey(name); ok && len(values) > 0 {
+			metaData[key] = values[0]
+		}
+	}
+	for name := range c.Request.Header {
+		if key, ok := metaKey(name); ok {
+			metaData[key] = c.GetHeader(name)
+		}
+	}
+	return metaData
+}
+
+// metaKey 返回 x-qn-meta-<name> 形式参数名中的 name，不区分大小写
+func metaKey(name string) (string, bool) {
+	const prefix = "x-qn-meta-"
+	if len(name) <= len(prefix) || !strings.EqualFold(name[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.ToLower(name[len(prefix):]), true
+}
+
+// uploadMultipart 逐个读取 multipart 分段，遇到 file 字段时直接流式上传，
+// 出现在 file 之前的 objectName 和 x-qn-meta-<name> 表单字段可以覆盖目标对象名称和自定义元数据
+func uploadMultipart(c *gin.Context, uploader model.Uploader, objectName string, metaData map[string]string) (*model.UploadResponse, error) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, &badRequestError{msg: "invalid multipart request: " + err.Error()}
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, &badRequestError{msg: "缺少file字段"}
+		}
+		if err != nil {
+			return nil, &badRequestError{msg: "invalid multipart request: " + err.Error()}
+		}
+
+		switch part.FormName() {
+		case "objectName":
+			value, err := readFormField(part)
+			if err != nil {
+				return nil, err
+			}
+			if len(value) > 0 {
+				objectName = string(value)
+			}
+		case "file":
+			defer part.Close()
+			if objectName == "" {
+				objectName = part.FileName()
+			}
+			if objectName == "" {
+				return nil, &badRequestError{msg: "缺少objectName参数"}
+			}
+			normalized, err := service.NormalizeMetaData(metaData)
+			if err != nil {
+				return nil, &badRequestError{msg: err.Error()}
+			}
+			return uploader.Upload(part, objectName, part.Header.Get("Content-Type"), normalized)
+		default:
+			key, ok := metaKey(part.FormName())
+			if !ok {
+				part.Close()
+				continue
+			}
+			value, err := readFormField(part)
+			if err != nil {
+				return nil, err
+			}
+			metaData[key] = string(value)
+		}
+	}
+}
+
+// maxFormFieldSize file 之前的普通表单字段的最大长度
+const maxFormFieldSize = 1024
+
+// readFormField 读取并关闭普通表单字段，超过 maxFormFieldSize 时返回错误而不是截断
+func readFormField(part *multipart.Part) ([]byte, error) {
+	defer part.Close()
+	value, err := io.ReadAll(io.LimitReader(part, maxFormFieldSize+1))
+	if err != nil {
+		return nil, &badRequestError{msg: "invalid " + part.FormName() + " field: " + err.Error()}
+	}
+	if len(value) > maxFormFieldSize {
+		return nil, &badRequestError{msg: fmt.Sprintf("%s field exceeds %d bytes", part.FormName(), maxFormFieldSize)}
+	}
+	return value, nil
+}
+
+// 私有下载链接的默认有效期
+const defaultDownloadURLExpires = 2 * time.Hour
+
 // DownloadFileHandler 生成文件下载链接接口
 // @Summary 生成文件下载链接
-// @Description 根据文件名生成私有或公共的下载链接
+// @Description 根据文件名生成私有或公共的下载链接，可指定有效期、下载文件名和图片处理参数
 // @Tags 文件管理
 // @Accept json
 // @Produce json
 // @Param objectName query string true "文件名"
 // @Param accessType query string false "访问类型 ('public' 或 'private')" 默认 "private"
-// @Success 200 {object} map[string]interface{} "生成下载链接成功，返回下载链接"
-// @Failure 400 {object} map[string]interface{} "缺少必要参数 objectName"
+// @Param expires query int false "私有链接有效期（秒），默认 7200 与 DOWNLOAD_URL_MAX_EXPIRES 中较小的值，不能超过 DOWNLOAD_URL_MAX_EXPIRES"
+// @Param attname query string false "下载时保存的文件名"
+// @Param imageView2 query string false "图片基本处理参数，例如 1/w/200/h/200/format/webp"
+// @Param imageMogr2 query string false "图片高级处理参数，例如 auto-orient/thumbnail/!50p/rotate/90"
+// @Param bucket query string false "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
+// @Success 200 {object} map[string]interface{} "生成下载链接成功，返回下载链接及过期时间"
+// @Failure 400 {object} map[string]interface{} "缺少必要参数 objectName 或参数无效"
 // @Router /api/v1/download [get]
 func DownloadFileHandler(c *gin.Context) {
 	objectName := c.Query("objectName")
@@ -78,24 +223,50 @@ func DownloadFileHandler(c *gin.Context) {
 		return
 	}
 
-	// 初始化 Qiniu 客户端
-	client := service.NewQiniuClient()
+	// 未指定有效期时使用默认值，但不超过 DOWNLOAD_URL_MAX_EXPIRES
+	maxExpires := requestConfig(c).DownloadURLMaxExpires
+	expires := min(defaultDownloadURLExpires, maxExpires)
+	if value := c.Query("expires"); value != "" {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > maxExpires {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": http.StatusBadRequest,
+				"msg":  fmt.Sprintf("expires must be between 1 and %d seconds", int64(maxExpires/time.Second)),
+			})
+			return
+		}
+		expires = time.Duration(seconds) * time.Second
+	}
 
-	var downloadURL string
+	fop, err := service.BuildImageFop(c.Query("imageView2"), c.Query("imageMogr2"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	opts := &model.URLOptions{AttName: c.Query("attname"), Fop: fop}
+
+	// 初始化存储后端
+	client, ok := newBucketStorage(c, c.Query("bucket"))
+	if !ok {
+		return
+	}
+
+	data := gin.H{}
 	if accessType == "private" {
-		// 设置链接的有效期为2小时
-		expiryTime := time.Now().Add(2 * time.Hour).Unix()
-		downloadURL = client.GeneratePrivateURL(objectName, expiryTime)
+		expiryTime := time.Now().Add(expires).Unix()
+		data["downloadURL"] = client.GeneratePrivateURL(objectName, expiryTime, opts)
+		data["expiresAt"] = expiryTime
 	} else {
-		downloadURL = client.GeneratePublicURL(objectName)
+		data["downloadURL"] = client.GeneratePublicURL(objectName, opts)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code": http.StatusOK,
 		"msg":  "生成下载链接成功",
-		"data": gin.H{
-			"downloadURL": downloadURL,
-		},
+		"data": data,
 	})
 }
 
@@ -106,6 +277,7 @@ func DownloadFileHandler(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param objectName query string true "文件名"
+// @Param bucket query string false "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
 // @Success 200 {object} map[string]interface{} "文件删除成功"
 // @Failure 400 {object} map[string]interface{} "缺少必要参数 objectName"
 // @Failure 500 {object} map[string]interface{} "文件删除失败"
@@ -120,14 +292,18 @@ func DeleteFileHandler(c *gin.Context) {
 		return
 	}
 
-	// 初始化七牛云客户端
-	client := service.NewQiniuClient()
+	// 初始化存储后端
+	client, ok := newBucketStorage(c, c.Query("bucket"))
+	if !ok {
+		return
+	}
 
 	// 调用 Delete 方法删除文件
 	err := client.Delete(objectName)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code": http.StatusInternalServerError,
+		status := storageErrorStatus(err)
+		c.JSON(status, gin.H{
+			"code": status,
 			"msg":  "failed to delete file: " + err.Error(),
 		})
 		return
@@ -142,21 +318,43 @@ func DeleteFileHandler(c *gin.Context) {
 
 // ListFilesHandler 获取文件列表接口
 // @Summary 获取文件列表
-// @Description 列出七牛云存储空间中的文件
+// @Description 列出七牛云存储空间中的文件。指定 delimiter（通常为 /）时按目录方式列举，
+// @Description 前缀之后包含 delimiter 的文件归并为目录，通过 common_prefixes 返回。
+// @Description 指定任一筛选或排序条件时在服务端跨页扫描，返回 next_token 用于继续获取，
+// @Description 续传令牌中包含筛选条件，继续获取时只需传入 token 和 limit
 // @Tags 文件管理
 // @Accept json
 // @Produce json
 // @Param prefix query string false "文件名前缀筛选条件"
+// @Param delimiter query string false "目录分隔符，例如 /，不能与筛选条件同时使用"
 // @Param marker query string false "游标，继续从上次读取的标记处开始列出"
 // @Param limit query int false "每次列举的最大文件数量 (1-1000)"
-// @Success 200 {object} map[string]interface{} "文件列表获取成功，返回文件信息及下一页游标"
+// @Param minSize query int false "最小文件大小（字节）"
+// @Param maxSize query int false "最大文件大小（字节）"
+// @Param modifiedAfter query string false "最后修改时间不早于该时间，RFC 3339 或 2006-01-02 格式"
+// @Param modifiedBefore query string false "最后修改时间早于该时间，RFC 3339 或 2006-01-02 格式"
+// @Param mimeType query string false "文件类型，例如 application/pdf 或 image/*"
+// @Param glob query string false "通配符，不包含 / 时匹配文件名，例如 *.pdf"
+// @Param regex query string false "匹配完整文件名的正则表达式"
+// @Param suffix query string false "文件名后缀，不区分大小写"
+// @Param sort query string false "排序字段 key、size 或 last_modified"
+// @Param order query string false "排序方向 asc 或 desc，默认 asc"
+// @Param scanBudget query int false "单次请求最多扫描的文件数量，不能超过 LIST_SCAN_BUDGET"
+// @Param token query string false "上一次筛选返回的 next_token"
+// @Param source query string false "数据来源 live（默认，直接列举存储空间）或 index（本地元数据索引）"
+// @Param bucket query string false "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
+// @Success 200 {object} map[string]interface{} "文件列表获取成功，返回文件信息、公共前缀及下一页游标"
+// @Failure 400 {object} map[string]interface{} "筛选条件或续传令牌无效"
+// @Failure 422 {object} map[string]interface{} "排序需要扫描的文件数量超过扫描预算"
 // @Failure 500 {object} map[string]interface{} "文件列表获取失败"
+// @Failure 503 {object} map[string]interface{} "索引尚未完成第一次全量同步"
 // @Router /api/v1/list [get]
 func ListFilesHandler(c *gin.Context) {
 	// 获取请求参数
-	prefix := c.DefaultQuery("prefix", "") // 文件前缀
-	marker := c.DefaultQuery("marker", "") // 游标，列举时继续读取上次的 marker
-	limit := 1000                          // 默认每次最多列举 1000 个文件
+	prefix := c.DefaultQuery("prefix", "")       // 文件前缀
+	delimiter := c.DefaultQuery("delimiter", "") // 目录分隔符，为空时不按目录归并
+	marker := c.DefaultQuery("marker", "")       // 游标，列举时继续读取上次的 marker
+	limit := 1000                                // 默认每次最多列举 1000 个文件
 	if c.Query("limit") != "" {
 		// 如果有指定 limit，转换为整数
 		parsedLimit, err := strconv.Atoi(c.Query("limit"))
@@ -165,11 +363,52 @@ func ListFilesHandler(c *gin.Context) {
 		}
 	}
 
-	// 初始化七牛云客户端
-	client := service.NewQiniuClient()
+	// 带续传令牌时筛选条件从令牌中恢复，否则从查询参数中解析
+	var cursor *service.ListCursor
+	if token := c.Query("token"); token != "" {
+		decoded, err := service.DecodeListCursor(token)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": http.StatusBadRequest,
+				"msg":  err.Error(),
+			})
+			return
+		}
+		cursor = decoded
+	} else {
+		filter, err := parseListFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": http.StatusBadRequest,
+				"msg":  err.Error(),
+			})
+			return
+		}
+		if filter.Active() {
+			cursor = &service.ListCursor{Filter: *filter}
+		}
+	}
+	if cursor != nil && delimiter != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "delimiter cannot be combined with filters",
+		})
+		return
+	}
+
+	// 直接列举存储后端或使用本地索引
+	lister, ok := listSource(c)
+	if !ok {
+		return
+	}
+
+	if cursor != nil {
+		listFilteredFiles(c, lister, cursor, limit)
+		return
+	}
 
 	// 获取文件列表
-	files, nextMarker, err := client.ListFiles(prefix, marker, limit)
+	files, commonPrefixes, nextMarker, err := lister.ListFiles(prefix, delimiter, marker, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": http.StatusInternalServerError,
@@ -178,37 +417,65 @@ func ListFilesHandler(c *gin.Context) {
 		return
 	}
 
-	// Map original file list to a limited field response
-	var limitedFiles []model.FileInfo
-	for _, file := range files {
-		limitedFiles = append(limitedFiles, model.FileInfo{
-			Key:           file.Key,
-			ContentLength: file.Fsize,
-			ETag:          file.Hash,
-			LastModified:  time.Unix(file.PutTime/1e7, 0).UTC(), // Convert timestamp to time.Time
+	// 返回文件列表、公共前缀和下一页游标
+	if commonPrefixes == nil {
+		commonPrefixes = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":            http.StatusOK,
+		"msg":             "文件列表获取成功",
+		"files":           files,
+		"common_prefixes": commonPrefixes,
+		"next_marker":     nextMarker,
+	})
+}
+
+// listFilteredFiles 按筛选条件跨页扫描并返回结果和续传令牌
+func listFilteredFiles(c *gin.Context, lister service.FileLister, cursor *service.ListCursor, limit int) {
+	budget := requestConfig(c).ListScanBudget
+	if value, err := strconv.Atoi(c.Query("scanBudget")); err == nil && value > 0 && value < budget {
+		budget = value
+	}
+
+	result, err := service.FilterFiles(lister, cursor, limit, budget)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrScanBudgetExceeded) {
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, gin.H{
+			"code": status,
+			"msg":  "Error getting file list: " + err.Error(),
 		})
+		return
 	}
 
-	// 返回文件列表和下一页游标
+	nextToken := ""
+	if result.Next != nil {
+		nextToken = service.EncodeListCursor(result.Next)
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"code":        http.StatusOK,
-		"msg":         "文件列表获取成功",
-		"files":       limitedFiles,
-		"next_marker": nextMarker,
+		"code":       http.StatusOK,
+		"msg":        "文件列表获取成功",
+		"files":      result.Files,
+		"scanned":    result.Scanned,
+		"next_token": nextToken,
 	})
 }
 
 // CopyFileHandler 复制文件接口
 // @Summary 复制文件
-// @Description 将七牛云存储空间中的文件从一个位置复制到另一个位置
+// @Description 将七牛云存储空间中的文件复制到同一存储空间或另一个已配置的存储空间中
 // @Tags 文件管理
 // @Accept json
 // @Produce json
 // @Param srcObject query string true "源文件名"
 // @Param destObject query string true "目标文件名"
 // @Param force query bool false "是否强制覆盖目标文件（true/false，默认为 false）"
+// @Param bucket query string false "源存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
+// @Param destBucket query string false "目标存储空间，默认与源存储空间相同"
 // @Success 200 {object} map[string]interface{} "文件复制成功"
-// @Failure 400 {object} map[string]interface{} "srcKey、destKey 缺失或 force 参数无效"
+// @Failure 400 {object} map[string]interface{} "srcKey、destKey 缺失、force 参数无效或存储空间未配置"
 // @Failure 500 {object} map[string]interface{} "文件复制失败"
 // @Router /api/v1/copy [post]
 func CopyFileHandler(c *gin.Context) {
@@ -235,14 +502,22 @@ func CopyFileHandler(c *gin.Context) {
 		return
 	}
 
-	// 初始化七牛云客户端
-	client := service.NewQiniuClient()
+	// 初始化源和目标存储空间
+	client, dest, ok := transferStorages(c)
+	if !ok {
+		return
+	}
 
-	// 执行复制操作
-	err = client.Copy(srcKey, destKey, force)
+	// 执行复制操作，目标为其他存储空间时跨存储空间复制
+	if dest == nil {
+		err = client.Copy(srcKey, destKey, force)
+	} else {
+		err = service.TransferObject(client, dest, srcKey, destKey, force, false)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code": http.StatusInternalServerError,
+		status := storageErrorStatus(err)
+		c.JSON(status, gin.H{
+			"code": status,
 			"msg":  "failed to copy file: " + err.Error(),
 		})
 		return
@@ -257,15 +532,17 @@ func CopyFileHandler(c *gin.Context) {
 
 // MoveFileHandler 移动文件接口
 // @Summary 移动文件
-// @Description 将七牛云存储空间中的文件从一个位置移动到另一个位置
+// @Description 将七牛云存储空间中的文件移动到同一存储空间或另一个已配置的存储空间中
 // @Tags 文件管理
 // @Accept json
 // @Produce json
 // @Param srcObject query string true "源文件名"
 // @Param destObject query string true "目标文件名"
 // @Param force query bool false "是否强制覆盖目标文件（true/false，默认为 false）"
+// @Param bucket query string false "源存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
+// @Param destBucket query string false "目标存储空间，默认与源存储空间相同"
 // @Success 200 {object} map[string]interface{} "文件移动成功"
-// @Failure 400 {object} map[string]interface{} "srcKey、destKey 缺失或 force 参数无效"
+// @Failure 400 {object} map[string]interface{} "srcKey、destKey 缺失、force 参数无效或存储空间未配置"
 // @Failure 500 {object} map[string]interface{} "文件移动失败"
 // @Router /api/v1/move [post]
 func MoveFileHandler(c *gin.Context) {
@@ -292,14 +569,22 @@ func MoveFileHandler(c *gin.Context) {
 		return
 	}
 
-	// 初始化七牛云客户端
-	client := service.NewQiniuClient()
+	// 初始化源和目标存储空间
+	client, dest, ok := transferStorages(c)
+	if !ok {
+		return
+	}
 
-	// 执行移动操作
-	err = client.Move(srcKey, destKey, force)
+	// 执行移动操作，目标为其他存储空间时跨存储空间移动
+	if dest == nil {
+		err = client.Move(srcKey, destKey, force)
+	} else {
+		err = service.TransferObject(client, dest, srcKey, destKey, force, true)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code": http.StatusInternalServerError,
+		status := storageErrorStatus(err)
+		c.JSON(status, gin.H{
+			"code": status,
 			"msg":  "failed to move file: " + err.Error(),
 		})
 		return
@@ -311,3 +596,74 @@ func MoveFileHandler(c *gin.Context) {
 		"msg":  "文件移动成功",
 	})
 }
+
+// ProfileHeader 选择命名账号的请求头，值为 QINIU_PROFILES 中配置的名称
+const ProfileHeader = "X-Qiniu-Profile"
+
+// profileName 返回请求选择的命名账号，路由前缀 /api/v1/profiles/:profile 优先于请求头，都没有时使用默认账号
+func profileName(c *gin.Context) string {
+	if profile := c.Param("profile"); profile != "" {
+		return profile
+	}
+	return c.GetHeader(ProfileHeader)
+}
+
+// newStorage 创建请求所选账号默认存储空间的存储后端，失败时直接写入错误响应
+func newStorage(c *gin.Context) (service.Storage, bool) {
+	return newBucketStorage(c, "")
+}
+
+// newBucketStorage 创建请求所选账号下 bucket 参数指定的存储空间的存储后端，name 为空时使用默认存储空间
+func newBucketStorage(c *gin.Context, name string) (service.Storage, bool) {
+	return newProfileStorage(c, profileName(c), name)
+}
+
+// newProfileStorage 创建 profile 账号下 bucket 存储空间的存储后端，用于使用创建任务时记录的账号和存储空间，
+// 失败时直接写入错误响应
+func newProfileStorage(c *gin.Context, profile, bucket string) (service.Storage, bool) {
+	storage, err := service.NewProfileStorage(requestConfig(c), profile, bucket)
+	if errors.Is(err, service.ErrUnknownBucket) || errors.Is(err, service.ErrUnknownProfile) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  err.Error(),
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "failed to initialize storage: " + err.Error(),
+		})
+		return nil, false
+	}
+	return storage, true
+}
+
+// transferStorages 创建复制、移动的源存储空间和目标存储空间，目标与源相同时 dest 为 nil
+func transferStorages(c *gin.Context) (src, dest service.Storage, ok bool) {
+	bucket := c.Query("bucket")
+	destBucket := c.DefaultQuery("destBucket", bucket)
+
+	if src, ok = newBucketStorage(c, bucket); !ok {
+		return nil, nil, false
+	}
+	if destBucket == bucket {
+		return src, nil, true
+	}
+	if dest, ok = newBucketStorage(c, destBucket); !ok {
+		return nil, nil, false
+	}
+	return src, dest, true
+}
+
+// storageErrorStatus 将存储后端的错误转换为 HTTP 状态码
+func storageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrObjectNotFound), errors.Is(err, service.ErrMultipartUploadNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrObjectExists):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
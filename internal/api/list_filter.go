@@ -0,0 +1,77 @@
+package api
+
+import (
+	"dooqiniu/internal/service"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseListFilter 从查询参数中解析列举的筛选和排序条件
+func parseListFilter(c *gin.Context) (*service.ListFilter, error) {
+	filter := &service.ListFilter{
+		Prefix:   c.Query("prefix"),
+		MimeType: c.Query("mimeType"),
+		Glob:     c.Query("glob"),
+		Regex:    c.Query("regex"),
+		Suffix:   c.Query("suffix"),
+		Sort:     c.Query("sort"),
+	}
+
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		return nil, fmt.Errorf("order must be asc or desc")
+	}
+
+	var err error
+	if filter.MinSize, err = querySize(c, "minSize"); err != nil {
+		return nil, err
+	}
+	if filter.MaxSize, err = querySize(c, "maxSize"); err != nil {
+		return nil, err
+	}
+	if filter.ModifiedAfter, err = queryTime(c, "modifiedAfter"); err != nil {
+		return nil, err
+	}
+	if filter.ModifiedBefore, err = queryTime(c, "modifiedBefore"); err != nil {
+		return nil, err
+	}
+
+	if err := filter.Compile(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// querySize 解析以字节为单位的大小参数，未设置时返回 nil
+func querySize(c *gin.Context, name string) (*int64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("invalid %s: %s", name, value)
+	}
+	return &size, nil
+}
+
+// queryTime 解析 RFC 3339 格式或 2006-01-02 格式（UTC 零点）的时间参数
+func queryTime(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s: %s", name, value)
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"dooqiniu/internal/model"
+	"dooqiniu/internal/service"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetObjectHandler 文件下载代理接口
+// @Summary 通过服务下载文件
+// @Description 由服务读取对象内容并流式返回，适用于无法访问七牛云 CDN 域名的客户端。
+// @Description 支持 Range 断点续传，以及以七牛云 hash 为 ETag 的 If-None-Match/If-Modified-Since 条件请求
+// @Tags 文件管理
+// @Produce octet-stream
+// @Param key path string true "对象名称"
+// @Param filename query string false "下载时保存的文件名，指定后以附件形式下载"
+// @Param inline query bool false "为 true 时在浏览器中直接打开，默认 false"
+// @Param Range header string false "字节范围，例如 bytes=0-1023"
+// @Param bucket query string false "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
+// @Success 200 {file} file "文件内容"
+// @Success 206 {file} file "部分文件内容"
+// @Success 304 "文件未修改"
+// @Failure 404 {object} map[string]interface{} "文件不存在"
+// @Failure 416 "Range 无效"
+// @Failure 500 {object} map[string]interface{} "读取文件失败"
+// @Router /api/v1/objects/{key} [get]
+func GetObjectHandler(c *gin.Context) {
+	objectName := strings.TrimPrefix(c.Param("key"), "/")
+	if objectName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "key is a required parameter",
+		})
+		return
+	}
+
+	// 初始化存储后端
+	client, ok := newBucketStorage(c, c.Query("bucket"))
+	if !ok {
+		return
+	}
+
+	info, err := client.Stat(objectName)
+	if err != nil {
+		c.JSON(storageErrorStatus(err), gin.H{
+			"code": storageErrorStatus(err),
+			"msg":  "failed to get file: " + err.Error(),
+		})
+		return
+	}
+
+	header := c.Writer.Header()
+	setObjectHeaders(header, info)
+	if disposition := contentDisposition(c); disposition != "" {
+		header.Set("Content-Disposition", disposition)
+	}
+
+	// Range 与条件请求由 http.ServeContent 处理，只读取实际需要的数据
+	reader := service.NewObjectReader(client, objectName, info.ContentLength)
+	defer reader.Close()
+	http.ServeContent(c.Writer, c.Request, "", info.LastModified, reader)
+}
+
+// HeadObjectHandler 获取文件元数据接口
+// @Summary 获取文件元数据
+// @Description 只返回响应头，不返回文件内容。除 Content-Length、Content-Type、ETag、Last-Modified 外，
+// @Description 还通过 X-Storage-Type、X-Object-Status、X-Restore-Status 返回存储类型、文件状态和解冻状态，
+// @Description 自定义元数据以 X-Qn-Meta-<name> 响应头返回
+// @Tags 文件管理
+// @Param key path string true "对象名称"
+// @Param bucket query string false "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
+// @Success 200 "文件存在"
+// @Success 304 "文件未修改"
+// @Failure 404 "文件不存在"
+// @Router /api/v1/objects/{key} [head]
+func HeadObjectHandler(c *gin.Context) {
+	// http.ServeContent 处理 HEAD 请求时不会读取文件内容
+	GetObjectHandler(c)
+}
+
+// StatHandler 获取文件信息接口
+// @Summary 获取文件信息
+// @Description 返回文件大小、hash、MIME 类型、上传时间、存储类型、文件状态、解冻状态和自定义元数据
+// @Tags 文件管理
+// @Produce json
+// @Param objectName query string true "文件名"
+// @Param bucket query string false "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
+// @Success 200 {object} map[string]interface{} "文件信息"
+// @Failure 400 {object} map[string]interface{} "缺少文件名"
+// @Failure 404 {object} map[string]interface{} "文件不存在"
+// @Failure 500 {object} map[string]interface{} "获取文件信息失败"
+// @Router /api/v1/stat [get]
+func StatHandler(c *gin.Context) {
+	objectName := c.Query("objectName")
+	if objectName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "objectName is a required parameter",
+		})
+		return
+	}
+
+	// 初始化存储后端
+	client, ok := newBucketStorage(c, c.Query("bucket"))
+	if !ok {
+		return
+	}
+
+	info, err := client.Stat(objectName)
+	if err != nil {
+		c.JSON(storageErrorStatus(err), gin.H{
+			"code": storageErrorStatus(err),
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": http.StatusOK,
+		"msg":  "获取文件信息成功",
+		"data": info,
+	})
+}
+
+// ChangeMetaRequest 修改文件元数据请求
+type ChangeMetaRequest struct {
+	ObjectName string `json:"objectName"`
+	// MimeType 新的 MIME 类型，为空时保持不变
+	MimeType string `json:"mimeType,omitempty"`
+	// MetaData 自定义元数据，键名可以省略 x-qn-meta- 前缀，合并模式下值为空字符串表示删除该键
+	MetaData map[string]string `json:"metadata,omitempty"`
+	// Replace 为 true 时用 metadata 替换全部自定义元数据，默认与原有元数据合并
+	Replace bool `json:"replace,omitempty"`
+}
+
+// ChangeMetaHandler 修改文件元数据接口
+// @Summary 修改文件 MIME 类型和自定义元数据
+// @Description 修改已上传文件的 MIME 类型，并合并或替换 x-qn-meta-* 自定义元数据，成功后返回修改后的文件信息。
+// @Description 自定义元数据键名只能包含字母、数字、- 和 _，最长 50 个字符，所有键值总长度不超过 1024 字节
+// @Tags 文件管理
+// @Accept json
+// @Produce json
+// @Param request body ChangeMetaRequest true "修改内容"
+// @Param bucket query string false "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
+// @Success 200 {object} map[string]interface{} "修改成功，返回文件信息"
+// @Failure 400 {object} map[string]interface{} "请求格式错误、没有需要修改的内容或自定义元数据无效"
+// @Failure 404 {object} map[string]interface{} "文件不存在"
+// @Failure 500 {object} map[string]interface{} "修改失败"
+// @Router /api/v1/meta [post]
+func ChangeMetaHandler(c *gin.Context) {
+	var request ChangeMetaRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "invalid request body: " + err.Error(),
+		})
+		return
+	}
+	if request.ObjectName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "objectName is a required parameter",
+		})
+		return
+	}
+	if request.MimeType == "" && len(request.MetaData) == 0 && !request.Replace {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "mimeType or metadata is required",
+		})
+		return
+	}
+	metaData, err := service.NormalizeMetaData(request.MetaData)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	// 初始化存储后端
+	client, ok := newBucketStorage(c, c.Query("bucket"))
+	if !ok {
+		return
+	}
+
+	if err := client.ChangeMeta(request.ObjectName, request.MimeType, metaData, request.Replace); err != nil {
+		status := storageErrorStatus(err)
+		c.JSON(status, gin.H{
+			"code": status,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	info, err := client.Stat(request.ObjectName)
+	if err != nil {
+		c.JSON(storageErrorStatus(err), gin.H{
+			"code": storageErrorStatus(err),
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": http.StatusOK,
+		"msg":  "修改文件元数据成功",
+		"data": info,
+	})
+}
+
+// setObjectHeaders 将文件信息写入响应头
+func setObjectHeaders(header http.Header, info *model.FileInfo) {
+	// 七牛云 hash 即内容摘要，可直接作为强 ETag
+	header.Set("ETag", `"`+info.ETag+`"`)
+	if info.MimeType != "" {
+		header.Set("Content-Type", info.MimeType)
+	} else {
+		header.Set("Content-Type", "application/octet-stream")
+	}
+	header.Set("X-Storage-Type", strconv.Itoa(info.StorageType))
+	header.Set("X-Object-Status", strconv.Itoa(info.Status))
+	if info.RestoreStatus != 0 {
+		header.Set("X-Restore-Status", strconv.Itoa(info.RestoreStatus))
+	}
+	for name, value := range info.MetaData {
+		header.Set("X-Qn-Meta-"+name, value)
+	}
+}
+
+// contentDisposition 根据 filename 和 inline 参数生成 Content-Disposition，
+// 非 ASCII 文件名按 RFC 2231 编码
+func contentDisposition(c *gin.Context) string {
+	filename := c.Query("filename")
+	inline := c.Query("inline") == "true"
+
+	dispositionType := "attachment"
+	if inline {
+		dispositionType = "inline"
+	}
+	if filename == "" {
+		if !inline {
+			return ""
+		}
+		return dispositionType
+	}
+	return mime.FormatMediaType(dispositionType, map[string]string{"filename": filename})
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"dooqiniu/internal/service"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeletePrefixHandler 按前缀删除接口
+// @Summary 按前缀删除文件
+// @Description 先以 dryRun=true 预览前缀下的文件数量和总大小并获取确认令牌，再携带 confirmToken 执行删除。
+// @Description 执行删除时以 NDJSON 流式返回进度，每删除一批文件输出一行，最后一行 done 为 true。
+// @Description 空前缀（整个存储桶）默认被拒绝，需要配置 ALLOW_ROOT_PREFIX_DELETE=true
+// @Tags 文件管理
+// @Produce json
+// @Produce application/x-ndjson
+// @Param prefix query string true "要删除的文件名前缀，例如 tmp/2024-10/"
+// @Param bucket query string false "命名存储空间，默认使用账号的默认存储空间"
+// @Param dryRun query bool false "为 true 时只统计不删除，返回确认令牌"
+// @Param confirmToken query string false "预览返回的确认令牌，10 分钟内有效且只能使用一次，账号、存储空间和前缀需要与预览时相同"
+// @Success 200 {object} map[string]interface{} "预览结果或删除进度"
+// @Failure 400 {object} map[string]interface{} "前缀无效或缺少确认令牌"
+// @Failure 403 {object} map[string]interface{} "不允许删除整个存储桶"
+// @Failure 409 {object} map[string]interface{} "确认令牌无效或已过期"
+// @Failure 500 {object} map[string]interface{} "删除失败"
+// @Router /api/v1/prefix [delete]
+func DeletePrefixHandler(c *gin.Context) {
+	prefix := c.Query("prefix")
+	bucket := c.Query("bucket")
+	dryRun := c.Query("dryRun") == "true"
+	confirmToken := c.Query("confirmToken")
+
+	// 空前缀和 "/" 都会匹配整个存储桶
+	if strings.Trim(prefix, "/") == "" && !requestConfig(c).AllowRootPrefixDelete {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code": http.StatusForbidden,
+			"msg":  "deleting the whole bucket is not allowed, set ALLOW_ROOT_PREFIX_DELETE=true to enable it",
+		})
+		return
+	}
+	if !dryRun && confirmToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "confirmToken is required, run with dryRun=true first",
+		})
+		return
+	}
+
+	// 初始化存储后端
+	client, ok := newBucketStorage(c, bucket)
+	if !ok {
+		return
+	}
+	target := service.PrefixDeleteTarget{Operation: service.PrefixDeleteOpDelete, Profile: profileName(c), Bucket: bucket, Prefix: prefix}
+
+	if dryRun {
+		plan, err := service.PlanPrefixDelete(client, target)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code": http.StatusInternalServerError,
+				"msg":  "failed to list files: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"code": http.StatusOK,
+			"msg":  "预览成功，使用 confirmToken 执行删除",
+			"data": plan,
+		})
+		return
+	}
+
+	limit, err := service.ConsumePrefixDeleteToken(target, confirmToken)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrPrefixDeleteTokenInvalid) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"code": status,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	// 删除可能持续较长时间，以 NDJSON 流式返回进度
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+
+	// 客户端断开连接后停止删除，预览之后新增的对象不会被删除
+	result, err := service.DeletePrefix(c.Request.Context(), client, prefix, limit, func(progress service.PrefixDeleteProgress) {
+		encoder.Encode(progress)
+		c.Writer.Flush()
+	})
+
+	summary := gin.H{
+		"done":    true,
+		"prefix":  prefix,
+		"deleted": result.Deleted,
+		"failed":  result.Failed,
+	}
+	if len(result.Errors) > 0 {
+		summary["errors"] = result.Errors
+	}
+	if err != nil {
+		summary["error"] = err.Error()
+	}
+	encoder.Encode(summary)
+}
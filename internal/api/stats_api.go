@@ -0,0 +1,120 @@
+package api
+
+import (
+	"dooqiniu/internal/service"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatsHandler 存储用量统计接口
+// @Summary 获取存储用量统计
+// @Description 统计前缀下的对象数量和总大小，并按第一级目录、文件类型、存储类型和最后修改时间分组。
+// @Description 默认返回 STATS_CACHE_TTL 内的最近一次快照，refresh=true 时重新统计，每次统计都会保存为快照
+// @Tags 用量统计
+// @Produce json
+// @Param prefix query string false "统计的文件名前缀，留空统计整个存储空间"
+// @Param source query string false "数据来源 live（默认，直接列举存储空间）或 index（本地元数据索引）"
+// @Param refresh query bool false "为 true 时忽略缓存的快照重新统计"
+// @Param bucket query string false "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
+// @Success 200 {object} map[string]interface{} "用量统计，cached 表示是否来自缓存的快照"
+// @Failure 400 {object} map[string]interface{} "数据来源无效或未启用索引"
+// @Failure 500 {object} map[string]interface{} "统计失败"
+// @Failure 503 {object} map[string]interface{} "索引尚未完成第一次全量同步"
+// @Router /api/v1/stats [get]
+func StatsHandler(c *gin.Context) {
+	scope := service.StatsScope{Profile: profileName(c), Bucket: c.Query("bucket"), Prefix: c.Query("prefix")}
+	cfg := requestConfig(c)
+	store := service.NewStatsStore(cfg.StatsDir)
+
+	lister, ok := listSource(c)
+	if !ok {
+		return
+	}
+
+	if c.Query("refresh") != "true" {
+		latest, err := store.Latest(scope)
+		if err == nil && latest != nil && time.Since(latest.GeneratedAt) < cfg.StatsCacheTTL {
+			c.JSON(http.StatusOK, gin.H{
+				"code":   http.StatusOK,
+				"msg":    "获取用量统计成功",
+				"cached": true,
+				"data":   latest,
+			})
+			return
+		}
+	}
+
+	stats, err := service.ComputeUsageStats(lister, c.DefaultQuery("source", sourceLive), scope)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "failed to compute stats: " + err.Error(),
+		})
+		return
+	}
+	if err := store.Append(stats); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":   http.StatusOK,
+		"msg":    "获取用量统计成功",
+		"cached": false,
+		"data":   stats,
+	})
+}
+
+// StatsHistoryHandler 用量统计历史接口
+// @Summary 获取用量统计历史
+// @Description 按时间顺序返回保存在本地的用量统计快照，用于绘制增长趋势
+// @Tags 用量统计
+// @Produce json
+// @Param prefix query string false "统计的文件名前缀，与生成快照时一致"
+// @Param since query string false "起始时间（含），RFC 3339 或 2006-01-02 格式"
+// @Param until query string false "结束时间（不含），RFC 3339 或 2006-01-02 格式"
+// @Param bucket query string false "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
+// @Success 200 {object} map[string]interface{} "快照列表"
+// @Failure 400 {object} map[string]interface{} "时间格式无效"
+// @Failure 500 {object} map[string]interface{} "读取快照失败"
+// @Router /api/v1/stats/history [get]
+func StatsHistoryHandler(c *gin.Context) {
+	since, err := queryTime(c, "since")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	until, err := queryTime(c, "until")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	scope := service.StatsScope{Profile: profileName(c), Bucket: c.Query("bucket"), Prefix: c.Query("prefix")}
+	store := service.NewStatsStore(requestConfig(c).StatsDir)
+	snapshots, err := store.History(scope, since, until)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": http.StatusOK,
+		"msg":  "获取用量统计历史成功",
+		"data": snapshots,
+	})
+}
@@ -0,0 +1,304 @@
+package api
+
+import (
+	"dooqiniu/internal/model"
+	"dooqiniu/internal/service"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ObjectTarget 操作的对象，objectNames 不为空时只处理其中的对象，否则处理 prefix 前缀下的所有对象
+type ObjectTarget struct {
+	ObjectNames []string `json:"objectNames,omitempty"`
+	Prefix      string   `json:"prefix,omitempty"`
+}
+
+// ChangeTypeRequest 修改存储类型请求
+type ChangeTypeRequest struct {
+	ObjectTarget
+	// Type 目标存储类型：0 标准存储，1 低频存储，2 归档存储，3 深度归档存储，4 归档直读存储
+	Type int `json:"type"`
+}
+
+// RestoreRequest 解冻归档文件请求
+type RestoreRequest struct {
+	ObjectTarget
+	// FreezeAfterDays 解冻后的有效天数，1 到 7 天，到期后重新冻结
+	FreezeAfterDays int `json:"freezeAfterDays"`
+}
+
+// LifecycleRequest 设置生命周期请求
+type LifecycleRequest struct {
+	ObjectTarget
+	model.LifecycleRule
+	// DryRun 与 ConfirmToken 用于按前缀设置删除规则，与按前缀删除相同，需要先预览再携带确认令牌执行
+	DryRun       bool   `json:"dryRun,omitempty"`
+	ConfirmToken string `json:"confirmToken,omitempty"`
+}
+
+// ChangeTypeHandler 修改存储类型接口
+// @Summary 修改文件存储类型
+// @Description 将指定文件或前缀下的所有文件转换为标准、低频、归档、深度归档或归档直读存储，七牛云后端使用批量接口执行
+// @Tags 存储类型
+// @Accept json
+// @Produce json
+// @Param request body ChangeTypeRequest true "目标文件和存储类型"
+// @Param bucket query string false "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
+// @Success 200 {object} map[string]interface{} "执行完成，返回成功和失败的数量"
+// @Failure 400 {object} map[string]interface{} "请求格式错误、未指定文件或存储类型无效"
+// @Failure 500 {object} map[string]interface{} "修改失败"
+// @Router /api/v1/chtype [post]
+func ChangeTypeHandler(c *gin.Context) {
+	var request ChangeTypeRequest
+	if !bindObjectTarget(c, &request, &request.ObjectTarget) {
+		return
+	}
+	if err := service.ValidateStorageType(request.Type); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	applyToObjects(c, request.ObjectTarget, "修改存储类型完成", func(objectName string) model.BatchOperation {
+		return model.BatchOperation{Op: model.BatchOpChtype, ObjectName: objectName, Type: request.Type}
+	})
+}
+
+// RestoreHandler 解冻归档文件接口
+// @Summary 解冻归档文件
+// @Description 对归档存储或深度归档存储的文件发起解冻，解冻通常需要数分钟到数小时，进度通过 GET /api/v1/restore 查看
+// @Tags 存储类型
+// @Accept json
+// @Produce json
+// @Param request body RestoreRequest true "目标文件和解冻有效期"
+// @Param bucket query string false "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
+// @Success 200 {object} map[string]interface{} "执行完成，返回成功和失败的数量"
+// @Failure 400 {object} map[string]interface{} "请求格式错误、未指定文件或有效期无效"
+// @Failure 500 {object} map[string]interface{} "解冻失败"
+// @Router /api/v1/restore [post]
+func RestoreHandler(c *gin.Context) {
+	var request RestoreRequest
+	if !bindObjectTarget(c, &request, &request.ObjectTarget) {
+		return
+	}
+	if err := service.ValidateFreezeAfterDays(request.FreezeAfterDays); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	applyToObjects(c, request.ObjectTarget, "解冻请求已提交", func(objectName string) model.BatchOperation {
+		return model.BatchOperation{Op: model.BatchOpRestore, ObjectName: objectName, FreezeAfterDays: request.FreezeAfterDays}
+	})
+}
+
+// RestoreStatusHandler 查询解冻状态接口
+// @Summary 查询归档文件的解冻状态
+// @Description restore_state 为 not_archived（非归档文件）、frozen（冻结）、restoring（解冻中）或 restored（已解冻）
+// @Tags 存储类型
+// @Produce json
+// @Param objectName query string true "文件名"
+// @Param bucket query string false "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
+// @Success 200 {object} map[string]interface{} "解冻状态"
+// @Failure 400 {object} map[string]interface{} "缺少文件名"
+// @Failure 404 {object} map[string]interface{} "文件不存在"
+// @Failure 500 {object} map[string]interface{} "查询失败"
+// @Router /api/v1/restore [get]
+func RestoreStatusHandler(c *gin.Context) {
+	objectName := c.Query("objectName")
+	if objectName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "objectName is a required parameter",
+		})
+		return
+	}
+
+	// 初始化存储后端
+	client, ok := newBucketStorage(c, c.Query("bucket"))
+	if !ok {
+		return
+	}
+
+	info, err := client.Stat(objectName)
+	if err != nil {
+		c.JSON(storageErrorStatus(err), gin.H{
+			"code": storageErrorStatus(err),
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": http.StatusOK,
+		"msg":  "获取解冻状态成功",
+		"data": gin.H{
+			"key":            info.Key,
+			"storage_type":   info.StorageType,
+			"restore_status": info.RestoreStatus,
+			"restore_state":  service.RestoreState(info),
+		},
+	})
+}
+
+// LifecycleHandler 设置生命周期接口
+// @Summary 设置文件生命周期
+// @Description 为指定文件或前缀下的所有文件设置上传后多少天转为低频、归档直读、归档、深度归档存储或删除。
+// @Description 天数为 0 的规则保持不变，为 -1 时取消已设置的规则。
+// @Description 按前缀设置 deleteAfterDays 会删除前缀下的所有文件，与按前缀删除相同，需要先以 dryRun=true 预览并获取确认令牌，再携带 confirmToken 执行
+// @Tags 存储类型
+// @Accept json
+// @Produce json
+// @Param request body LifecycleRequest true "目标文件和生命周期规则"
+// @Param bucket query string false "存储空间，QINIU_BUCKETS 中配置的名称，默认使用 QINIU_BUCKET"
+// @Success 200 {object} map[string]interface{} "执行完成，返回成功和失败的数量；dryRun 时返回预览结果"
+// @Failure 400 {object} map[string]interface{} "请求格式错误、未指定文件、规则无效或缺少确认令牌"
+// @Failure 403 {object} map[string]interface{} "不允许删除整个存储桶"
+// @Failure 409 {object} map[string]interface{} "确认令牌无效或已过期"
+// @Failure 500 {object} map[string]interface{} "设置失败"
+// @Router /api/v1/lifecycle [post]
+func LifecycleHandler(c *gin.Context) {
+	var request LifecycleRequest
+	if !bindObjectTarget(c, &request, &request.ObjectTarget) {
+		return
+	}
+	if err := service.ValidateLifecycleRule(request.LifecycleRule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	// 按前缀设置删除规则等同于按前缀删除，使用相同的预览和确认令牌流程
+	if len(request.ObjectNames) == 0 && request.DeleteAfterDays > 0 && !confirmLifecycleDelete(c, request) {
+		return
+	}
+
+	rule := request.LifecycleRule
+	applyToObjects(c, request.ObjectTarget, "设置生命周期完成", func(objectName string) model.BatchOperation {
+		return model.BatchOperation{Op: model.BatchOpLifecycle, ObjectName: objectName, Lifecycle: &rule}
+	})
+}
+
+// confirmLifecycleDelete 校验按前缀设置删除规则的确认令牌，dryRun 时返回预览结果。返回 false 时已写入响应
+func confirmLifecycleDelete(c *gin.Context, request LifecycleRequest) bool {
+	if strings.Trim(request.Prefix, "/") == "" && !requestConfig(c).AllowRootPrefixDelete {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code": http.StatusForbidden,
+			"msg":  "deleting the whole bucket is not allowed, set ALLOW_ROOT_PREFIX_DELETE=true to enable it",
+		})
+		return false
+	}
+	if !request.DryRun && request.ConfirmToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "confirmToken is required for deleteAfterDays on a prefix, run with dryRun=true first",
+		})
+		return false
+	}
+	bucket := c.Query("bucket")
+	target := service.PrefixDeleteTarget{Operation: service.PrefixDeleteOpLifecycle, Profile: profileName(c), Bucket: bucket, Prefix: request.Prefix}
+
+	if request.DryRun {
+		// 初始化存储后端
+		client, ok := newBucketStorage(c, bucket)
+		if !ok {
+			return false
+		}
+		plan, err := service.PlanPrefixDelete(client, target)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code": http.StatusInternalServerError,
+				"msg":  "failed to list files: " + err.Error(),
+			})
+			return false
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"code": http.StatusOK,
+			"msg":  "预览成功，使用 confirmToken 设置删除规则",
+			"data": plan,
+		})
+		return false
+	}
+
+	if _, err := service.ConsumePrefixDeleteToken(target, request.ConfirmToken); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrPrefixDeleteTokenInvalid) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"code": status,
+			"msg":  err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// bindObjectTarget 解析请求体并校验操作对象，为避免误操作整个存储桶，按前缀处理时前缀不能为空
+func bindObjectTarget(c *gin.Context, request any, target *ObjectTarget) bool {
+	if err := c.ShouldBindJSON(request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "invalid request body: " + err.Error(),
+		})
+		return false
+	}
+	if len(target.ObjectNames) == 0 && target.Prefix == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "objectNames or prefix is required",
+		})
+		return false
+	}
+	if len(target.ObjectNames) > maxBatchOperations {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "too many objectNames",
+		})
+		return false
+	}
+	for _, objectName := range target.ObjectNames {
+		if objectName == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": http.StatusBadRequest,
+				"msg":  "objectNames must not contain empty names",
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// applyToObjects 对目标文件批量执行操作并返回汇总结果
+func applyToObjects(c *gin.Context, target ObjectTarget, msg string, newOp func(objectName string) model.BatchOperation) {
+	// 初始化存储后端
+	client, ok := newBucketStorage(c, c.Query("bucket"))
+	if !ok {
+		return
+	}
+
+	summary, err := service.ApplyToObjects(client, target.ObjectNames, target.Prefix, newOp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "batch operation failed: " + err.Error(),
+			"data": summary,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": http.StatusOK,
+		"msg":  msg,
+		"data": summary,
+	})
+}
@@ -0,0 +1,461 @@
+package api
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"dooqiniu/internal/model"
+	"dooqiniu/internal/service"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,checksum,expiration"
+	// 支持的 Upload-Checksum 算法
+	tusChecksumAlgorithms = "md5,sha1,sha256"
+	// tus 扩展定义的校验失败状态码
+	statusChecksumMismatch = 460
+)
+
+// TusHeaders tus 协议公共处理：校验 Tus-Resumable 版本并在响应中携带协议头
+func TusHeaders(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+
+	if c.Request.Method != http.MethodOptions && c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{
+			"code": http.StatusPreconditionFailed,
+			"msg":  "unsupported Tus-Resumable version",
+		})
+		return
+	}
+	c.Next()
+}
+
+// TusOptionsHandler tus 协议能力查询接口
+// @Summary tus 协议能力查询
+// @Description 返回服务端支持的 tus 版本、扩展及校验算法
+// @Tags tus 上传
+// @Success 204 "协议信息在响应头中返回"
+// @Router /api/v1/tus [options]
+func TusOptionsHandler(c *gin.Context) {
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Checksum-Algorithm", tusChecksumAlgorithms)
+	c.Status(http.StatusNoContent)
+}
+
+// TusCreateHandler tus 创建上传接口
+// @Summary 创建 tus 上传
+// @Description 根据 Upload-Length 和 Upload-Metadata 创建上传任务，metadata 中的 objectName（缺省为 filename）作为目标对象名称
+// @Tags tus 上传
+// @Param Tus-Resumable header string true "tus 协议版本 1.0.0"
+// @Param Upload-Length header int true "文件总大小"
+// @Param Upload-Metadata header string false "tus 元数据，支持 objectName、filename、filetype"
+// @Param bucket query string false "命名存储空间，上传完成后写入该存储空间"
+// @Success 201 "创建成功，Location 头为上传地址"
+// @Failure 400 {object} map[string]interface{} "Upload-Length 或 Upload-Metadata 无效"
+// @Failure 500 {object} map[string]interface{} "创建失败"
+// @Router /api/v1/tus [post]
+func TusCreateHandler(c *gin.Context) {
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "invalid Upload-Length header",
+		})
+		return
+	}
+
+	metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "invalid Upload-Metadata header: " + err.Error(),
+		})
+		return
+	}
+
+	upload := &model.TusUpload{
+		ObjectName:  firstNonEmpty(metadata["objectName"], metadata["filename"], metadata["name"]),
+		ContentType: firstNonEmpty(metadata["filetype"], metadata["contentType"], metadata["type"]),
+		Length:      length,
+		Metadata:    metadata,
+		Profile:     profileName(c),
+		Bucket:      c.Query("bucket"),
+		CreatedAt:   time.Now().UTC(),
+	}
+	if upload.ObjectName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "Upload-Metadata must contain objectName or filename",
+		})
+		return
+	}
+
+	// 创建时校验账号和存储空间，上传完成时使用任务中记录的账号和存储空间
+	if _, ok := newProfileStorage(c, upload.Profile, upload.Bucket); !ok {
+		return
+	}
+
+	store := service.NewTusStore(requestConfig(c))
+	// 顺便清理过期的上传任务
+	if err := store.RemoveExpired(); err != nil {
+		fmt.Println("failed to remove expired tus uploads:", err)
+	}
+	if err := store.Create(upload); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "failed to create upload: " + err.Error(),
+		})
+		return
+	}
+
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+upload.ID)
+
+	// 空文件无需 PATCH，创建时直接写入存储后端
+	if length == 0 {
+		if err := finishTusUpload(requestConfig(c), store, upload); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code": http.StatusInternalServerError,
+				"msg":  "upload failed: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	c.Header("Upload-Offset", "0")
+	c.Header("Upload-Expires", tusExpires(upload))
+	c.Status(http.StatusCreated)
+}
+
+// TusHeadHandler tus 查询上传偏移量接口
+// @Summary 查询 tus 上传偏移量
+// @Description 返回已接收的字节数，客户端据此继续上传
+// @Tags tus 上传
+// @Param Tus-Resumable header string true "tus 协议版本 1.0.0"
+// @Param id path string true "上传 ID"
+// @Success 200 "Upload-Offset 与 Upload-Length 在响应头中返回"
+// @Failure 404 "上传不存在、已过期或属于其他账号"
+// @Router /api/v1/tus/{id} [head]
+func TusHeadHandler(c *gin.Context) {
+	upload, offset, err := getTusUpload(c, service.NewTusStore(requestConfig(c)), c.Param("id"))
+	if errors.Is(err, service.ErrTusUploadNotFound) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Header("Upload-Expires", tusExpires(upload))
+	if len(upload.Metadata) > 0 {
+		c.Header("Upload-Metadata", formatTusMetadata(upload.Metadata))
+	}
+	c.Status(http.StatusOK)
+}
+
+// TusPatchHandler tus 追加数据接口
+// @Summary 上传 tus 分块
+// @Description 从 Upload-Offset 处追加数据，全部接收后写入七牛云并返回文件信息
+// @Tags tus 上传
+// @Accept application/offset+octet-stream
+// @Produce json
+// @Param Tus-Resumable header string true "tus 协议版本 1.0.0"
+// @Param Upload-Offset header int true "本次数据的起始偏移量"
+// @Param Upload-Checksum header string false "分块摘要，格式为 算法 Base64值"
+// @Param id path string true "上传 ID"
+// @Success 200 {object} map[string]interface{} "上传完成，返回文件信息"
+// @Success 204 "分块接收成功"
+// @Failure 400 {object} map[string]interface{} "请求头无效"
+// @Failure 404 {object} map[string]interface{} "上传不存在、已过期或属于其他账号"
+// @Failure 409 {object} map[string]interface{} "Upload-Offset 与已接收的偏移量不一致"
+// @Failure 415 {object} map[string]interface{} "Content-Type 无效"
+// @Failure 423 {object} map[string]interface{} "上传正在被其他请求写入"
+// @Failure 460 {object} map[string]interface{} "分块校验失败"
+// @Failure 500 {object} map[string]interface{} "上传失败"
+// @Router /api/v1/tus/{id} [patch]
+func TusPatchHandler(c *gin.Context) {
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"code": http.StatusUnsupportedMediaType,
+			"msg":  "Content-Type must be application/offset+octet-stream",
+		})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "invalid Upload-Offset header",
+		})
+		return
+	}
+
+	checksum, expected, err := parseTusChecksum(c.GetHeader("Upload-Checksum"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "invalid Upload-Checksum header: " + err.Error(),
+		})
+		return
+	}
+
+	store := service.NewTusStore(requestConfig(c))
+	id := c.Param("id")
+
+	unlock, err := store.Lock(id)
+	if err != nil {
+		c.JSON(http.StatusLocked, gin.H{
+			"code": http.StatusLocked,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	defer unlock()
+
+	upload, current, err := getTusUpload(c, store, id)
+	if errors.Is(err, service.ErrTusUploadNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": http.StatusNotFound,
+			"msg":  "upload not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "failed to load upload: " + err.Error(),
+		})
+		return
+	}
+	if offset != current {
+		c.JSON(http.StatusConflict, gin.H{
+			"code": http.StatusConflict,
+			"msg":  "Upload-Offset does not match the current offset " + strconv.FormatInt(current, 10),
+		})
+		return
+	}
+
+	if upload.Result == nil {
+		written, err := store.Append(id, offset, upload.Length-offset, c.Request.Body, checksum, expected)
+		if errors.Is(err, service.ErrTusChecksumMismatch) {
+			c.JSON(statusChecksumMismatch, gin.H{
+				"code": statusChecksumMismatch,
+				"msg":  "checksum mismatch",
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code": http.StatusInternalServerError,
+				"msg":  "failed to write chunk: " + err.Error(),
+			})
+			return
+		}
+		current = offset + written
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(current, 10))
+	if current < upload.Length {
+		c.Header("Upload-Expires", tusExpires(upload))
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	// 数据接收完整后写入七牛云，失败时客户端可在相同偏移量重发空 PATCH 重试
+	if upload.Result == nil {
+		if err := finishTusUpload(requestConfig(c), store, upload); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code": http.StatusInternalServerError,
+				"msg":  "upload failed: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": http.StatusOK,
+		"msg":  "上传成功",
+		"data": upload.Result,
+	})
+}
+
+// TusDeleteHandler tus 终止上传接口
+// @Summary 终止 tus 上传
+// @Description 删除上传任务及已接收的数据
+// @Tags tus 上传
+// @Param Tus-Resumable header string true "tus 协议版本 1.0.0"
+// @Param id path string true "上传 ID"
+// @Success 204 "上传已终止"
+// @Failure 404 {object} map[string]interface{} "上传不存在、已过期或属于其他账号"
+// @Failure 423 {object} map[string]interface{} "上传正在被其他请求写入"
+// @Failure 500 {object} map[string]interface{} "终止失败"
+// @Router /api/v1/tus/{id} [delete]
+func TusDeleteHandler(c *gin.Context) {
+	store := service.NewTusStore(requestConfig(c))
+	id := c.Param("id")
+
+	unlock, err := store.Lock(id)
+	if err != nil {
+		c.JSON(http.StatusLocked, gin.H{
+			"code": http.StatusLocked,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	defer unlock()
+
+	if _, _, err := getTusUpload(c, store, id); errors.Is(err, service.ErrTusUploadNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": http.StatusNotFound,
+			"msg":  "upload not found",
+		})
+		return
+	}
+
+	if err := store.Delete(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "failed to delete upload: " + err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// getTusUpload 读取上传任务，任务只能通过创建时选择的账号访问，其他账号视为不存在
+func getTusUpload(c *gin.Context, store *service.TusStore, id string) (*model.TusUpload, int64, error) {
+	upload, offset, err := store.Get(id)
+	if err == nil && upload.Profile != profileName(c) {
+		return nil, 0, service.ErrTusUploadNotFound
+	}
+	return upload, offset, err
+}
+
+// tusExpires 返回 tus expiration 扩展的 Upload-Expires 响应头
+func tusExpires(upload *model.TusUpload) string {
+	return upload.CreatedAt.Add(service.TusUploadTTL).Format(http.TimeFormat)
+}
+
+// finishTusUpload 将本地暂存的数据写入创建任务时选择的账号和存储空间并记录结果
+func finishTusUpload(cfg *model.Config, store *service.TusStore, upload *model.TusUpload) error {
+	file, err := store.Open(upload.ID)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// 初始化存储后端
+	client, err := service.NewProfileStorage(cfg, upload.Profile, upload.Bucket)
+	if err != nil {
+		return err
+	}
+
+	result, err := client.Upload(file, upload.ObjectName, upload.ContentType, nil)
+	if err != nil {
+		return err
+	}
+
+	upload.Result = result
+	if err := store.Save(upload); err != nil {
+		return err
+	}
+	return store.RemoveData(upload.ID)
+}
+
+// parseTusMetadata 解析 Upload-Metadata，格式为逗号分隔的 "key base64value"
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		switch len(fields) {
+		case 1:
+			metadata[fields[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			metadata[fields[0]] = string(value)
+		default:
+			return nil, errors.New("malformed key value pair")
+		}
+	}
+	return metadata, nil
+}
+
+// formatTusMetadata 将元数据编码为 Upload-Metadata 格式
+func formatTusMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if metadata[key] == "" {
+			pairs = append(pairs, key)
+			continue
+		}
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(metadata[key])))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// parseTusChecksum 解析 Upload-Checksum，格式为 "算法 base64摘要"，未携带时返回 nil
+func parseTusChecksum(header string) (hash.Hash, []byte, error) {
+	if header == "" {
+		return nil, nil, nil
+	}
+
+	fields := strings.Fields(header)
+	if len(fields) != 2 {
+		return nil, nil, errors.New("malformed checksum")
+	}
+	expected, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch fields[0] {
+	case "md5":
+		return md5.New(), expected, nil
+	case "sha1":
+		return sha1.New(), expected, nil
+	case "sha256":
+		return sha256.New(), expected, nil
+	default:
+		return nil, nil, errors.New("unsupported checksum algorithm " + fields[0])
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
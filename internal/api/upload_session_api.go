@@ -0,0 +1,393 @@
+package api
+
+import (
+	"crypto/md5"
+	"dooqiniu/internal/model"
+	"dooqiniu/internal/service"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 七牛云分片上传 v2 的分片编号范围
+	maxPartNumber = 10000
+	// 单个分片最大 1 GB
+	maxPartSize = 1 << 30
+)
+
+// InitiateUploadHandler 初始化分片上传会话接口
+// @Summary 初始化分片上传会话
+// @Description 创建七牛云分片上传任务并持久化会话，服务重启后可继续上传
+// @Tags 分片上传
+// @Accept json
+// @Produce json
+// @Param objectName query string true "目标对象名称"
+// @Param contentType query string false "文件 MIME 类型"
+// @Param bucket query string false "命名存储空间，会话之后的请求都使用该存储空间"
+// @Success 200 {object} map[string]interface{} "会话创建成功，返回会话信息"
+// @Failure 400 {object} map[string]interface{} "缺少必要参数 objectName"
+// @Failure 500 {object} map[string]interface{} "会话创建失败"
+// @Router /api/v1/uploads [post]
+func InitiateUploadHandler(c *gin.Context) {
+	objectName := c.Query("objectName")
+	if objectName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "objectName is a required parameter",
+		})
+		return
+	}
+
+	sessionID, err := service.NewUploadSessionID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "failed to create upload session: " + err.Error(),
+		})
+		return
+	}
+
+	// 初始化存储后端，账号和存储空间记录在会话中
+	bucket := c.Query("bucket")
+	client, ok := newBucketStorage(c, bucket)
+	if !ok {
+		return
+	}
+
+	uploadID, expireAt, err := client.InitiateMultipartUpload(objectName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "failed to create upload session: " + err.Error(),
+		})
+		return
+	}
+
+	session := &model.UploadSession{
+		ID:          sessionID,
+		ObjectName:  objectName,
+		UploadID:    uploadID,
+		ContentType: c.Query("contentType"),
+		Profile:     profileName(c),
+		Bucket:      bucket,
+		ExpireAt:    expireAt,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := service.NewUploadSessionStore(requestConfig(c)).Save(session); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "failed to create upload session: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": http.StatusOK,
+		"msg":  "上传会话创建成功",
+		"data": session,
+	})
+}
+
+// UploadPartHandler 上传分片接口
+// @Summary 上传分片
+// @Description 请求体为分片内容，同一分片编号可重复上传以覆盖之前的数据
+// @Tags 分片上传
+// @Accept application/octet-stream
+// @Produce json
+// @Param id path string true "会话 ID"
+// @Param n path int true "分片编号 (1-10000)"
+// @Success 200 {object} map[string]interface{} "分片上传成功，返回分片信息"
+// @Failure 400 {object} map[string]interface{} "分片编号无效或分片为空"
+// @Failure 404 {object} map[string]interface{} "会话不存在"
+// @Failure 500 {object} map[string]interface{} "分片上传失败"
+// @Router /api/v1/uploads/{id}/parts/{n} [put]
+func UploadPartHandler(c *gin.Context) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	partNumber, err := strconv.ParseInt(c.Param("n"), 10, 64)
+	if err != nil || partNumber < 1 || partNumber > maxPartNumber {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "invalid part number",
+		})
+		return
+	}
+
+	// 分片上传需要可重复读取的数据，先落盘到临时文件并计算 MD5
+	part, size, partMD5, err := spoolPart(c)
+	if part != nil {
+		defer os.Remove(part.Name())
+		defer part.Close()
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"code": http.StatusRequestEntityTooLarge,
+			"msg":  "part exceeds the maximum part size",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "failed to read part: " + err.Error(),
+		})
+		return
+	}
+	if size == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "part is empty",
+		})
+		return
+	}
+
+	// 使用创建会话时的账号和存储空间
+	client, ok := newProfileStorage(c, session.Profile, session.Bucket)
+	if !ok {
+		return
+	}
+
+	etag, err := client.UploadPart(session.ObjectName, session.UploadID, partNumber, part, partMD5)
+	if err != nil {
+		status := storageErrorStatus(err)
+		c.JSON(status, gin.H{
+			"code": status,
+			"msg":  "failed to upload part: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": http.StatusOK,
+		"msg":  "分片上传成功",
+		"data": model.UploadedPart{
+			PartNumber:   partNumber,
+			ETag:         etag,
+			Size:         size,
+			LastModified: time.Now().UTC(),
+		},
+	})
+}
+
+// ListUploadPartsHandler 查询分片上传会话接口
+// @Summary 查询已上传的分片
+// @Description 返回会话信息及七牛云上已经上传成功的分片，用于断点续传
+// @Tags 分片上传
+// @Accept json
+// @Produce json
+// @Param id path string true "会话 ID"
+// @Success 200 {object} map[string]interface{} "查询成功，返回会话及分片列表"
+// @Failure 404 {object} map[string]interface{} "会话不存在"
+// @Failure 500 {object} map[string]interface{} "查询失败"
+// @Router /api/v1/uploads/{id} [get]
+func ListUploadPartsHandler(c *gin.Context) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	// 使用创建会话时的账号和存储空间
+	client, ok := newProfileStorage(c, session.Profile, session.Bucket)
+	if !ok {
+		return
+	}
+
+	parts, err := client.ListParts(session.ObjectName, session.UploadID)
+	if err != nil {
+		status := storageErrorStatus(err)
+		c.JSON(status, gin.H{
+			"code": status,
+			"msg":  "failed to list parts: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": http.StatusOK,
+		"msg":  "分片列表获取成功",
+		"data": gin.H{
+			"session": session,
+			"parts":   parts,
+		},
+	})
+}
+
+// CompleteUploadHandler 完成分片上传接口
+// @Summary 完成分片上传
+// @Description 按分片编号顺序合成文件，成功后删除会话
+// @Tags 分片上传
+// @Accept json
+// @Produce json
+// @Param id path string true "会话 ID"
+// @Success 200 {object} map[string]interface{} "上传成功，返回文件信息"
+// @Failure 400 {object} map[string]interface{} "没有已上传的分片"
+// @Failure 404 {object} map[string]interface{} "会话不存在"
+// @Failure 500 {object} map[string]interface{} "合成文件失败"
+// @Router /api/v1/uploads/{id}/complete [post]
+func CompleteUploadHandler(c *gin.Context) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	// 使用创建会话时的账号和存储空间
+	client, ok := newProfileStorage(c, session.Profile, session.Bucket)
+	if !ok {
+		return
+	}
+
+	// 以七牛云记录的分片为准，避免会话状态与实际上传结果不一致
+	parts, err := client.ListParts(session.ObjectName, session.UploadID)
+	if err != nil {
+		status := storageErrorStatus(err)
+		c.JSON(status, gin.H{
+			"code": status,
+			"msg":  "failed to list parts: " + err.Error(),
+		})
+		return
+	}
+	if len(parts) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "no parts have been uploaded",
+		})
+		return
+	}
+
+	uploadResponse, err := client.CompleteMultipartUpload(session.ObjectName, session.UploadID, session.ContentType, parts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "upload failed: " + err.Error(),
+		})
+		return
+	}
+
+	if err := service.NewUploadSessionStore(requestConfig(c)).Delete(session.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "failed to delete upload session: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": http.StatusOK,
+		"msg":  "上传成功",
+		"data": uploadResponse,
+	})
+}
+
+// AbortUploadHandler 终止分片上传接口
+// @Summary 终止分片上传
+// @Description 终止七牛云分片上传任务并删除会话
+// @Tags 分片上传
+// @Accept json
+// @Produce json
+// @Param id path string true "会话 ID"
+// @Success 200 {object} map[string]interface{} "上传已终止"
+// @Failure 404 {object} map[string]interface{} "会话不存在"
+// @Failure 500 {object} map[string]interface{} "终止失败"
+// @Router /api/v1/uploads/{id} [delete]
+func AbortUploadHandler(c *gin.Context) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	// 使用创建会话时的账号和存储空间
+	client, ok := newProfileStorage(c, session.Profile, session.Bucket)
+	if !ok {
+		return
+	}
+
+	if err := client.AbortMultipartUpload(session.ObjectName, session.UploadID); err != nil {
+		status := storageErrorStatus(err)
+		c.JSON(status, gin.H{
+			"code": status,
+			"msg":  "failed to abort upload: " + err.Error(),
+		})
+		return
+	}
+
+	if err := service.NewUploadSessionStore(requestConfig(c)).Delete(session.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "failed to delete upload session: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": http.StatusOK,
+		"msg":  "上传已终止",
+	})
+}
+
+// loadUploadSession 读取路径参数 id 对应的会话，失败时直接写入错误响应
+func loadUploadSession(c *gin.Context) (*model.UploadSession, bool) {
+	store := service.NewUploadSessionStore(requestConfig(c))
+
+	session, err := store.Get(c.Param("id"))
+	// 会话只能通过创建时选择的账号访问
+	if err == nil && session.Profile != profileName(c) {
+		err = service.ErrUploadSessionNotFound
+	}
+	if errors.Is(err, service.ErrUploadSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": http.StatusNotFound,
+			"msg":  "upload session not found",
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": http.StatusInternalServerError,
+			"msg":  "failed to load upload session: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	// uploadId 过期后七牛云会清理分片，会话也随之失效
+	if time.Now().After(session.ExpireAt) {
+		_ = store.Delete(session.ID)
+		c.JSON(http.StatusGone, gin.H{
+			"code": http.StatusGone,
+			"msg":  "upload session expired",
+		})
+		return nil, false
+	}
+
+	return session, true
+}
+
+// spoolPart 将请求体写入临时文件，返回定位到开头的文件、分片大小和十六进制 MD5
+func spoolPart(c *gin.Context) (*os.File, int64, string, error) {
+	file, err := os.CreateTemp("", "dooqiniu-part-*")
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	hash := md5.New()
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxPartSize)
+	size, err := io.Copy(io.MultiWriter(file, hash), body)
+	if err != nil {
+		return file, 0, "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return file, 0, "", err
+	}
+
+	return file, size, hex.EncodeToString(hash.Sum(nil)), nil
+}
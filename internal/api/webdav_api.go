@@ -0,0 +1,64 @@
+package api
+
+import (
+	"dooqiniu/internal/service"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/webdav"
+)
+
+// WebDAVPrefix WebDAV 的挂载路径
+const WebDAVPrefix = "/webdav"
+
+// WebDAVMethods WebDAV 客户端会用到的 HTTP 方法
+var WebDAVMethods = []string{
+	http.MethodOptions, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete,
+	"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK",
+}
+
+// 锁需要在请求之间共享，整个进程使用同一个锁管理器
+var webdavLockSystem = webdav.NewMemLS()
+
+// WebDAVHandler WebDAV 接口，可在 Finder/资源管理器中将存储桶挂载为网络磁盘
+// @Summary WebDAV 访问
+// @Description 支持 PROPFIND、GET、PUT、DELETE、MKCOL、COPY、MOVE、LOCK 等 WebDAV 方法，目录以 "/" 分隔的前缀表示
+// @Tags WebDAV
+// @Param path path string true "文件或目录路径"
+// @Failure 400 {object} map[string]interface{} "不支持 bucket 参数"
+// @Success 200 "按 WebDAV 协议返回"
+// @Router /webdav/{path} [get]
+func WebDAVHandler(c *gin.Context) {
+	// WebDAV 客户端不会在后续请求中带上查询参数，只支持默认存储空间，明确拒绝 bucket 参数而不是忽略
+	if c.Query("bucket") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": http.StatusBadRequest,
+			"msg":  "bucket parameter is not supported by WebDAV, only the default bucket can be mounted",
+		})
+		return
+	}
+
+	// 初始化存储后端
+	storage, ok := newStorage(c)
+	if !ok {
+		return
+	}
+
+	request := c.Request
+	if request.Method == http.MethodPut {
+		request = service.TrackWebDAVUpload(request)
+	}
+
+	handler := &webdav.Handler{
+		Prefix:     WebDAVPrefix,
+		FileSystem: service.NewWebDAVFileSystem(storage),
+		LockSystem: webdavLockSystem,
+		Logger: func(r *http.Request, err error) {
+			if err != nil {
+				log.Printf("webdav %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+	handler.ServeHTTP(c.Writer, request)
+}
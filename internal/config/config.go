@@ -2,17 +2,412 @@ package config
 
 import (
 	"dooqiniu/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/pflag"
 )
 
-// 从环境变量加载配置信息
-func LoadQiniuConfig() *model.Config {
-	return &model.Config{
-		Port:           os.Getenv("PORT"),            // 从环境变量读取端口
-		QiniuRegion:    os.Getenv("QINIU_REGION"),    // 从环境变量读取区域
-		QiniuEndpoint:  os.Getenv("QINIU_ENDPOINT"),  // 从环境变量读取 Endpoint
-		QiniuBucket:    os.Getenv("QINIU_BUCKET"),    // 从环境变量读取 Bucket
-		QiniuSecretId:  os.Getenv("QINIU_SECRETID"),  // 从环境变量读取 AccessKeyId
-		QiniuSecretKey: os.Getenv("QINIU_SECRETKEY"), // 从环境变量读取 AccessKeySecret
+// ConfigFileEnv 指定配置文件路径的环境变量，命令行参数 --config 优先
+const ConfigFileEnv = "DOOQINIU_CONFIG"
+
+// active 当前生效的配置版本，启动时由 Init 或 Set 设置，重新加载时原子替换
+var active atomic.Pointer[revision]
+
+// Current 返回当前生效的配置。启动时未设置时，只使用默认值和环境变量加载一次，忽略校验错误
+func Current() *model.Config {
+	if rev := active.Load(); rev != nil {
+		return rev.cfg
+	}
+	cfg, _ := Load("", nil)
+	active.CompareAndSwap(nil, newRevision(cfg, 1))
+	return active.Load().cfg
+}
+
+// Set 设置当前生效的配置，生成一个新的配置版本
+func Set(cfg *model.Config) {
+	swap(cfg)
+}
+
+// Init 加载并校验配置后设置为当前配置，并记录配置来源供重新加载使用
+func Init(path string, flags *pflag.FlagSet) error {
+	cfg, err := Load(path, flags)
+	if err != nil {
+		return err
+	}
+	reloadMu.Lock()
+	source = configSource{path: path, flags: flags}
+	reloadMu.Unlock()
+	Set(cfg)
+	return nil
+}
+
+// setting 一个配置项。配置文件中的键名为环境变量名的小写形式，
+// 命令行参数名再将下划线换成连字符，例如 QINIU_BUCKET、qiniu_bucket、--qiniu-bucket
+type setting struct {
+	env string
+	// aliases 兼容的旧环境变量名
+	aliases []string
+	def     string
+	// usage 不为空时注册为命令行参数，密钥等敏感配置不提供命令行参数
+	usage string
+	// secret 密钥类配置项，还可以从 <ENV>_FILE 指向的文件、密钥目录或密钥服务读取
+	secret bool
+	// bootstrap 密钥服务自身的配置，不从密钥服务读取
+	bootstrap bool
+	apply     func(cfg *model.Config, value string) error
+}
+
+func (s setting) key() string {
+	return strings.ToLower(s.env)
+}
+
+func (s setting) flagName() string {
+	return strings.ReplaceAll(s.key(), "_", "-")
+}
+
+// resolve 按 命令行参数 > 环境变量 > 配置文件 > 默认值 的优先级取值，overridden 表示值来自环境变量或命令行参数
+func (s setting) resolve(fileValues map[string]string, flags *pflag.FlagSet) (value string, overridden bool) {
+	value = s.def
+	if v, ok := fileValues[s.key()]; ok {
+		value = v
+	}
+	if v, ok := s.envValue(); ok {
+		value, overridden = v, true
+	}
+	if flags != nil && s.usage != "" {
+		if flag := flags.Lookup(s.flagName()); flag != nil && flag.Changed {
+			value, overridden = flag.Value.String(), true
+		}
+	}
+	return value, overridden
+}
+
+// envValue 读取环境变量，值为空等同于未设置
+func (s setting) envValue() (string, bool) {
+	for _, name := range append([]string{s.env}, s.aliases...) {
+		if value := os.Getenv(name); value != "" {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+var settings = []setting{
+	{env: "PORT", def: "9090", usage: "HTTP listen port", apply: func(cfg *model.Config, value string) error {
+		port, err := strconv.Atoi(value)
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("must be a port number between 1 and 65535")
+		}
+		cfg.Port = value
+		return nil
+	}},
+	{env: "STORAGE_BACKEND", def: "qiniu", usage: "storage backend: qiniu, local or memory", apply: func(cfg *model.Config, value string) error {
+		cfg.StorageBackend = value
+		return nil
+	}},
+	{env: "LOCAL_STORAGE_DIR", def: filepath.Join("data", "storage"), usage: "data directory of the local storage backend", apply: func(cfg *model.Config, value string) error {
+		cfg.LocalStorageDir = value
+		return nil
+	}},
+	{env: "QINIU_ACCESSKEY", aliases: []string{"QINIU_SECRETID"}, secret: true, apply: func(cfg *model.Config, value string) error {
+		cfg.QiniuAccessKey = value
+		return nil
+	}},
+	{env: "QINIU_SECRETKEY", secret: true, apply: func(cfg *model.Config, value string) error {
+		cfg.QiniuSecretKey = value
+		return nil
+	}},
+	{env: "QINIU_BUCKET", usage: "default Qiniu bucket", apply: func(cfg *model.Config, value string) error {
+		cfg.QiniuBucket = value
+		return nil
+	}},
+	{env: "QINIU_ENDPOINT", usage: "download domain of the default bucket", apply: func(cfg *model.Config, value string) error {
+		cfg.QiniuEndpoint = value
+		return nil
+	}},
+	{env: "QINIU_REGION", usage: "region ID of the default bucket, looked up automatically when empty", apply: func(cfg *model.Config, value string) error {
+		cfg.QiniuRegion = value
+		return nil
+	}},
+	{env: "QINIU_BUCKETS", apply: func(cfg *model.Config, value string) (err error) {
+		cfg.Buckets, err = ParseBucketProfiles(value)
+		return err
+	}},
+	{env: "QINIU_PROFILES", secret: true, apply: func(cfg *model.Config, value string) (err error) {
+		cfg.Profiles, err = ParseAccountProfiles(value)
+		return err
+	}},
+	{env: "S3_GATEWAY_ADDR", usage: "listen address of the S3 gateway, disabled when empty", apply: func(cfg *model.Config, value string) error {
+		cfg.S3GatewayAddr = value
+		return nil
+	}},
+	{env: "S3_BUCKET", usage: "bucket name exposed by the S3 gateway, defaults to QINIU_BUCKET", apply: func(cfg *model.Config, value string) error {
+		cfg.S3Bucket = value
+		return nil
+	}},
+	{env: "S3_REGION", def: "us-east-1", usage: "region exposed by the S3 gateway", apply: func(cfg *model.Config, value string) error {
+		cfg.S3Region = value
+		return nil
+	}},
+	{env: "S3_CREDENTIALS", secret: true, apply: func(cfg *model.Config, value string) (err error) {
+		cfg.S3Credentials, err = parseCredentials(value)
+		return err
+	}},
+	{env: "DOWNLOAD_URL_MAX_EXPIRES", def: "604800", usage: "maximum lifetime of private download URLs in seconds", apply: func(cfg *model.Config, value string) (err error) {
+		cfg.DownloadURLMaxExpires, err = parseSeconds(value, false)
+		return err
+	}},
+	{env: "ALLOW_ROOT_PREFIX_DELETE", def: "false", usage: "allow deleting by an empty prefix, i.e. the whole bucket", apply: func(cfg *model.Config, value string) (err error) {
+		cfg.AllowRootPrefixDelete, err = strconv.ParseBool(value)
+		return err
+	}},
+	{env: "LIST_SCAN_BUDGET", def: "100000", usage: "maximum objects scanned by a filtered list request", apply: func(cfg *model.Config, value string) (err error) {
+		cfg.ListScanBudget, err = parsePositiveInt(value)
+		return err
+	}},
+	{env: "INDEX_PATH", usage: "metadata index file, disabled when empty", apply: func(cfg *model.Config, value string) error {
+		cfg.IndexPath = value
+		return nil
+	}},
+	{env: "INDEX_RECONCILE_INTERVAL", def: "21600", usage: "metadata index reconcile interval in seconds", apply: func(cfg *model.Config, value string) (err error) {
+		cfg.IndexReconcileInterval, err = parseSeconds(value, false)
+		return err
+	}},
+	{env: "STATS_DIR", def: filepath.Join("data", "stats"), usage: "directory of usage stats snapshots", apply: func(cfg *model.Config, value string) error {
+		cfg.StatsDir = value
+		return nil
+	}},
+	{env: "STATS_CACHE_TTL", def: "3600", usage: "usage stats snapshot cache TTL in seconds", apply: func(cfg *model.Config, value string) (err error) {
+		cfg.StatsCacheTTL, err = parseSeconds(value, false)
+		return err
+	}},
+	{env: "STATS_SNAPSHOT_INTERVAL", def: "0", usage: "periodic usage stats snapshot interval in seconds, 0 to disable", apply: func(cfg *model.Config, value string) (err error) {
+		cfg.StatsSnapshotInterval, err = parseSeconds(value, true)
+		return err
+	}},
+	{env: "CONFIG_WATCH_INTERVAL", def: "10", usage: "config file change check interval in seconds, 0 to disable", apply: func(cfg *model.Config, value string) (err error) {
+		cfg.ConfigWatchInterval, err = parseSeconds(value, true)
+		return err
+	}},
+	{env: "SECRETS_DIR", def: "/run/secrets", usage: "directory of secret files named after config keys, e.g. Docker or Kubernetes secret mounts", apply: func(cfg *model.Config, value string) error {
+		cfg.SecretsDir = value
+		return nil
+	}},
+	{env: "SECRET_PROVIDER", usage: "external secret provider, e.g. vault, disabled when empty", apply: func(cfg *model.Config, value string) error {
+		cfg.SecretProvider = value
+		return nil
+	}},
+	{env: "VAULT_ADDR", usage: "Vault server address", apply: func(cfg *model.Config, value string) error {
+		cfg.VaultAddr = value
+		return nil
+	}},
+	{env: "VAULT_TOKEN", secret: true, bootstrap: true, apply: func(cfg *model.Config, value string) error {
+		cfg.VaultToken = value
+		return nil
+	}},
+	{env: "VAULT_SECRET_PATH", usage: "Vault API path of the secret, e.g. secret/data/dooqiniu for KV v2", apply: func(cfg *model.Config, value string) error {
+		cfg.VaultSecretPath = value
+		return nil
+	}},
+	{env: "VAULT_NAMESPACE", usage: "Vault Enterprise namespace", apply: func(cfg *model.Config, value string) error {
+		cfg.VaultNamespace = value
+		return nil
+	}},
+	{env: "UPLOAD_SESSION_DIR", def: filepath.Join("data", "upload_sessions"), usage: "directory of multipart upload sessions", apply: func(cfg *model.Config, value string) error {
+		cfg.UploadSessionDir = value
+		return nil
+	}},
+	{env: "TUS_UPLOAD_DIR", def: filepath.Join("data", "tus"), usage: "staging directory of tus uploads", apply: func(cfg *model.Config, value string) error {
+		cfg.TusUploadDir = value
+		return nil
+	}},
+}
+
+// RegisterFlags 在 flags 上注册所有可以通过命令行参数设置的配置项
+func RegisterFlags(flags *pflag.FlagSet) {
+	for _, s := range settings {
+		if s.usage != "" {
+			flags.String(s.flagName(), s.def, s.usage+" ("+s.env+")")
+		}
+	}
+}
+
+// Load 加载并校验配置，同一配置项的优先级为：命令行参数 > 环境变量 > 配置文件 > 默认值，
+// 密钥类配置项未通过命令行参数或环境变量设置时，再依次尝试 <ENV>_FILE、密钥目录和密钥服务。
+// path 为空时不读取配置文件，flags 为 nil 时不读取命令行参数。
+// 出错时仍返回尽量完整的配置，错误信息包含所有无效的配置项
+func Load(path string, flags *pflag.FlagSet) (*model.Config, error) {
+	var fileValues map[string]string
+	if path != "" {
+		var err error
+		if fileValues, err = readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	known := make(map[string]bool, len(settings))
+	values := make(map[string]string, len(settings))
+	var pending []setting
+	for _, s := range settings {
+		known[s.key()] = true
+		if s.secret {
+			known[s.key()+secretFileKeySuffix] = true
+		}
+
+		value, overridden := s.resolve(fileValues, flags)
+		values[s.env] = value
+		if !s.secret {
+			continue
+		}
+		if !overridden {
+			pending = append(pending, s)
+		} else if os.Getenv(s.env+secretFileEnvSuffix) != "" {
+			errs = append(errs, fmt.Errorf("both %s and %s%s are set", s.env, s.env, secretFileEnvSuffix))
+		}
+	}
+	for key := range fileValues {
+		if !known[key] {
+			errs = append(errs, fmt.Errorf("unknown key %q in config file %s", key, path))
+		}
+	}
+	if err := loadSecrets(pending, values, fileValues); err != nil {
+		errs = append(errs, err)
+	}
+
+	cfg := &model.Config{}
+	for _, s := range settings {
+		if err := s.apply(cfg, values[s.env]); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", s.env, err))
+		}
+	}
+
+	// 网关的桶名默认与七牛云存储空间相同
+	if cfg.S3Bucket == "" {
+		cfg.S3Bucket = cfg.QiniuBucket
+	}
+	if cfg.S3Bucket == "" {
+		cfg.S3Bucket = "dooqiniu"
+	}
+
+	errs = append(errs, Validate(cfg))
+	return cfg, errors.Join(errs...)
+}
+
+// Validate 校验配置项之间的依赖关系
+func Validate(cfg *model.Config) error {
+	var errs []error
+	switch cfg.StorageBackend {
+	case "qiniu":
+		for _, required := range []struct{ env, value string }{
+			{"QINIU_ACCESSKEY", cfg.QiniuAccessKey},
+			{"QINIU_SECRETKEY", cfg.QiniuSecretKey},
+			{"QINIU_BUCKET", cfg.QiniuBucket},
+		} {
+			if required.value == "" {
+				errs = append(errs, fmt.Errorf("%s is required when STORAGE_BACKEND is qiniu", required.env))
+			}
+		}
+	case "local", "memory":
+	default:
+		errs = append(errs, fmt.Errorf("invalid STORAGE_BACKEND: %s, must be qiniu, local or memory", cfg.StorageBackend))
+	}
+
+	if cfg.S3GatewayAddr != "" && len(cfg.S3Credentials) == 0 {
+		errs = append(errs, fmt.Errorf("S3_CREDENTIALS is required when S3_GATEWAY_ADDR is set"))
+	}
+	return errors.Join(errs...)
+}
+
+// ParseBucketProfiles 解析 JSON 格式的命名存储空间配置，例如
+// {"staging": {"bucket": "acme-staging", "endpoint": "https://staging.cdn.example.com", "region": "z0"}}
+func ParseBucketProfiles(value string) (map[string]model.BucketProfile, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var profiles map[string]model.BucketProfile
+	if err := json.Unmarshal([]byte(value), &profiles); err != nil {
+		return nil, err
+	}
+	for name, profile := range profiles {
+		if name == "" || profile.Bucket == "" {
+			return nil, fmt.Errorf("bucket profile %q must have a name and a bucket", name)
+		}
+	}
+	return profiles, nil
+}
+
+// ParseAccountProfiles 解析 JSON 格式的命名账号配置，例如
+// {"acme": {"accessKey": "...", "secretKey": "...", "bucket": "acme-assets", "endpoint": "https://cdn.acme.com",
+// "buckets": {"backup": {"bucket": "acme-backup", "endpoint": "https://backup.acme.com"}}}}
+func ParseAccountProfiles(value string) (map[string]model.AccountProfile, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var profiles map[string]model.AccountProfile
+	if err := json.Unmarshal([]byte(value), &profiles); err != nil {
+		return nil, err
+	}
+	for name, profile := range profiles {
+		if name == "" || profile.AccessKey == "" || profile.SecretKey == "" || profile.Bucket == "" {
+			return nil, fmt.Errorf("profile %q must have a name, accessKey, secretKey and bucket", name)
+		}
+		for bucketName, bucket := range profile.Buckets {
+			if bucketName == "" || bucket.Bucket == "" {
+				return nil, fmt.Errorf("bucket profile %q of profile %q must have a name and a bucket", bucketName, name)
+			}
+		}
+	}
+	return profiles, nil
+}
+
+// parseCredentials 解析逗号分隔的 AccessKey:SecretKey 列表，配置文件中也可以写成 AccessKey 到 SecretKey 的映射
+func parseCredentials(value string) (map[string]string, error) {
+	credentials := make(map[string]string)
+	if strings.HasPrefix(strings.TrimSpace(value), "{") {
+		if err := json.Unmarshal([]byte(value), &credentials); err != nil {
+			return nil, err
+		}
+		return credentials, nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		accessKey, secretKey, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || accessKey == "" || secretKey == "" {
+			return nil, fmt.Errorf("must be a comma-separated list of accessKey:secretKey")
+		}
+		credentials[accessKey] = secretKey
+	}
+	return credentials, nil
+}
+
+// parseSeconds 解析以秒为单位的时长，allowZero 为 true 时允许 0
+func parseSeconds(value string, allowZero bool) (time.Duration, error) {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 || (seconds == 0 && !allowZero) {
+		return 0, fmt.Errorf("must be a positive number of seconds")
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// parsePositiveInt 解析正整数
+func parsePositiveInt(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("must be a positive integer")
 	}
+	return n, nil
 }
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// readFile 读取 YAML 或 TOML 配置文件，返回键名到配置值的映射，
+// 对象形式的值（例如 qiniu_buckets）转换为与环境变量相同的 JSON 字符串
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var raw map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q, must be .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case nil:
+			values[key] = ""
+		case string:
+			values[key] = v
+		case map[string]any, []any:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of %q in config file %s: %w", key, path, err)
+			}
+			values[key] = string(data)
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return values, nil
+}
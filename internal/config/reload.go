@@ -0,0 +1,333 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"dooqiniu/internal/model"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	// drainTimeout 切换配置后等待旧配置上的请求完成的最长时间，超时后不再等待
+	drainTimeout = 10 * time.Minute
+	// drainPollInterval 检查旧配置上的请求是否完成的间隔
+	drainPollInterval = 100 * time.Millisecond
+)
+
+// 重新加载的触发方式
+const (
+	ReloadTriggerSignal = "SIGHUP"
+	ReloadTriggerFile   = "file"
+	ReloadTriggerAPI    = "api"
+)
+
+// Revision 配置版本信息，每次成功切换配置时 Number 加一
+type Revision struct {
+	Number int64 `json:"revision"`
+	// Checksum 配置内容的摘要，用于确认各实例的配置是否一致
+	Checksum string    `json:"checksum"`
+	LoadedAt time.Time `json:"loadedAt"`
+}
+
+// ReloadResult 一次重新加载的结果
+type ReloadResult struct {
+	At      time.Time `json:"at"`
+	Trigger string    `json:"trigger"`
+	// Changed 为 false 表示配置内容没有变化或加载失败，仍使用原来的版本
+	Changed bool   `json:"changed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Status 当前配置的状态
+type Status struct {
+	Revision
+	// Source 配置文件路径，没有使用配置文件时为空
+	Source string `json:"source,omitempty"`
+	// InFlight 使用当前版本处理中的请求数，Draining 为仍有请求在处理的旧版本的请求数
+	InFlight int64           `json:"inFlight"`
+	Draining map[int64]int64 `json:"draining,omitempty"`
+	// RestartRequired 已修改但需要重启才能生效的配置项
+	RestartRequired []string      `json:"restartRequired,omitempty"`
+	LastReload      *ReloadResult `json:"lastReload,omitempty"`
+}
+
+// revision 一个生效过的配置版本，inFlight 为使用该版本处理中的请求数
+type revision struct {
+	cfg      *model.Config
+	info     Revision
+	inFlight atomic.Int64
+}
+
+// configSource 重新加载时使用的配置来源，与启动时相同
+type configSource struct {
+	path  string
+	flags *pflag.FlagSet
+}
+
+var (
+	// reloadMu 保证同一时间只有一次重新加载，并保护下面的变量
+	reloadMu        sync.Mutex
+	source          configSource
+	reloadHooks     []func(old, cfg *model.Config)
+	lastReload      *ReloadResult
+	restartRequired []string
+	draining        = make(map[*revision]struct{})
+)
+
+// restartSettings 只在启动时生效的配置项，重新加载时保留原值，修改后需要重启
+var restartSettings = []struct {
+	env  string
+	keep func(old, cfg *model.Config) bool
+}{
+	{"PORT", func(old, cfg *model.Config) bool { return keep(&cfg.Port, old.Port) }},
+	{"STORAGE_BACKEND", func(old, cfg *model.Config) bool { return keep(&cfg.StorageBackend, old.StorageBackend) }},
+	{"S3_GATEWAY_ADDR", func(old, cfg *model.Config) bool { return keep(&cfg.S3GatewayAddr, old.S3GatewayAddr) }},
+	{"S3_BUCKET", func(old, cfg *model.Config) bool { return keep(&cfg.S3Bucket, old.S3Bucket) }},
+	{"S3_REGION", func(old, cfg *model.Config) bool { return keep(&cfg.S3Region, old.S3Region) }},
+	{"S3_CREDENTIALS", func(old, cfg *model.Config) bool {
+		changed := !maps.Equal(old.S3Credentials, cfg.S3Credentials)
+		cfg.S3Credentials = old.S3Credentials
+		return changed
+	}},
+	{"INDEX_PATH", func(old, cfg *model.Config) bool { return keep(&cfg.IndexPath, old.IndexPath) }},
+	{"INDEX_RECONCILE_INTERVAL", func(old, cfg *model.Config) bool {
+		return keep(&cfg.IndexReconcileInterval, old.IndexReconcileInterval)
+	}},
+	{"STATS_SNAPSHOT_INTERVAL", func(old, cfg *model.Config) bool {
+		return keep(&cfg.StatsSnapshotInterval, old.StatsSnapshotInterval)
+	}},
+	{"CONFIG_WATCH_INTERVAL", func(old, cfg *model.Config) bool {
+		return keep(&cfg.ConfigWatchInterval, old.ConfigWatchInterval)
+	}},
+}
+
+// keep 将 *field 恢复为 old，返回值是否不同
+func keep[T comparable](field *T, old T) bool {
+	changed := *field != old
+	*field = old
+	return changed
+}
+
+func newRevision(cfg *model.Config, number int64) *revision {
+	return &revision{
+		cfg: cfg,
+		info: Revision{
+			Number:   number,
+			Checksum: checksum(cfg),
+			LoadedAt: time.Now().UTC(),
+		},
+	}
+}
+
+// checksum 计算配置内容的摘要，只返回前 12 位，不会泄露密钥
+func checksum(cfg *model.Config) string {
+	data, _ := json.Marshal(cfg)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// swap 将 cfg 设置为新的配置版本，返回被替换的版本
+func swap(cfg *model.Config) (old, rev *revision) {
+	for {
+		old = active.Load()
+		number := int64(1)
+		if old != nil {
+			number = old.info.Number + 1
+		}
+		rev = newRevision(cfg, number)
+		if active.CompareAndSwap(old, rev) {
+			return old, rev
+		}
+	}
+}
+
+// Acquire 标记一个使用当前配置的请求开始，返回当前配置的版本、配置内容和请求结束时调用的函数。
+// 请求应在整个处理过程中使用返回的配置，而不是再次调用 Current。
+// 切换配置后，旧版本上的请求全部结束才会调用 OnReload 注册的回调
+func Acquire() (Revision, *model.Config, func()) {
+	Current()
+	for {
+		rev := active.Load()
+		rev.inFlight.Add(1)
+		// 计数前配置已被切换时，改为在新版本上计数
+		if active.Load() == rev {
+			return rev.info, rev.cfg, func() { rev.inFlight.Add(-1) }
+		}
+		rev.inFlight.Add(-1)
+	}
+}
+
+// contextKey 在 context 中保存请求所用配置的键
+type contextKey struct{}
+
+// NewContext 返回携带 cfg 的 context，请求处理过程中通过 FromContext 获取同一个配置版本
+func NewContext(ctx context.Context, cfg *model.Config) context.Context {
+	return context.WithValue(ctx, contextKey{}, cfg)
+}
+
+// FromContext 返回 ctx 携带的配置，没有时返回当前配置
+func FromContext(ctx context.Context) *model.Config {
+	if cfg, ok := ctx.Value(contextKey{}).(*model.Config); ok {
+		return cfg
+	}
+	return Current()
+}
+
+// OnReload 注册配置切换后的回调，在旧配置上的请求全部完成（或等待超时）后调用
+func OnReload(hook func(old, cfg *model.Config)) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	reloadHooks = append(reloadHooks, hook)
+}
+
+// CurrentStatus 返回当前配置的版本和重新加载的状态
+func CurrentStatus() Status {
+	Current()
+	rev := active.Load()
+
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	status := Status{
+		Revision:        rev.info,
+		Source:          source.path,
+		InFlight:        rev.inFlight.Load(),
+		RestartRequired: restartRequired,
+		LastReload:      lastReload,
+	}
+	if len(draining) > 0 {
+		status.Draining = make(map[int64]int64, len(draining))
+		for old := range draining {
+			status.Draining[old.info.Number] = old.inFlight.Load()
+		}
+	}
+	return status
+}
+
+// Reload 按启动时的来源重新加载配置，配置无效时继续使用原来的版本并返回错误。
+// 命令行参数在重新加载后仍然优先，只在启动时生效的配置项保留原值
+func Reload(trigger string) (Revision, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	Current()
+	old := active.Load()
+	result := &ReloadResult{At: time.Now().UTC(), Trigger: trigger}
+	lastReload = result
+
+	cfg, err := Load(source.path, source.flags)
+	if err != nil {
+		result.Error = err.Error()
+		fmt.Printf("Error reloading config (%s), keeping revision %d: %v\n", trigger, old.info.Number, err)
+		return old.info, err
+	}
+
+	restartRequired = nil
+	for _, s := range restartSettings {
+		if s.keep(old.cfg, cfg) {
+			restartRequired = append(restartRequired, s.env)
+		}
+	}
+	if len(restartRequired) > 0 {
+		fmt.Printf("Config %v changed, restart required to take effect\n", restartRequired)
+	}
+
+	if checksum(cfg) == old.info.Checksum {
+		fmt.Printf("Config reloaded (%s), revision %d unchanged\n", trigger, old.info.Number)
+		return old.info, nil
+	}
+
+	_, rev := swap(cfg)
+	result.Changed = true
+	fmt.Printf("Config revision %d active (%s, checksum %s)\n", rev.info.Number, trigger, rev.info.Checksum)
+
+	draining[old] = struct{}{}
+	hooks := append([]func(old, cfg *model.Config){}, reloadHooks...)
+	go drain(old, cfg, hooks)
+	return rev.info, nil
+}
+
+// drain 等待旧版本上的请求完成后调用回调
+func drain(old *revision, cfg *model.Config, hooks []func(old, cfg *model.Config)) {
+	deadline := time.Now().Add(drainTimeout)
+	for old.inFlight.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+	if n := old.inFlight.Load(); n > 0 {
+		fmt.Printf("Config revision %d still has %d requests in flight after %s, not waiting\n", old.info.Number, n, drainTimeout)
+	} else {
+		fmt.Printf("Config revision %d drained\n", old.info.Number)
+	}
+
+	for _, hook := range hooks {
+		hook(old.cfg, cfg)
+	}
+
+	reloadMu.Lock()
+	delete(draining, old)
+	reloadMu.Unlock()
+}
+
+// Watch 在收到 SIGHUP，或配置文件、密钥文件的修改时间、大小变化时重新加载配置，
+// CONFIG_WATCH_INTERVAL 为 0 时不检查文件
+func Watch() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			Reload(ReloadTriggerSignal)
+		}
+	}()
+
+	interval := Current().ConfigWatchInterval
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		last := watchedFilesState()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			state := watchedFilesState()
+			if state == last {
+				continue
+			}
+			last = state
+			Reload(ReloadTriggerFile)
+		}
+	}()
+}
+
+// watchedFilesState 返回配置文件和密钥文件的修改时间与大小，不存在或暂时不可读的文件记为 -
+func watchedFilesState() string {
+	reloadMu.Lock()
+	path := source.path
+	reloadMu.Unlock()
+
+	files := secretFiles(Current().SecretsDir)
+	if path != "" {
+		files = append(files, path)
+	}
+
+	var b strings.Builder
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d;", file, info.ModTime().UnixNano(), info.Size())
+		} else {
+			fmt.Fprintf(&b, "%s:-;", file)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"context"
+	"dooqiniu/internal/model"
+	"testing"
+)
+
+func TestAcquiredConfigSurvivesReload(t *testing.T) {
+	first := &model.Config{QiniuBucket: "first"}
+	Set(first)
+	revision, cfg, release := Acquire()
+	defer release()
+	if cfg != first {
+		t.Fatalf("Acquire returned %+v, want the active config", cfg)
+	}
+
+	ctx := NewContext(context.Background(), cfg)
+	second := &model.Config{QiniuBucket: "second"}
+	Set(second)
+
+	if got := FromContext(ctx); got != first {
+		t.Errorf("FromContext = %q, want the config acquired at revision %d", got.QiniuBucket, revision.Number)
+	}
+	if got := FromContext(context.Background()); got != second {
+		t.Errorf("FromContext without a config = %q, want the current config", got.QiniuBucket)
+	}
+}
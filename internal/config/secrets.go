@@ -0,0 +1,137 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 从文件读取密钥的环境变量和配置文件键名后缀，例如 QINIU_SECRETKEY_FILE、qiniu_secretkey_file
+	secretFileEnvSuffix = "_FILE"
+	secretFileKeySuffix = "_file"
+	// secretProviderTimeout 从密钥服务读取所有密钥的超时时间
+	secretProviderTimeout = 10 * time.Second
+)
+
+// SecretProvider 外部密钥服务，用于读取七牛云密钥等密钥类配置项
+type SecretProvider interface {
+	// Lookup 返回配置项的值，key 为配置文件中的键名，例如 qiniu_secretkey，密钥服务中没有该配置项时 ok 为 false
+	Lookup(ctx context.Context, key string) (value string, ok bool, err error)
+}
+
+// SecretProviderFactory 创建密钥服务，value 返回已加载的配置项的值（以环境变量名表示），用于读取密钥服务自身的配置
+type SecretProviderFactory func(value func(env string) string) (SecretProvider, error)
+
+var (
+	secretProvidersMu sync.RWMutex
+	secretProviders   = map[string]SecretProviderFactory{
+		"vault": newVaultProvider,
+	}
+)
+
+// RegisterSecretProvider 注册密钥服务，SECRET_PROVIDER 为 name 时使用，已存在时替换
+func RegisterSecretProvider(name string, factory SecretProviderFactory) {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+	secretProviders[name] = factory
+}
+
+// loadSecrets 为未通过命令行参数或环境变量设置的密钥类配置项依次尝试 <ENV>_FILE 指向的文件、
+// 密钥目录和密钥服务，找到时写入 values，都没有时保留配置文件中的值或默认值
+func loadSecrets(pending []setting, values, fileValues map[string]string) error {
+	var remaining []setting
+	for _, s := range pending {
+		value, ok, err := readSecretFile(s, values["SECRETS_DIR"], fileValues)
+		if err != nil {
+			return err
+		}
+		if ok {
+			values[s.env] = value
+		} else if !s.bootstrap {
+			remaining = append(remaining, s)
+		}
+	}
+
+	name := values["SECRET_PROVIDER"]
+	if name == "" || len(remaining) == 0 {
+		return nil
+	}
+	secretProvidersMu.RLock()
+	factory, ok := secretProviders[name]
+	secretProvidersMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("invalid SECRET_PROVIDER: unknown secret provider %q", name)
+	}
+	provider, err := factory(func(env string) string { return values[env] })
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), secretProviderTimeout)
+	defer cancel()
+	for _, s := range remaining {
+		value, ok, err := provider.Lookup(ctx, s.key())
+		if err != nil {
+			return fmt.Errorf("failed to read %s from secret provider %s: %w", s.env, name, err)
+		}
+		if ok {
+			values[s.env] = value
+		}
+	}
+	return nil
+}
+
+// readSecretFile 读取 <ENV>_FILE 环境变量、配置文件中的 <key>_file 或密钥目录下以键名或环境变量名命名的文件，
+// 文件末尾的换行会被去掉
+func readSecretFile(s setting, dir string, fileValues map[string]string) (string, bool, error) {
+	path := os.Getenv(s.env + secretFileEnvSuffix)
+	if path == "" {
+		path = fileValues[s.key()+secretFileKeySuffix]
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read %s%s: %w", s.env, secretFileEnvSuffix, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	}
+
+	if dir == "" {
+		return "", false, nil
+	}
+	for _, name := range []string{s.key(), s.env} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return strings.TrimRight(string(data), "\r\n"), true, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", false, fmt.Errorf("failed to read secret %s: %w", filepath.Join(dir, name), err)
+		}
+	}
+	return "", false, nil
+}
+
+// secretFiles 返回当前环境下可能提供密钥的文件，重新加载时检查这些文件是否修改
+func secretFiles(dir string) []string {
+	var files []string
+	for _, s := range settings {
+		if !s.secret {
+			continue
+		}
+		if path := os.Getenv(s.env + secretFileEnvSuffix); path != "" {
+			files = append(files, path)
+			continue
+		}
+		if dir != "" {
+			files = append(files, filepath.Join(dir, s.key()), filepath.Join(dir, s.env))
+		}
+	}
+	return files
+}
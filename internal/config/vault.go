@@ -0,0 +1,118 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var _ SecretProvider = (*VaultProvider)(nil)
+
+// VaultProvider 通过 HTTP API 从 Vault 的 KV 密钥引擎（v1 或 v2）读取一个密钥，同一个实例只请求一次。
+// 密钥中的字段名与配置文件的键名相同，例如 qiniu_secretkey，也可以使用环境变量名 QINIU_SECRETKEY
+type VaultProvider struct {
+	addr      string
+	token     string
+	path      string
+	namespace string
+	client    *http.Client
+
+	once   sync.Once
+	values map[string]string
+	err    error
+}
+
+// NewVaultProvider 创建 Vault 密钥服务，path 为密钥的 API 路径，KV v2 需要包含 data，例如 secret/data/dooqiniu
+func NewVaultProvider(addr, token, path, namespace string) *VaultProvider {
+	return &VaultProvider{
+		addr:      strings.TrimRight(addr, "/"),
+		token:     token,
+		path:      strings.Trim(path, "/"),
+		namespace: namespace,
+		client:    &http.Client{Timeout: secretProviderTimeout},
+	}
+}
+
+// newVaultProvider 使用 VAULT_ADDR、VAULT_TOKEN、VAULT_SECRET_PATH 和 VAULT_NAMESPACE 创建 Vault 密钥服务
+func newVaultProvider(value func(env string) string) (SecretProvider, error) {
+	for _, env := range []string{"VAULT_ADDR", "VAULT_TOKEN", "VAULT_SECRET_PATH"} {
+		if value(env) == "" {
+			return nil, fmt.Errorf("%s is required when SECRET_PROVIDER is vault", env)
+		}
+	}
+	return NewVaultProvider(value("VAULT_ADDR"), value("VAULT_TOKEN"), value("VAULT_SECRET_PATH"), value("VAULT_NAMESPACE")), nil
+}
+
+// Lookup 返回密钥中 key 对应的字段，第一次调用时读取整个密钥
+func (p *VaultProvider) Lookup(ctx context.Context, key string) (string, bool, error) {
+	p.once.Do(func() {
+		p.values, p.err = p.read(ctx)
+	})
+	if p.err != nil {
+		return "", false, p.err
+	}
+	for _, name := range []string{key, strings.ToUpper(key)} {
+		if value, ok := p.values[name]; ok {
+			return value, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// read 读取密钥的所有字段，字符串字段直接返回，对象等其他类型的字段返回 JSON 文本
+func (p *VaultProvider) read(ctx context.Context) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.addr+"/v1/"+p.path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault secret: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []string                   `json:"errors"`
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode != http.StatusOK {
+		if len(body.Errors) > 0 {
+			return nil, fmt.Errorf("vault returned %s for %s: %s", resp.Status, p.path, strings.Join(body.Errors, "; "))
+		}
+		return nil, fmt.Errorf("vault returned %s for %s", resp.Status, p.path)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("invalid vault response: %w", decodeErr)
+	}
+
+	// KV v2 的字段在 data.data 中，同时返回 data.metadata
+	data := body.Data
+	if inner, ok := data["data"]; ok {
+		if _, ok := data["metadata"]; ok {
+			data = nil
+			if err := json.Unmarshal(inner, &data); err != nil {
+				return nil, fmt.Errorf("invalid vault response: %w", err)
+			}
+		}
+	}
+
+	values := make(map[string]string, len(data))
+	for key, raw := range data {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			values[key] = s
+		} else {
+			values[key] = string(raw)
+		}
+	}
+	return values, nil
+}
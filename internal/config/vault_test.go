@@ -0,0 +1,154 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// vaultStub 模拟 Vault 的 HTTP API，token 或命名空间不匹配时返回 403
+func vaultStub(t *testing.T, namespace string, secrets map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("X-Vault-Token") != "s.test" || r.Header.Get("X-Vault-Namespace") != namespace {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		body, ok := secrets[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVaultProviderLookup(t *testing.T) {
+	server := vaultStub(t, "", map[string]string{
+		"/v1/kv/dooqiniu":          `{"data":{"qiniu_secretkey":"v1-secret","QINIU_ACCESSKEY":"v1-access","s3_credentials":{"AKID":"secret"}}}`,
+		"/v1/secret/data/dooqiniu": `{"data":{"data":{"qiniu_secretkey":"v2-secret"},"metadata":{"version":3}}}`,
+		// KV v1 中名为 data 的普通字段不能被当作 KV v2 的结构
+		"/v1/kv/nested": `{"data":{"data":"plain field"}}`,
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		key    string
+		want   string
+		wantOK bool
+	}{
+		{"kv v1", "kv/dooqiniu", "qiniu_secretkey", "v1-secret", true},
+		{"environment variable name", "kv/dooqiniu", "qiniu_accesskey", "v1-access", true},
+		{"object field as json", "kv/dooqiniu", "s3_credentials", `{"AKID":"secret"}`, true},
+		{"missing field", "kv/dooqiniu", "qiniu_profiles", "", false},
+		{"kv v2", "secret/data/dooqiniu", "qiniu_secretkey", "v2-secret", true},
+		{"kv v2 metadata is not a field", "secret/data/dooqiniu", "metadata", "", false},
+		{"kv v1 field named data", "kv/nested", "data", "plain field", true},
+		{"leading and trailing slashes", "/kv/dooqiniu/", "qiniu_secretkey", "v1-secret", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewVaultProvider(server.URL+"/", "s.test", tt.path, "")
+			got, ok, err := p.Lookup(context.Background(), tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Lookup(%q) = %q, %v, want %q, %v", tt.key, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestVaultProviderNamespaceAndErrors(t *testing.T) {
+	server := vaultStub(t, "team-a", map[string]string{
+		"/v1/kv/dooqiniu": `{"data":{"qiniu_secretkey":"secret"}}`,
+		"/v1/kv/broken":   `not json`,
+	})
+
+	tests := []struct {
+		name      string
+		token     string
+		path      string
+		namespace string
+		wantErr   string
+	}{
+		{"namespace header", "s.test", "kv/dooqiniu", "team-a", ""},
+		{"wrong namespace", "s.test", "kv/dooqiniu", "team-b", "permission denied"},
+		{"wrong token", "s.other", "kv/dooqiniu", "team-a", "403 Forbidden"},
+		{"missing secret", "s.test", "kv/missing", "team-a", "404 Not Found"},
+		{"invalid response", "s.test", "kv/broken", "team-a", "invalid vault response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewVaultProvider(server.URL, tt.token, tt.path, tt.namespace)
+			value, _, err := p.Lookup(context.Background(), "qiniu_secretkey")
+			if tt.wantErr == "" {
+				if err != nil || value != "secret" {
+					t.Errorf("Lookup = %q, %v", value, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVaultProviderReadsOnce(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"data":{"qiniu_accesskey":"access","qiniu_secretkey":"secret"}}`))
+	}))
+	defer server.Close()
+
+	p := NewVaultProvider(server.URL, "s.test", "kv/dooqiniu", "")
+	for _, key := range []string{"qiniu_accesskey", "qiniu_secretkey", "qiniu_profiles"} {
+		if _, _, err := p.Lookup(context.Background(), key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("vault was requested %d times, want 1", requests)
+	}
+}
+
+func TestLoadReadsSecretsFromVault(t *testing.T) {
+	server := vaultStub(t, "", map[string]string{
+		"/v1/secret/data/dooqiniu": `{"data":{"data":{"QINIU_ACCESSKEY":"vault-access","qiniu_secretkey":"vault-secret"},"metadata":{}}}`,
+	})
+	t.Setenv("SECRET_PROVIDER", "vault")
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "s.test")
+	t.Setenv("VAULT_SECRET_PATH", "secret/data/dooqiniu")
+	t.Setenv("QINIU_ACCESSKEY", "")
+	t.Setenv("QINIU_SECRETKEY", "")
+	t.Setenv("STORAGE_BACKEND", "memory")
+
+	cfg, err := Load("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.QiniuAccessKey != "vault-access" || cfg.QiniuSecretKey != "vault-secret" {
+		t.Errorf("credentials = %q, %q, want the values from vault", cfg.QiniuAccessKey, cfg.QiniuSecretKey)
+	}
+
+	// 环境变量优先于密钥服务
+	t.Setenv("QINIU_SECRETKEY", "from-env")
+	if cfg, err = Load("", nil); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.QiniuSecretKey != "from-env" {
+		t.Errorf("QiniuSecretKey = %q, want the environment variable", cfg.QiniuSecretKey)
+	}
+}
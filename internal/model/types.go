@@ -1,7 +1,7 @@
 package model
 
 import (
-	"mime/multipart"
+	"io"
 	"time"
 )
 
@@ -11,13 +11,144 @@ type Config struct {
 	QiniuRegion    string
 	QiniuEndpoint  string
 	QiniuBucket    string
-	QiniuSecretId  string
+	QiniuAccessKey string
 	QiniuSecretKey string
+	// Buckets 命名的存储空间配置，请求通过 bucket 参数选择，未指定时使用 QiniuBucket
+	Buckets map[string]BucketProfile
+	// Profiles 命名的七牛云账号配置，请求通过 X-Qiniu-Profile 请求头或路由前缀选择，未指定时使用上面的默认账号
+	Profiles map[string]AccountProfile
+	// StorageBackend 存储后端：qiniu（默认）、local 或 memory
+	StorageBackend  string
+	LocalStorageDir string
+	// S3 兼容网关，S3GatewayAddr 为空时不启动
+	S3GatewayAddr string
+	S3Bucket      string
+	S3Region      string
+	// S3Credentials 网关本地的 AccessKey 到 SecretKey 的映射，与七牛云凭证无关
+	S3Credentials map[string]string
+	// DownloadURLMaxExpires 私有下载链接允许的最长有效期
+	DownloadURLMaxExpires time.Duration
+	// AllowRootPrefixDelete 是否允许按空前缀（即整个存储桶）删除
+	AllowRootPrefixDelete bool
+	// ListScanBudget 带筛选条件的列举单次请求最多扫描的对象数量
+	ListScanBudget int
+	// IndexPath 本地元数据索引文件路径，为空时不启用索引
+	IndexPath string
+	// IndexReconcileInterval 索引与存储后端定期对账的间隔
+	IndexReconcileInterval time.Duration
+	// StatsDir 用量统计快照的保存目录
+	StatsDir string
+	// StatsCacheTTL 用量统计快照的缓存有效期，超过后重新统计
+	StatsCacheTTL time.Duration
+	// StatsSnapshotInterval 定期保存用量统计快照的间隔，为 0 时不定期统计
+	StatsSnapshotInterval time.Duration
+	// UploadSessionDir 分片上传会话的保存目录
+	UploadSessionDir string
+	// TusUploadDir tus 上传任务的暂存目录
+	TusUploadDir string
+	// ConfigWatchInterval 检查配置文件是否修改的间隔，为 0 时只在收到 SIGHUP 时重新加载
+	ConfigWatchInterval time.Duration
+	// SecretsDir 密钥文件目录，文件名为配置项的键名，例如 Docker 的 /run/secrets
+	SecretsDir string
+	// SecretProvider 外部密钥服务，为空时不使用
+	SecretProvider string
+	// Vault 密钥服务的地址、令牌、密钥路径和命名空间
+	VaultAddr       string
+	VaultToken      string
+	VaultSecretPath string
+	VaultNamespace  string
 }
 
-// Uploader 定义上传接口
+// BucketProfile 命名的存储空间配置，与默认存储空间使用同一个七牛云账号
+type BucketProfile struct {
+	// Bucket 七牛云存储空间名称
+	Bucket string `json:"bucket"`
+	// Endpoint 下载域名
+	Endpoint string `json:"endpoint"`
+	// Region 区域 ID，例如 z0，为空时自动查询
+	Region string `json:"region,omitempty"`
+}
+
+// AccountProfile 命名的七牛云账号配置，包括默认存储空间和该账号下的命名存储空间
+type AccountProfile struct {
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+	// Bucket、Endpoint、Region 为该账号的默认存储空间
+	Bucket   string `json:"bucket"`
+	Endpoint string `json:"endpoint"`
+	Region   string `json:"region,omitempty"`
+	// Buckets 该账号下的命名存储空间，请求通过 bucket 参数选择
+	Buckets map[string]BucketProfile `json:"buckets,omitempty"`
+}
+
+// URLOptions 生成下载链接时的附加参数
+type URLOptions struct {
+	// AttName 下载时保存的文件名
+	AttName string
+	// Fop 七牛云数据处理指令，例如 imageView2/1/w/200
+	Fop string
+}
+
+// 批量操作类型
+const (
+	BatchOpDelete = "delete"
+	BatchOpCopy   = "copy"
+	BatchOpMove   = "move"
+	BatchOpStat   = "stat"
+	BatchOpChtype = "chtype"
+	// BatchOpRestore 解冻归档存储或深度归档存储的对象
+	BatchOpRestore = "restore"
+	// BatchOpLifecycle 设置对象的生命周期
+	BatchOpLifecycle = "lifecycle"
+)
+
+// BatchOperation 批量操作中的单个操作，delete/stat/chtype/restore/lifecycle 使用 ObjectName，
+// copy/move 使用 SrcObject 和 DestObject
+type BatchOperation struct {
+	Op         string `json:"op"`
+	ObjectName string `json:"objectName,omitempty"`
+	SrcObject  string `json:"srcObject,omitempty"`
+	DestObject string `json:"destObject,omitempty"`
+	Force      bool   `json:"force,omitempty"`
+	// Type chtype 的目标存储类型：0 标准存储，1 低频存储，2 归档存储，3 深度归档存储，4 归档直读存储
+	Type int `json:"type,omitempty"`
+	// FreezeAfterDays restore 解冻后的有效天数，1 到 7 天
+	FreezeAfterDays int `json:"freezeAfterDays,omitempty"`
+	// Lifecycle lifecycle 操作设置的生命周期规则
+	Lifecycle *LifecycleRule `json:"lifecycle,omitempty"`
+}
+
+// LifecycleRule 对象的生命周期规则，天数从上传时间开始计算，0 表示不修改，-1 表示取消已设置的规则
+type LifecycleRule struct {
+	ToIAAfterDays          int `json:"toIAAfterDays,omitempty"`
+	ToArchiveIRAfterDays   int `json:"toArchiveIRAfterDays,omitempty"`
+	ToArchiveAfterDays     int `json:"toArchiveAfterDays,omitempty"`
+	ToDeepArchiveAfterDays int `json:"toDeepArchiveAfterDays,omitempty"`
+	DeleteAfterDays        int `json:"deleteAfterDays,omitempty"`
+}
+
+// ObjectLifecycle 对象按生命周期规则转换存储类型和过期删除的时间，未设置的规则不返回
+type ObjectLifecycle struct {
+	TransitionToIA          *time.Time `json:"transition_to_ia,omitempty"`
+	TransitionToArchiveIR   *time.Time `json:"transition_to_archive_ir,omitempty"`
+	TransitionToArchive     *time.Time `json:"transition_to_archive,omitempty"`
+	TransitionToDeepArchive *time.Time `json:"transition_to_deep_archive,omitempty"`
+	Expiration              *time.Time `json:"expiration,omitempty"`
+}
+
+// BatchResult 单个操作的执行结果，Code 与 HTTP 状态码含义一致
+type BatchResult struct {
+	Operation BatchOperation `json:"operation"`
+	Code      int            `json:"code"`
+	Error     string         `json:"error,omitempty"`
+	// Data stat 操作返回的文件信息
+	Data *FileInfo `json:"data,omitempty"`
+}
+
+// Uploader 定义上传接口，file 可以是 multipart.File 或请求体等任意数据流，
+// metaData 为上传时附加的自定义元数据，键名不含 x-qn-meta- 前缀，可以为 nil
 type Uploader interface {
-	Upload(file multipart.File, objectName string) (string, error)
+	Upload(file io.Reader, objectName, contentType string, metaData map[string]string) (*UploadResponse, error)
 }
 
 // FileInfo 包含文件基本信息
@@ -25,7 +156,20 @@ type FileInfo struct {
 	Key           string    `json:"key"`
 	ContentLength int64     `json:"content-length"`
 	ETag          string    `json:"etag"`
+	MimeType      string    `json:"mime_type"`
 	LastModified  time.Time `json:"last_modified"`
+	// StorageType 存储类型：0 标准存储，1 低频存储，2 归档存储，3 深度归档存储，4 归档直读存储
+	StorageType int `json:"storage_type"`
+	// EndUser 上传时指定的终端用户标识
+	EndUser string `json:"end_user,omitempty"`
+	// Status 文件状态：0 启用，1 禁用
+	Status int `json:"status"`
+	// RestoreStatus 归档文件的解冻状态：0 未解冻，1 解冻中，2 解冻完成
+	RestoreStatus int `json:"restore_status,omitempty"`
+	// MetaData 自定义元数据，键名不含 x-qn-meta- 前缀
+	MetaData map[string]string `json:"metadata,omitempty"`
+	// Lifecycle 生命周期，只在查询单个文件时返回
+	Lifecycle *ObjectLifecycle `json:"lifecycle,omitempty"`
 }
 
 // UploadResponse 包含上传文件后的响应信息
@@ -34,3 +178,42 @@ type UploadResponse struct {
 	ETag          string    `json:"etag"`
 	LastModified  time.Time `json:"last-modified"`
 }
+
+// UploadSession 分片上传会话，对应七牛云分片上传 v2 的一次 Multipart Upload 任务
+type UploadSession struct {
+	ID          string `json:"id"`
+	ObjectName  string `json:"object_name"`
+	UploadID    string `json:"upload_id"`
+	ContentType string `json:"content_type,omitempty"`
+	// UploadKey 分片实际上传到的 key，为空时与 ObjectName 相同。
+	// S3 网关先上传到临时 key，完成后再移动到 ObjectName，以便覆盖已存在的对象
+	UploadKey string `json:"upload_key,omitempty"`
+	// Profile、Bucket 创建会话时选择的账号和存储空间，为空表示默认账号和默认存储空间
+	Profile   string    `json:"profile,omitempty"`
+	Bucket    string    `json:"bucket,omitempty"`
+	ExpireAt  time.Time `json:"expire_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UploadedPart 已经上传成功的分片信息
+type UploadedPart struct {
+	PartNumber   int64     `json:"part_number"`
+	ETag         string    `json:"etag"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// TusUpload tus 协议上传任务，数据在本地暂存，全部接收后再写入七牛云
+type TusUpload struct {
+	ID          string            `json:"id"`
+	ObjectName  string            `json:"object_name"`
+	ContentType string            `json:"content_type,omitempty"`
+	Length      int64             `json:"length"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	// Profile、Bucket 创建任务时选择的账号和存储空间，为空表示默认账号和默认存储空间
+	Profile   string    `json:"profile,omitempty"`
+	Bucket    string    `json:"bucket,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Result 写入七牛云后的文件信息，为空表示尚未完成
+	Result *UploadResponse `json:"result,omitempty"`
+}
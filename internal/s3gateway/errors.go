@@ -0,0 +1,86 @@
+package s3gateway
+
+import (
+	"dooqiniu/internal/service"
+	"encoding/xml"
+	"errors"
+	"net/http"
+)
+
+// s3Error S3 协议定义的错误，Code 与 AWS 文档中的错误码一致
+type s3Error struct {
+	Code       string
+	Message    string
+	StatusCode int
+}
+
+func (e *s3Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+var (
+	errAccessDenied                 = &s3Error{"AccessDenied", "Access Denied.", http.StatusForbidden}
+	errSignatureVersionNotSupported = &s3Error{"InvalidRequest", "The authorization mechanism you have provided is not supported. Please use AWS4-HMAC-SHA256.", http.StatusBadRequest}
+	errAuthorizationHeaderMalformed = &s3Error{"AuthorizationHeaderMalformed", "The authorization header is malformed.", http.StatusBadRequest}
+	errInvalidAccessKeyID           = &s3Error{"InvalidAccessKeyId", "The AWS Access Key Id you provided does not exist in our records.", http.StatusForbidden}
+	errSignatureDoesNotMatch        = &s3Error{"SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.", http.StatusForbidden}
+	errRequestTimeTooSkewed         = &s3Error{"RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.", http.StatusForbidden}
+	errExpiredPresignRequest        = &s3Error{"AccessDenied", "Request has expired.", http.StatusForbidden}
+	errInvalidDigest                = &s3Error{"InvalidDigest", "The Content-MD5 or checksum you specified is not valid.", http.StatusBadRequest}
+	errBadDigest                    = &s3Error{"BadDigest", "The Content-MD5 or checksum you specified did not match what we received.", http.StatusBadRequest}
+	errContentSHA256Mismatch        = &s3Error{"XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.", http.StatusBadRequest}
+	errIncompleteBody               = &s3Error{"IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header.", http.StatusBadRequest}
+	errMalformedXML                 = &s3Error{"MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema.", http.StatusBadRequest}
+	errNoSuchBucket                 = &s3Error{"NoSuchBucket", "The specified bucket does not exist.", http.StatusNotFound}
+	errNoSuchKey                    = &s3Error{"NoSuchKey", "The specified key does not exist.", http.StatusNotFound}
+	errNoSuchUpload                 = &s3Error{"NoSuchUpload", "The specified multipart upload does not exist.", http.StatusNotFound}
+	errInvalidPart                  = &s3Error{"InvalidPart", "One or more of the specified parts could not be found.", http.StatusBadRequest}
+	errInvalidPartOrder             = &s3Error{"InvalidPartOrder", "The list of parts was not in ascending order.", http.StatusBadRequest}
+	errInvalidArgument              = &s3Error{"InvalidArgument", "Invalid Argument.", http.StatusBadRequest}
+	errInvalidRange                 = &s3Error{"InvalidRange", "The requested range is not satisfiable.", http.StatusRequestedRangeNotSatisfiable}
+	errEntityTooLarge               = &s3Error{"EntityTooLarge", "Your proposed upload exceeds the maximum allowed object size.", http.StatusBadRequest}
+	errOperationAborted             = &s3Error{"OperationAborted", "A conflicting conditional operation is currently in progress against this resource. Please try again.", http.StatusConflict}
+	errMethodNotAllowed             = &s3Error{"MethodNotAllowed", "The specified method is not allowed against this resource.", http.StatusMethodNotAllowed}
+	errNotImplemented               = &s3Error{"NotImplemented", "A header or query you provided implies functionality that is not implemented.", http.StatusNotImplemented}
+	errInternalError                = &s3Error{"InternalError", "We encountered an internal error. Please try again.", http.StatusInternalServerError}
+)
+
+// toS3Error 将存储后端返回的错误转换为 S3 错误
+func toS3Error(err error) *s3Error {
+	var s3Err *s3Error
+	switch {
+	case errors.As(err, &s3Err):
+		return s3Err
+	case errors.Is(err, service.ErrObjectNotFound):
+		return errNoSuchKey
+	case errors.Is(err, service.ErrMultipartUploadNotFound), errors.Is(err, service.ErrUploadSessionNotFound):
+		return errNoSuchUpload
+	case errors.Is(err, service.ErrObjectExists):
+		// 检查目标对象后、上传完成前有其他请求创建了同名对象
+		return errOperationAborted
+	default:
+		return errInternalError
+	}
+}
+
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource,omitempty"`
+	RequestID string   `xml:"RequestId"`
+}
+
+// writeError 返回 XML 格式的错误，HEAD 请求只返回状态码
+func writeError(w http.ResponseWriter, r *http.Request, err *s3Error) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(err.StatusCode)
+		return
+	}
+	writeXML(w, err.StatusCode, errorResponse{
+		Code:      err.Code,
+		Message:   err.Message,
+		Resource:  r.URL.Path,
+		RequestID: w.Header().Get("X-Amz-Request-Id"),
+	})
+}
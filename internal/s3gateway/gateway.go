@@ -0,0 +1,236 @@
+package s3gateway
+
+import (
+	"dooqiniu/internal/config"
+	"dooqiniu/internal/model"
+	"dooqiniu/internal/service"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Gateway 以 S3 REST API 的子集对外提供存储服务，所有请求都使用本地配置的
+// S3 密钥校验 SigV4 签名，再转换为 service.Storage 的调用，七牛云的密钥不会下发给客户端
+type Gateway struct {
+	bucket      string
+	region      string
+	credentials map[string]string
+	// now 返回校验签名时间使用的当前时间
+	now func() time.Time
+}
+
+func NewGateway(cfg *model.Config) *Gateway {
+	return &Gateway{
+		bucket:      cfg.S3Bucket,
+		region:      cfg.S3Region,
+		credentials: cfg.S3Credentials,
+		now:         time.Now,
+	}
+}
+
+// ServeHTTP 校验签名后按路径和查询参数分发到对应的 S3 操作
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if requestID, err := service.NewUploadSessionID(); err == nil {
+		w.Header().Set("X-Amz-Request-Id", strings.ToUpper(requestID[:16]))
+	}
+	w.Header().Set("Server", "dooqiniu")
+
+	sig, s3Err := g.verifySignature(r)
+	if s3Err != nil {
+		writeError(w, r, s3Err)
+		return
+	}
+
+	bucket, key := g.parsePath(r)
+	if bucket == "" {
+		if r.Method != http.MethodGet {
+			writeError(w, r, errMethodNotAllowed)
+			return
+		}
+		g.listBuckets(w, r)
+		return
+	}
+	if bucket != g.bucket {
+		writeError(w, r, errNoSuchBucket)
+		return
+	}
+
+	// 整个请求使用开始时获取的配置版本
+	cfg := config.FromContext(r.Context())
+	storage, err := service.NewStorage(cfg)
+	if err != nil {
+		writeError(w, r, errInternalError)
+		return
+	}
+	req := &request{w: w, r: r, sig: sig, cfg: cfg, storage: storage, key: key}
+
+	if key == "" {
+		g.serveBucket(req)
+		return
+	}
+	g.serveObject(req)
+}
+
+// request 单次 S3 请求的上下文
+type request struct {
+	w       http.ResponseWriter
+	r       *http.Request
+	sig     *signature
+	cfg     *model.Config
+	storage service.Storage
+	key     string
+}
+
+func (g *Gateway) serveBucket(req *request) {
+	query := req.r.URL.Query()
+
+	switch req.r.Method {
+	case http.MethodHead:
+		req.w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		switch {
+		case query.Has("location"):
+			g.getBucketLocation(req)
+		case query.Has("uploads"):
+			// 上传会话按 ID 存储，无法按 bucket 列举，返回空列表
+			writeXML(req.w, http.StatusOK, listMultipartUploadsResult{Bucket: g.bucket})
+		case query.Get("list-type") == "2":
+			g.listObjectsV2(req)
+		default:
+			g.listObjectsV1(req)
+		}
+	case http.MethodPost:
+		if query.Has("delete") {
+			g.deleteObjects(req)
+			return
+		}
+		writeError(req.w, req.r, errNotImplemented)
+	default:
+		writeError(req.w, req.r, errMethodNotAllowed)
+	}
+}
+
+func (g *Gateway) serveObject(req *request) {
+	query := req.r.URL.Query()
+
+	switch req.r.Method {
+	case http.MethodHead:
+		g.getObject(req, false)
+	case http.MethodGet:
+		if query.Has("uploadId") {
+			g.listParts(req)
+			return
+		}
+		g.getObject(req, true)
+	case http.MethodPut:
+		switch {
+		case query.Has("uploadId"):
+			if req.r.Header.Get("X-Amz-Copy-Source") != "" {
+				writeError(req.w, req.r, errNotImplemented)
+				return
+			}
+			g.uploadPart(req)
+		case req.r.Header.Get("X-Amz-Copy-Source") != "":
+			g.copyObject(req)
+		default:
+			g.putObject(req)
+		}
+	case http.MethodPost:
+		switch {
+		case query.Has("uploads"):
+			g.createMultipartUpload(req)
+		case query.Has("uploadId"):
+			g.completeMultipartUpload(req)
+		default:
+			writeError(req.w, req.r, errNotImplemented)
+		}
+	case http.MethodDelete:
+		if query.Has("uploadId") {
+			g.abortMultipartUpload(req)
+			return
+		}
+		g.deleteObject(req)
+	default:
+		writeError(req.w, req.r, errMethodNotAllowed)
+	}
+}
+
+// parsePath 解析请求中的 bucket 和对象名，同时支持路径风格和虚拟主机风格
+func (g *Gateway) parsePath(r *http.Request) (string, string) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+
+	host := r.Host
+	if i := strings.LastIndex(host, ":"); i > 0 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+	if strings.HasPrefix(host, g.bucket+".") {
+		return g.bucket, path
+	}
+
+	bucket, key, _ := strings.Cut(path, "/")
+	return bucket, key
+}
+
+type listAllMyBucketsResult struct {
+	XMLName xml.Name     `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListAllMyBucketsResult"`
+	Owner   owner        `xml:"Owner"`
+	Buckets []bucketInfo `xml:"Buckets>Bucket"`
+}
+
+type owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type bucketInfo struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+// listBuckets 网关只对外暴露一个 bucket
+func (g *Gateway) listBuckets(w http.ResponseWriter, r *http.Request) {
+	writeXML(w, http.StatusOK, listAllMyBucketsResult{
+		Owner: owner{ID: "dooqiniu", DisplayName: "dooqiniu"},
+		Buckets: []bucketInfo{{
+			Name:         g.bucket,
+			CreationDate: formatTime(time.Unix(0, 0)),
+		}},
+	})
+}
+
+type locationConstraint struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+	Location string   `xml:",chardata"`
+}
+
+func (g *Gateway) getBucketLocation(req *request) {
+	location := g.region
+	// us-east-1 在 S3 协议中表示为空
+	if location == "us-east-1" {
+		location = ""
+	}
+	writeXML(req.w, http.StatusOK, locationConstraint{Location: location})
+}
+
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
+
+// formatTime 按 S3 XML 响应中的 ISO 8601 格式输出时间
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// quoteETag S3 的 ETag 带双引号
+func quoteETag(etag string) string {
+	return `"` + etag + `"`
+}
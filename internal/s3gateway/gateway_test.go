@@ -0,0 +1,166 @@
+package s3gateway
+
+import (
+	"bytes"
+	"context"
+	"dooqiniu/internal/config"
+	"dooqiniu/internal/service"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testBucket    = "test-bucket"
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+// newTestGateway 使用内存后端创建网关。内存后端与七牛云相同，上传不能覆盖已存在的对象
+func newTestGateway(t *testing.T) (*Gateway, service.Storage) {
+	t.Helper()
+	t.Setenv("STORAGE_BACKEND", "memory")
+	cfg, err := config.Load("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.UploadSessionDir = t.TempDir()
+	cfg.S3Bucket = testBucket
+	cfg.S3Region = "us-east-1"
+	cfg.S3Credentials = map[string]string{testAccessKey: testSecretKey}
+	config.Set(cfg)
+
+	s, err := service.NewStorage(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { service.DeletePrefix(context.Background(), s, "", nil, nil) })
+	return NewGateway(cfg), s
+}
+
+// signedRequest 创建使用 Authorization 头签名的请求
+func signedRequest(t *testing.T, g *Gateway, method, target string, body []byte) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(method, target, bytes.NewReader(body))
+	amzDate := time.Now().UTC().Format(amzDateFormat)
+	payloadHash := sha256Hex(body)
+	r.Header.Set("X-Amz-Date", amzDate)
+	r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	credential := testAccessKey + "/" + amzDate[:8] + "/us-east-1/s3/aws4_request"
+	sig, s3Err := g.newSignature(credential, amzDate, payloadHash)
+	if s3Err != nil {
+		t.Fatal(s3Err.Message)
+	}
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalURI(r.URL.Path),
+		canonicalQuery(r.URL.Query(), ""),
+		canonicalHeaders(r, signedHeaders),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{signV4Algorithm, amzDate, sig.scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+	r.Header.Set("Authorization", signV4Algorithm+" Credential="+credential+
+		", SignedHeaders="+strings.Join(signedHeaders, ";")+", Signature="+sig.sign(stringToSign))
+	return r
+}
+
+func serve(t *testing.T, g *Gateway, method, target string, body []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, signedRequest(t, g, method, target, body))
+	return w
+}
+
+func getObject(t *testing.T, g *Gateway, key string) string {
+	t.Helper()
+	w := serve(t, g, http.MethodGet, "/"+testBucket+"/"+key, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: %d %s", key, w.Code, w.Body.String())
+	}
+	return w.Body.String()
+}
+
+// assertNoTempObjects 覆盖完成后不应留下临时对象
+func assertNoTempObjects(t *testing.T, s service.Storage) {
+	t.Helper()
+	files, _, _, err := s.ListFiles("", "", "", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if strings.Contains(file.Key, ".dooqiniu-tmp-") {
+			t.Errorf("temporary object %s left behind", file.Key)
+		}
+	}
+}
+
+func TestPutObjectOverwrites(t *testing.T) {
+	g, s := newTestGateway(t)
+
+	for _, content := range []string{"first", "second"} {
+		if w := serve(t, g, http.MethodPut, "/"+testBucket+"/put/a.txt", []byte(content)); w.Code != http.StatusOK {
+			t.Fatalf("PUT %q: %d %s", content, w.Code, w.Body.String())
+		}
+		if got := getObject(t, g, "put/a.txt"); got != content {
+			t.Errorf("content = %q, want %q", got, content)
+		}
+	}
+	assertNoTempObjects(t, s)
+}
+
+func TestMultipartUploadOverwrites(t *testing.T) {
+	g, s := newTestGateway(t)
+	if w := serve(t, g, http.MethodPut, "/"+testBucket+"/multipart/a.bin", []byte("old")); w.Code != http.StatusOK {
+		t.Fatalf("PUT: %d %s", w.Code, w.Body.String())
+	}
+
+	w := serve(t, g, http.MethodPost, "/"+testBucket+"/multipart/a.bin?uploads", nil)
+	var initiated initiateMultipartUploadResult
+	if err := xml.Unmarshal(w.Body.Bytes(), &initiated); err != nil || initiated.UploadID == "" {
+		t.Fatalf("initiate: %d %s", w.Code, w.Body.String())
+	}
+
+	w = serve(t, g, http.MethodPut, "/"+testBucket+"/multipart/a.bin?partNumber=1&uploadId="+initiated.UploadID, []byte("new content"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("upload part: %d %s", w.Code, w.Body.String())
+	}
+	complete := "<CompleteMultipartUpload><Part><PartNumber>1</PartNumber><ETag>" + w.Header().Get("ETag") + "</ETag></Part></CompleteMultipartUpload>"
+	w = serve(t, g, http.MethodPost, "/"+testBucket+"/multipart/a.bin?uploadId="+initiated.UploadID, []byte(complete))
+	if w.Code != http.StatusOK {
+		t.Fatalf("complete: %d %s", w.Code, w.Body.String())
+	}
+
+	if got := getObject(t, g, "multipart/a.bin"); got != "new content" {
+		t.Errorf("content = %q, want the completed upload", got)
+	}
+	assertNoTempObjects(t, s)
+}
+
+func TestStorageUploadDoesNotOverwrite(t *testing.T) {
+	_, s := newTestGateway(t)
+	if _, err := s.Upload(strings.NewReader("first"), "insert-only.txt", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Upload(strings.NewReader("second"), "insert-only.txt", "", nil); err == nil {
+		t.Fatal("expected Upload to reject an existing object")
+	}
+
+	if _, err := service.PutObject(s, strings.NewReader("second"), "insert-only.txt", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := s.Open("insert-only.txt", 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if data, _ := io.ReadAll(reader); string(data) != "second" {
+		t.Errorf("content = %q, want second", data)
+	}
+}
@@ -0,0 +1,240 @@
+package s3gateway
+
+import (
+	"dooqiniu/internal/model"
+	"dooqiniu/internal/service"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultMaxKeys = 1000
+	// 每次向存储后端请求的对象数量
+	listPageSize = 1000
+)
+
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Marker                *string        `xml:"Marker"`
+	NextMarker            string         `xml:"NextMarker,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	KeyCount              *int           `xml:"KeyCount"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	EncodingType          string         `xml:"EncodingType,omitempty"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []objectInfo   `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type objectInfo struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// listCursor 列举的游标：marker 为存储后端当前页的游标，after 为已返回的最后一个 key 或公共前缀。
+// 存储后端的游标不一定是对象名（七牛云为不透明字符串），因此需要同时记录两者才能从页中间继续
+type listCursor struct {
+	Marker string `json:"m,omitempty"`
+	After  string `json:"a,omitempty"`
+}
+
+func (c listCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeListCursor(token string) (listCursor, bool) {
+	var cursor listCursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || json.Unmarshal(data, &cursor) != nil {
+		return cursor, false
+	}
+	return cursor, true
+}
+
+type listOutput struct {
+	objects        []model.FileInfo
+	commonPrefixes []string
+	next           *listCursor
+}
+
+// listObjects 在存储后端的分页列举之上模拟 S3 的 delimiter 和 max-keys 语义
+func listObjects(storage service.Storage, prefix, delimiter string, cursor listCursor, maxKeys int) (*listOutput, error) {
+	output := &listOutput{}
+	if maxKeys == 0 {
+		return output, nil
+	}
+
+	marker := cursor.Marker
+	after := cursor.After
+	for {
+		files, _, nextMarker, err := storage.ListFiles(prefix, "", marker, listPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			if file.Key <= after {
+				continue
+			}
+			// after 为公共前缀时跳过其下的所有对象
+			if delimiter != "" && strings.HasSuffix(after, delimiter) && strings.HasPrefix(file.Key, after) {
+				continue
+			}
+
+			if output.count() == maxKeys {
+				output.next = &listCursor{Marker: marker, After: after}
+				return output, nil
+			}
+
+			if delimiter != "" {
+				if i := strings.Index(file.Key[len(prefix):], delimiter); i >= 0 {
+					after = file.Key[:len(prefix)+i+len(delimiter)]
+					output.commonPrefixes = append(output.commonPrefixes, after)
+					continue
+				}
+			}
+			after = file.Key
+			output.objects = append(output.objects, file)
+		}
+
+		if nextMarker == "" {
+			return output, nil
+		}
+		marker = nextMarker
+	}
+}
+
+func (o *listOutput) count() int {
+	return len(o.objects) + len(o.commonPrefixes)
+}
+
+// listParams 解析 ListObjects 的公共参数
+func listParams(req *request) (string, string, int, string, *s3Error) {
+	query := req.r.URL.Query()
+
+	maxKeys := defaultMaxKeys
+	if value := query.Get("max-keys"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return "", "", 0, "", errInvalidArgument
+		}
+		if n < maxKeys {
+			maxKeys = n
+		}
+	}
+
+	encodingType := query.Get("encoding-type")
+	if encodingType != "" && encodingType != "url" {
+		return "", "", 0, "", errInvalidArgument
+	}
+	return query.Get("prefix"), query.Get("delimiter"), maxKeys, encodingType, nil
+}
+
+func (g *Gateway) listObjectsV1(req *request) {
+	prefix, delimiter, maxKeys, encodingType, s3Err := listParams(req)
+	if s3Err != nil {
+		writeError(req.w, req.r, s3Err)
+		return
+	}
+	marker := req.r.URL.Query().Get("marker")
+
+	output, err := listObjects(req.storage, prefix, delimiter, listCursor{After: marker}, maxKeys)
+	if err != nil {
+		writeError(req.w, req.r, toS3Error(err))
+		return
+	}
+
+	result := g.listResult(output, prefix, delimiter, maxKeys, encodingType)
+	result.Marker = &marker
+	// V1 的 NextMarker 只在指定了 delimiter 时返回，否则客户端使用最后一个 key
+	if output.next != nil && delimiter != "" {
+		result.NextMarker = encodeKey(output.next.After, encodingType)
+	}
+	writeXML(req.w, http.StatusOK, result)
+}
+
+func (g *Gateway) listObjectsV2(req *request) {
+	prefix, delimiter, maxKeys, encodingType, s3Err := listParams(req)
+	if s3Err != nil {
+		writeError(req.w, req.r, s3Err)
+		return
+	}
+	query := req.r.URL.Query()
+
+	var cursor listCursor
+	token := query.Get("continuation-token")
+	if token != "" {
+		var ok bool
+		if cursor, ok = decodeListCursor(token); !ok {
+			writeError(req.w, req.r, errInvalidArgument)
+			return
+		}
+	} else {
+		cursor.After = query.Get("start-after")
+	}
+
+	output, err := listObjects(req.storage, prefix, delimiter, cursor, maxKeys)
+	if err != nil {
+		writeError(req.w, req.r, toS3Error(err))
+		return
+	}
+
+	result := g.listResult(output, prefix, delimiter, maxKeys, encodingType)
+	keyCount := output.count()
+	result.KeyCount = &keyCount
+	result.ContinuationToken = token
+	result.StartAfter = encodeKey(query.Get("start-after"), encodingType)
+	if output.next != nil {
+		result.NextContinuationToken = output.next.encode()
+	}
+	writeXML(req.w, http.StatusOK, result)
+}
+
+func (g *Gateway) listResult(output *listOutput, prefix, delimiter string, maxKeys int, encodingType string) listBucketResult {
+	result := listBucketResult{
+		Name:         g.bucket,
+		Prefix:       encodeKey(prefix, encodingType),
+		MaxKeys:      maxKeys,
+		Delimiter:    encodeKey(delimiter, encodingType),
+		EncodingType: encodingType,
+		IsTruncated:  output.next != nil,
+	}
+	for _, file := range output.objects {
+		result.Contents = append(result.Contents, objectInfo{
+			Key:          encodeKey(file.Key, encodingType),
+			LastModified: formatTime(file.LastModified),
+			ETag:         quoteETag(file.ETag),
+			Size:         file.ContentLength,
+			StorageClass: "STANDARD",
+		})
+	}
+	for _, p := range output.commonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: encodeKey(p, encodingType)})
+	}
+	return result
+}
+
+// encodeKey 客户端指定 encoding-type=url 时对响应中的 key 做 URL 编码
+func encodeKey(key, encodingType string) string {
+	if encodingType != "url" {
+		return key
+	}
+	return uriEncode(key, false)
+}
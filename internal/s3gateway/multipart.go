@@ -0,0 +1,337 @@
+package s3gateway
+
+import (
+	"crypto/md5"
+	"dooqiniu/internal/model"
+	"dooqiniu/internal/service"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// S3 分片编号的取值范围
+	maxPartNumber = 10000
+	// 单个分片的最大大小
+	maxPartSize = 5 << 30
+)
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+// createMultipartUpload 创建分片上传任务，S3 的 UploadId 即上传会话 ID，
+// 会话中记录存储后端的 uploadId 和创建时指定的 Content-Type。
+// 存储后端不能覆盖已存在的对象，分片先上传到临时 key，完成时再强制移动到目标 key
+func (g *Gateway) createMultipartUpload(req *request) {
+	sessionID, err := service.NewUploadSessionID()
+	if err != nil {
+		writeError(req.w, req.r, errInternalError)
+		return
+	}
+	uploadKey, err := service.TempObjectKey(req.key)
+	if err != nil {
+		writeError(req.w, req.r, errInternalError)
+		return
+	}
+
+	uploadID, expireAt, err := req.storage.InitiateMultipartUpload(uploadKey)
+	if err != nil {
+		writeError(req.w, req.r, toS3Error(err))
+		return
+	}
+
+	session := &model.UploadSession{
+		ID:          sessionID,
+		ObjectName:  req.key,
+		UploadID:    uploadID,
+		ContentType: req.r.Header.Get("Content-Type"),
+		UploadKey:   uploadKey,
+		ExpireAt:    expireAt,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := service.NewUploadSessionStore(req.cfg).Save(session); err != nil {
+		writeError(req.w, req.r, errInternalError)
+		return
+	}
+
+	writeXML(req.w, http.StatusOK, initiateMultipartUploadResult{
+		Bucket:   g.bucket,
+		Key:      req.key,
+		UploadID: session.ID,
+	})
+}
+
+// loadSession 读取查询参数 uploadId 对应的会话，会话必须属于请求的对象且未过期
+func loadSession(req *request) (*model.UploadSession, *s3Error) {
+	session, err := service.NewUploadSessionStore(req.cfg).Get(req.r.URL.Query().Get("uploadId"))
+	if err != nil {
+		return nil, toS3Error(err)
+	}
+	// S3 网关只访问默认账号的默认存储空间，其他账号或存储空间的会话不能在这里继续
+	if session.ObjectName != req.key || session.Profile != "" || session.Bucket != "" || time.Now().After(session.ExpireAt) {
+		return nil, errNoSuchUpload
+	}
+	// 兼容没有记录临时 key 的会话
+	if session.UploadKey == "" {
+		session.UploadKey = session.ObjectName
+	}
+	return session, nil
+}
+
+func (g *Gateway) uploadPart(req *request) {
+	partNumber, err := strconv.ParseInt(req.r.URL.Query().Get("partNumber"), 10, 64)
+	if err != nil || partNumber < 1 || partNumber > maxPartNumber {
+		writeError(req.w, req.r, errInvalidArgument)
+		return
+	}
+	if req.r.ContentLength > maxPartSize {
+		writeError(req.w, req.r, errEntityTooLarge)
+		return
+	}
+
+	session, s3Err := loadSession(req)
+	if s3Err != nil {
+		writeError(req.w, req.r, s3Err)
+		return
+	}
+
+	body, s3Err := payloadReader(req.r, req.sig)
+	if s3Err != nil {
+		writeError(req.w, req.r, s3Err)
+		return
+	}
+
+	// 存储后端上传分片需要可重复读取的数据，先写入临时文件
+	part, partMD5, err := spoolPart(body)
+	if part != nil {
+		defer os.Remove(part.Name())
+		defer part.Close()
+	}
+	if err != nil {
+		writeError(req.w, req.r, toS3Error(err))
+		return
+	}
+
+	etag, err := req.storage.UploadPart(session.UploadKey, session.UploadID, partNumber, part, partMD5)
+	if err != nil {
+		writeError(req.w, req.r, toS3Error(err))
+		return
+	}
+
+	req.w.Header().Set("ETag", quoteETag(etag))
+	req.w.WriteHeader(http.StatusOK)
+}
+
+// spoolPart 将分片写入临时文件，返回定位到开头的文件和十六进制 MD5
+func spoolPart(body io.Reader) (*os.File, string, error) {
+	file, err := os.CreateTemp("", "dooqiniu-s3-part-*")
+	if err != nil {
+		return nil, "", err
+	}
+
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(file, hash), io.LimitReader(body, maxPartSize+1)); err != nil {
+		return file, "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return file, "", err
+	}
+	return file, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+type listPartsResult struct {
+	XMLName              xml.Name   `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListPartsResult"`
+	Bucket               string     `xml:"Bucket"`
+	Key                  string     `xml:"Key"`
+	UploadID             string     `xml:"UploadId"`
+	PartNumberMarker     int64      `xml:"PartNumberMarker"`
+	NextPartNumberMarker int64      `xml:"NextPartNumberMarker"`
+	MaxParts             int        `xml:"MaxParts"`
+	IsTruncated          bool       `xml:"IsTruncated"`
+	StorageClass         string     `xml:"StorageClass"`
+	Parts                []partInfo `xml:"Part"`
+}
+
+type partInfo struct {
+	PartNumber   int64  `xml:"PartNumber"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+}
+
+func (g *Gateway) listParts(req *request) {
+	query := req.r.URL.Query()
+
+	maxParts := 1000
+	if value := query.Get("max-parts"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			writeError(req.w, req.r, errInvalidArgument)
+			return
+		}
+		if n < maxParts {
+			maxParts = n
+		}
+	}
+	var partNumberMarker int64
+	if value := query.Get("part-number-marker"); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 0 {
+			writeError(req.w, req.r, errInvalidArgument)
+			return
+		}
+		partNumberMarker = n
+	}
+
+	session, s3Err := loadSession(req)
+	if s3Err != nil {
+		writeError(req.w, req.r, s3Err)
+		return
+	}
+
+	parts, err := req.storage.ListParts(session.UploadKey, session.UploadID)
+	if err != nil {
+		writeError(req.w, req.r, toS3Error(err))
+		return
+	}
+
+	result := listPartsResult{
+		Bucket:           g.bucket,
+		Key:              req.key,
+		UploadID:         session.ID,
+		PartNumberMarker: partNumberMarker,
+		MaxParts:         maxParts,
+		StorageClass:     "STANDARD",
+	}
+	for _, part := range parts {
+		if part.PartNumber <= partNumberMarker {
+			continue
+		}
+		if len(result.Parts) == maxParts {
+			result.IsTruncated = true
+			break
+		}
+		result.Parts = append(result.Parts, partInfo{
+			PartNumber:   part.PartNumber,
+			LastModified: formatTime(part.LastModified),
+			ETag:         quoteETag(part.ETag),
+			Size:         part.Size,
+		})
+		result.NextPartNumberMarker = part.PartNumber
+	}
+	writeXML(req.w, http.StatusOK, result)
+}
+
+type completeMultipartUpload struct {
+	Parts []struct {
+		PartNumber int64  `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	} `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+	Bucket  string   `xml:"Bucket"`
+	Key     string   `xml:"Key"`
+	ETag    string   `xml:"ETag"`
+}
+
+func (g *Gateway) completeMultipartUpload(req *request) {
+	session, s3Err := loadSession(req)
+	if s3Err != nil {
+		writeError(req.w, req.r, s3Err)
+		return
+	}
+
+	var input completeMultipartUpload
+	if s3Err := decodeXMLBody(req, &input); s3Err != nil {
+		writeError(req.w, req.r, s3Err)
+		return
+	}
+	if len(input.Parts) == 0 {
+		writeError(req.w, req.r, errMalformedXML)
+		return
+	}
+
+	parts := make([]model.UploadedPart, 0, len(input.Parts))
+	for i, part := range input.Parts {
+		if i > 0 && part.PartNumber <= input.Parts[i-1].PartNumber {
+			writeError(req.w, req.r, errInvalidPartOrder)
+			return
+		}
+		parts = append(parts, model.UploadedPart{
+			PartNumber: part.PartNumber,
+			ETag:       strings.Trim(part.ETag, `"`),
+		})
+	}
+
+	// 校验分片是否都已上传，避免存储后端返回难以理解的错误
+	uploaded, err := req.storage.ListParts(session.UploadKey, session.UploadID)
+	if err != nil {
+		writeError(req.w, req.r, toS3Error(err))
+		return
+	}
+	etags := make(map[int64]string, len(uploaded))
+	for _, part := range uploaded {
+		etags[part.PartNumber] = part.ETag
+	}
+	for _, part := range parts {
+		if etags[part.PartNumber] != part.ETag {
+			writeError(req.w, req.r, errInvalidPart)
+			return
+		}
+	}
+
+	result, err := req.storage.CompleteMultipartUpload(session.UploadKey, session.UploadID, session.ContentType, parts)
+	if err != nil {
+		writeError(req.w, req.r, toS3Error(err))
+		return
+	}
+	_ = service.NewUploadSessionStore(req.cfg).Delete(session.ID)
+	if session.UploadKey != session.ObjectName {
+		if err := req.storage.Move(session.UploadKey, session.ObjectName, true); err != nil {
+			req.storage.Delete(session.UploadKey)
+			writeError(req.w, req.r, toS3Error(err))
+			return
+		}
+	}
+
+	writeXML(req.w, http.StatusOK, completeMultipartUploadResult{
+		Bucket: g.bucket,
+		Key:    req.key,
+		ETag:   quoteETag(result.ETag),
+	})
+}
+
+func (g *Gateway) abortMultipartUpload(req *request) {
+	session, s3Err := loadSession(req)
+	if s3Err != nil {
+		writeError(req.w, req.r, s3Err)
+		return
+	}
+
+	if err := req.storage.AbortMultipartUpload(session.UploadKey, session.UploadID); err != nil {
+		writeError(req.w, req.r, toS3Error(err))
+		return
+	}
+	if err := service.NewUploadSessionStore(req.cfg).Delete(session.ID); err != nil {
+		writeError(req.w, req.r, errInternalError)
+		return
+	}
+	req.w.WriteHeader(http.StatusNoContent)
+}
+
+type listMultipartUploadsResult struct {
+	XMLName     xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListMultipartUploadsResult"`
+	Bucket      string   `xml:"Bucket"`
+	IsTruncated bool     `xml:"IsTruncated"`
+}
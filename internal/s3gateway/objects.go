@@ -0,0 +1,251 @@
+package s3gateway
+
+import (
+	"dooqiniu/internal/service"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// 单次 PutObject 允许的最大对象大小，与 S3 保持一致
+const maxPutObjectSize = 5 << 30
+
+// getObject 处理 GetObject 和 HeadObject，支持单个 Range
+func (g *Gateway) getObject(req *request, withBody bool) {
+	info, err := req.storage.Stat(req.key)
+	if err != nil {
+		writeError(req.w, req.r, toS3Error(err))
+		return
+	}
+
+	header := req.w.Header()
+	header.Set("ETag", quoteETag(info.ETag))
+	header.Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	header.Set("Accept-Ranges", "bytes")
+	if info.MimeType != "" {
+		header.Set("Content-Type", info.MimeType)
+	}
+
+	offset, length := int64(0), info.ContentLength
+	status := http.StatusOK
+	if rangeHeader := req.r.Header.Get("Range"); rangeHeader != "" {
+		var ok bool
+		offset, length, ok = parseRange(rangeHeader, info.ContentLength)
+		if !ok {
+			header.Set("Content-Range", "bytes */"+strconv.FormatInt(info.ContentLength, 10))
+			writeError(req.w, req.r, errInvalidRange)
+			return
+		}
+		header.Set("Content-Range", "bytes "+strconv.FormatInt(offset, 10)+"-"+
+			strconv.FormatInt(offset+length-1, 10)+"/"+strconv.FormatInt(info.ContentLength, 10))
+		status = http.StatusPartialContent
+	}
+	header.Set("Content-Length", strconv.FormatInt(length, 10))
+
+	if !withBody || length == 0 {
+		req.w.WriteHeader(status)
+		return
+	}
+
+	body, err := req.storage.Open(req.key, offset, length)
+	if err != nil {
+		header.Del("Content-Range")
+		header.Del("Content-Length")
+		writeError(req.w, req.r, toS3Error(err))
+		return
+	}
+	defer body.Close()
+
+	req.w.WriteHeader(status)
+	io.Copy(req.w, body)
+}
+
+// parseRange 解析单个字节范围，支持 bytes=a-b、bytes=a- 和 bytes=-n 三种形式
+func parseRange(value string, size int64) (int64, int64, bool) {
+	spec, ok := strings.CutPrefix(value, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	startText, endText, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, false
+	}
+
+	if startText == "" {
+		suffix, err := strconv.ParseInt(endText, 10, 64)
+		if err != nil || suffix <= 0 || size == 0 {
+			return 0, 0, false
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, suffix, true
+	}
+
+	start, err := strconv.ParseInt(startText, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if endText != "" {
+		end, err = strconv.ParseInt(endText, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end - start + 1, true
+}
+
+func (g *Gateway) putObject(req *request) {
+	if req.r.ContentLength > maxPutObjectSize {
+		writeError(req.w, req.r, errEntityTooLarge)
+		return
+	}
+
+	body, s3Err := payloadReader(req.r, req.sig)
+	if s3Err != nil {
+		writeError(req.w, req.r, s3Err)
+		return
+	}
+
+	// S3 的 PutObject 会覆盖已存在的对象，而存储后端的上传只能新增对象
+	result, err := service.PutObject(req.storage, body, req.key, req.r.Header.Get("Content-Type"), nil)
+	if err != nil {
+		writeError(req.w, req.r, toS3Error(err))
+		return
+	}
+
+	req.w.Header().Set("ETag", quoteETag(result.ETag))
+	req.w.WriteHeader(http.StatusOK)
+}
+
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CopyObjectResult"`
+	ETag         string   `xml:"ETag"`
+	LastModified string   `xml:"LastModified"`
+}
+
+// copyObject 处理 CopyObject，只支持同一个 bucket 内的复制
+func (g *Gateway) copyObject(req *request) {
+	source, err := url.PathUnescape(req.r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		writeError(req.w, req.r, errInvalidArgument)
+		return
+	}
+	// 复制源格式为 bucket/key，可能带有前导斜杠和 versionId
+	source, _, _ = strings.Cut(strings.TrimPrefix(source, "/"), "?")
+	srcBucket, srcKey, _ := strings.Cut(source, "/")
+	if srcBucket != g.bucket {
+		writeError(req.w, req.r, errNoSuchBucket)
+		return
+	}
+	if srcKey == "" {
+		writeError(req.w, req.r, errInvalidArgument)
+		return
+	}
+
+	// 复制到自身通常用于修改元数据，七牛云不支持自定义元数据，直接返回对象信息
+	if srcKey != req.key {
+		if err := req.storage.Copy(srcKey, req.key, true); err != nil {
+			writeError(req.w, req.r, toS3Error(err))
+			return
+		}
+	}
+
+	info, err := req.storage.Stat(req.key)
+	if err != nil {
+		writeError(req.w, req.r, toS3Error(err))
+		return
+	}
+	writeXML(req.w, http.StatusOK, copyObjectResult{
+		ETag:         quoteETag(info.ETag),
+		LastModified: formatTime(info.LastModified),
+	})
+}
+
+// deleteObject 对象不存在时同样返回成功，与 S3 的行为一致
+func (g *Gateway) deleteObject(req *request) {
+	if err := req.storage.Delete(req.key); err != nil && !errors.Is(err, service.ErrObjectNotFound) {
+		writeError(req.w, req.r, toS3Error(err))
+		return
+	}
+	req.w.WriteHeader(http.StatusNoContent)
+}
+
+type deleteRequest struct {
+	Quiet   bool `xml:"Quiet"`
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name        `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+	Deleted []deletedObject `xml:"Deleted"`
+	Errors  []deleteError   `xml:"Error"`
+}
+
+type deletedObject struct {
+	Key string `xml:"Key"`
+}
+
+type deleteError struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// S3 单次批量删除最多 1000 个对象
+const maxDeleteObjects = 1000
+
+func (g *Gateway) deleteObjects(req *request) {
+	var input deleteRequest
+	if s3Err := decodeXMLBody(req, &input); s3Err != nil {
+		writeError(req.w, req.r, s3Err)
+		return
+	}
+	if len(input.Objects) == 0 || len(input.Objects) > maxDeleteObjects {
+		writeError(req.w, req.r, errMalformedXML)
+		return
+	}
+
+	var result deleteResult
+	for _, object := range input.Objects {
+		err := req.storage.Delete(object.Key)
+		if err != nil && !errors.Is(err, service.ErrObjectNotFound) {
+			s3Err := toS3Error(err)
+			result.Errors = append(result.Errors, deleteError{Key: object.Key, Code: s3Err.Code, Message: s3Err.Message})
+			continue
+		}
+		if !input.Quiet {
+			result.Deleted = append(result.Deleted, deletedObject{Key: object.Key})
+		}
+	}
+	writeXML(req.w, http.StatusOK, result)
+}
+
+// 请求体为 XML 的接口允许的最大长度
+const maxXMLBodySize = 1 << 20
+
+// decodeXMLBody 校验签名后解析 XML 请求体
+func decodeXMLBody(req *request, v interface{}) *s3Error {
+	body, s3Err := payloadReader(req.r, req.sig)
+	if s3Err != nil {
+		return s3Err
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxXMLBodySize))
+	if err != nil {
+		return toS3Error(err)
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return errMalformedXML
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package s3gateway
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 单个 aws-chunked 分块的最大长度，防止异常请求占用过多内存
+const maxChunkHeaderSize = 4096
+
+// payloadReader 根据 x-amz-content-sha256 返回校验后的请求体：
+// 普通请求校验整体 SHA256，aws-chunked 请求解码分块并校验每个分块的签名
+func payloadReader(r *http.Request, sig *signature) (io.Reader, *s3Error) {
+	body := io.Reader(r.Body)
+
+	switch sig.payloadHash {
+	case unsignedPayload:
+	case streamingPayload, streamingPayloadTrailer:
+		body = newChunkedReader(r, sig, true)
+	case streamingUnsignedPayloadTail:
+		body = newChunkedReader(r, sig, false)
+	default:
+		expected, err := hex.DecodeString(sig.payloadHash)
+		if err != nil || len(expected) != sha256.Size {
+			return nil, errInvalidDigest
+		}
+		body = &verifyingReader{r: body, hash: sha256.New(), expected: expected, err: errContentSHA256Mismatch}
+	}
+
+	// Content-MD5 为 base64 编码的 MD5，数据读完后校验
+	if contentMD5 := r.Header.Get("Content-MD5"); contentMD5 != "" {
+		expected, err := base64.StdEncoding.DecodeString(contentMD5)
+		if err != nil {
+			return nil, errInvalidDigest
+		}
+		body = &verifyingReader{r: body, hash: md5.New(), expected: expected, err: errBadDigest}
+	}
+	return body, nil
+}
+
+// verifyingReader 在读到末尾时校验数据摘要，不一致则以 err 代替 io.EOF 返回
+type verifyingReader struct {
+	r        io.Reader
+	hash     hash.Hash
+	expected []byte
+	err      *s3Error
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(v.hash.Sum(nil), v.expected) {
+		return n, v.err
+	}
+	return n, err
+}
+
+// chunkedReader 解码 aws-chunked 编码的请求体
+type chunkedReader struct {
+	r         *bufio.Reader
+	sig       *signature
+	signed    bool
+	prevSig   string
+	trailer   string
+	remaining int64
+	chunkSig  string
+	chunkHash hash.Hash
+	checksum  hash.Hash
+	err       error
+}
+
+func newChunkedReader(r *http.Request, sig *signature, signed bool) *chunkedReader {
+	reader := &chunkedReader{
+		r:         bufio.NewReader(r.Body),
+		sig:       sig,
+		signed:    signed,
+		prevSig:   sig.seed,
+		trailer:   strings.ToLower(r.Header.Get("X-Amz-Trailer")),
+		chunkHash: sha256.New(),
+	}
+	reader.checksum = newChecksum(reader.trailer)
+	return reader
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	for c.remaining == 0 {
+		if err := c.nextChunk(); err != nil {
+			c.err = err
+			return 0, err
+		}
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	c.chunkHash.Write(p[:n])
+	if c.checksum != nil {
+		c.checksum.Write(p[:n])
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		c.err = err
+	}
+	return n, err
+}
+
+// nextChunk 校验上一个分块并读取下一个分块头，读到最后一个分块时返回 io.EOF
+func (c *chunkedReader) nextChunk() error {
+	if c.chunkSig != "" {
+		// 分块数据之后紧跟 \r\n
+		if err := c.expectCRLF(); err != nil {
+			return err
+		}
+		if err := c.verifyChunk(); err != nil {
+			return err
+		}
+	}
+
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	sizeText, extension, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeText), 16, 64)
+	if err != nil || size < 0 {
+		return errIncompleteBody
+	}
+
+	c.chunkSig = strings.TrimPrefix(extension, "chunk-signature=")
+	if c.signed && c.chunkSig == "" {
+		return errSignatureDoesNotMatch
+	}
+	if !c.signed {
+		c.chunkSig = "-"
+	}
+	c.chunkHash.Reset()
+	c.remaining = size
+
+	if size > 0 {
+		return nil
+	}
+
+	// 最后一个分块长度为 0，校验其签名后读取尾部
+	if err := c.verifyChunk(); err != nil {
+		return err
+	}
+	if err := c.readTrailer(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+func (c *chunkedReader) verifyChunk() error {
+	if !c.signed {
+		return nil
+	}
+	stringToSign := strings.Join([]string{
+		signV4Algorithm + "-PAYLOAD",
+		c.sig.amzDate,
+		c.sig.scope,
+		c.prevSig,
+		emptySHA256,
+		hex.EncodeToString(c.chunkHash.Sum(nil)),
+	}, "\n")
+	expected := c.sig.sign(stringToSign)
+	if !hmac.Equal([]byte(expected), []byte(c.chunkSig)) {
+		return errSignatureDoesNotMatch
+	}
+	c.prevSig = c.chunkSig
+	return nil
+}
+
+// readTrailer 读取尾部的校验和与签名，没有尾部时只有一个空行
+func (c *chunkedReader) readTrailer() error {
+	var trailers, trailerSig string
+	for {
+		line, err := c.readLine()
+		if err == io.EOF && line == "" {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			break
+		}
+		name, value, _ := strings.Cut(line, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		if name == "x-amz-trailer-signature" {
+			trailerSig = value
+			continue
+		}
+		trailers += name + ":" + value + "\n"
+
+		if name == c.trailer && c.checksum != nil {
+			if base64.StdEncoding.EncodeToString(c.checksum.Sum(nil)) != value {
+				return errBadDigest
+			}
+		}
+	}
+
+	if c.signed && c.sig.payloadHash == streamingPayloadTrailer {
+		stringToSign := strings.Join([]string{
+			signV4Algorithm + "-TRAILER",
+			c.sig.amzDate,
+			c.sig.scope,
+			c.prevSig,
+			sha256Hex([]byte(trailers)),
+		}, "\n")
+		if !hmac.Equal([]byte(c.sig.sign(stringToSign)), []byte(trailerSig)) {
+			return errSignatureDoesNotMatch
+		}
+	}
+	return nil
+}
+
+func (c *chunkedReader) readLine() (string, error) {
+	line, err := c.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull || len(line) > maxChunkHeaderSize {
+		return "", errIncompleteBody
+	}
+	if err != nil {
+		if err == io.EOF && len(line) == 0 {
+			return "", io.EOF
+		}
+		return "", errIncompleteBody
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+func (c *chunkedReader) expectCRLF() error {
+	line, err := c.readLine()
+	if err != nil || line != "" {
+		return errIncompleteBody
+	}
+	return nil
+}
+
+// newChecksum 返回尾部校验和对应的摘要算法，不支持的算法不做校验
+func newChecksum(trailer string) hash.Hash {
+	switch trailer {
+	case "x-amz-checksum-crc32":
+		return crc32.NewIEEE()
+	case "x-amz-checksum-crc32c":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case "x-amz-checksum-sha1":
+		return sha1.New()
+	case "x-amz-checksum-sha256":
+		return sha256.New()
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,283 @@
+package s3gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	signV4Algorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat   = "20060102T150405Z"
+	// 请求时间与服务器时间允许的最大偏差
+	maxClockSkew = 15 * time.Minute
+	// 预签名链接的最长有效期
+	maxPresignExpires = 7 * 24 * time.Hour
+
+	unsignedPayload              = "UNSIGNED-PAYLOAD"
+	streamingPayload             = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingPayloadTrailer      = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	streamingUnsignedPayloadTail = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	// 空字符串的 SHA256，用于分块签名
+	emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// signature 一次请求通过校验后的签名上下文，分块上传时用于继续校验每个分块
+type signature struct {
+	accessKey   string
+	amzDate     string
+	scope       string
+	signingKey  []byte
+	seed        string
+	payloadHash string
+}
+
+// verifySignature 校验 AWS Signature Version 4，支持 Authorization 头和预签名链接两种方式
+func (g *Gateway) verifySignature(r *http.Request) (*signature, *s3Error) {
+	if r.URL.Query().Get("X-Amz-Algorithm") != "" {
+		return g.verifyPresigned(r)
+	}
+
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return nil, errAccessDenied
+	}
+	if !strings.HasPrefix(authorization, signV4Algorithm+" ") {
+		return nil, errSignatureVersionNotSupported
+	}
+
+	fields := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimPrefix(authorization, signV4Algorithm+" "), ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			fields[key] = value
+		}
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	requestTime, err := time.Parse(amzDateFormat, amzDate)
+	if err != nil {
+		return nil, errAccessDenied
+	}
+	if skew := g.now().Sub(requestTime); skew > maxClockSkew || skew < -maxClockSkew {
+		return nil, errRequestTimeTooSkewed
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		return nil, errAccessDenied
+	}
+
+	sig, s3Err := g.newSignature(fields["Credential"], amzDate, payloadHash)
+	if s3Err != nil {
+		return nil, s3Err
+	}
+
+	// 签名必须包含 host 和 x-amz-date，否则截获的签名可以用于其他主机或时间
+	signedHeaders := strings.Split(fields["SignedHeaders"], ";")
+	if !signsHeaders(signedHeaders, "host", "x-amz-date") {
+		return nil, errAuthorizationHeaderMalformed
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		canonicalURI(r.URL.Path),
+		canonicalQuery(r.URL.Query(), ""),
+		canonicalHeaders(r, signedHeaders),
+		fields["SignedHeaders"],
+		payloadHash,
+	}, "\n")
+
+	if !sig.matches(canonicalRequest, fields["Signature"]) {
+		return nil, errSignatureDoesNotMatch
+	}
+	sig.seed = fields["Signature"]
+	return sig, nil
+}
+
+// verifyPresigned 校验查询参数中携带的预签名
+func (g *Gateway) verifyPresigned(r *http.Request) (*signature, *s3Error) {
+	query := r.URL.Query()
+	if query.Get("X-Amz-Algorithm") != signV4Algorithm {
+		return nil, errSignatureVersionNotSupported
+	}
+
+	amzDate := query.Get("X-Amz-Date")
+	requestTime, err := time.Parse(amzDateFormat, amzDate)
+	if err != nil {
+		return nil, errAccessDenied
+	}
+	expires, err := strconv.ParseInt(query.Get("X-Amz-Expires"), 10, 64)
+	if err != nil || expires < 0 || time.Duration(expires)*time.Second > maxPresignExpires {
+		return nil, errAccessDenied
+	}
+	now := g.now()
+	if now.After(requestTime.Add(time.Duration(expires) * time.Second)) {
+		return nil, errExpiredPresignRequest
+	}
+	// 签名时间晚于服务器时间超过允许的偏差时拒绝，避免提前生成有效期超出上限的链接
+	if requestTime.Sub(now) > maxClockSkew {
+		return nil, errRequestTimeTooSkewed
+	}
+
+	sig, s3Err := g.newSignature(query.Get("X-Amz-Credential"), amzDate, unsignedPayload)
+	if s3Err != nil {
+		return nil, s3Err
+	}
+
+	signedHeaders := strings.Split(query.Get("X-Amz-SignedHeaders"), ";")
+	if !signsHeaders(signedHeaders, "host") {
+		return nil, errAccessDenied
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		canonicalURI(r.URL.Path),
+		canonicalQuery(query, "X-Amz-Signature"),
+		canonicalHeaders(r, signedHeaders),
+		query.Get("X-Amz-SignedHeaders"),
+		unsignedPayload,
+	}, "\n")
+
+	if !sig.matches(canonicalRequest, query.Get("X-Amz-Signature")) {
+		return nil, errSignatureDoesNotMatch
+	}
+	return sig, nil
+}
+
+// newSignature 解析 Credential 并根据本地配置的 SecretKey 派生签名密钥
+func (g *Gateway) newSignature(credential, amzDate, payloadHash string) (*signature, *s3Error) {
+	// Credential 格式为 AccessKey/日期/区域/服务/aws4_request
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[3] != "s3" || parts[4] != "aws4_request" {
+		return nil, errAuthorizationHeaderMalformed
+	}
+	if !strings.HasPrefix(amzDate, parts[1]) {
+		return nil, errAuthorizationHeaderMalformed
+	}
+
+	secretKey, ok := g.credentials[parts[0]]
+	if !ok {
+		return nil, errInvalidAccessKeyID
+	}
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretKey), parts[1])
+	for _, part := range parts[2:] {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+
+	return &signature{
+		accessKey:   parts[0],
+		amzDate:     amzDate,
+		scope:       strings.Join(parts[1:], "/"),
+		signingKey:  signingKey,
+		payloadHash: payloadHash,
+	}, nil
+}
+
+// matches 计算待签名字符串的签名并与请求中的签名比较
+func (s *signature) matches(canonicalRequest, expected string) bool {
+	stringToSign := strings.Join([]string{
+		signV4Algorithm,
+		s.amzDate,
+		s.scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+	return hmac.Equal([]byte(s.sign(stringToSign)), []byte(expected))
+}
+
+func (s *signature) sign(stringToSign string) string {
+	return hex.EncodeToString(hmacSHA256(s.signingKey, stringToSign))
+}
+
+func canonicalURI(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return uriEncode(path, false)
+}
+
+// canonicalQuery 按参数名和值排序并编码查询参数，exclude 指定不参与签名的参数
+func canonicalQuery(query url.Values, exclude string) string {
+	pairs := make([]string, 0, len(query))
+	for key, values := range query {
+		if key == exclude {
+			continue
+		}
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// signsHeaders 判断签名的请求头是否包含 required 中的所有请求头
+func signsHeaders(signedHeaders []string, required ...string) bool {
+	for _, name := range required {
+		if !slices.Contains(signedHeaders, name) {
+			return false
+		}
+	}
+	return true
+}
+
+func canonicalHeaders(r *http.Request, signedHeaders []string) string {
+	var b strings.Builder
+	for _, name := range signedHeaders {
+		var value string
+		if name == "host" {
+			value = r.Host
+		} else {
+			values := r.Header.Values(name)
+			for i := range values {
+				values[i] = strings.Join(strings.Fields(values[i]), " ")
+			}
+			value = strings.Join(values, ",")
+		}
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(value)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// uriEncode 按 AWS 的规则编码，只保留非保留字符，encodeSlash 为 false 时保留路径分隔符
+func uriEncode(value string, encodeSlash bool) string {
+	const hexChars = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexChars[c>>4])
+			b.WriteByte(hexChars[c&15])
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"dooqiniu/internal/model"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ValidateBatchOperation 校验单个批量操作的参数
+func ValidateBatchOperation(op model.BatchOperation) error {
+	switch op.Op {
+	case model.BatchOpDelete, model.BatchOpStat:
+		if op.ObjectName == "" {
+			return fmt.Errorf("objectName is required for %s", op.Op)
+		}
+	case model.BatchOpCopy, model.BatchOpMove:
+		if op.SrcObject == "" || op.DestObject == "" {
+			return fmt.Errorf("srcObject and destObject are required for %s", op.Op)
+		}
+	case model.BatchOpChtype:
+		if op.ObjectName == "" {
+			return fmt.Errorf("objectName is required for %s", op.Op)
+		}
+		return ValidateStorageType(op.Type)
+	case model.BatchOpRestore:
+		if op.ObjectName == "" {
+			return fmt.Errorf("objectName is required for %s", op.Op)
+		}
+		return ValidateFreezeAfterDays(op.FreezeAfterDays)
+	case model.BatchOpLifecycle:
+		if op.ObjectName == "" {
+			return fmt.Errorf("objectName is required for %s", op.Op)
+		}
+		if op.Lifecycle == nil {
+			return fmt.Errorf("lifecycle is required for %s", op.Op)
+		}
+		return ValidateLifecycleRule(*op.Lifecycle)
+	default:
+		return fmt.Errorf("unknown operation: %s", op.Op)
+	}
+	return nil
+}
+
+// batchEach 逐个执行批量操作，供没有批量接口的本地和内存后端使用
+func batchEach(s Storage, ops []model.BatchOperation) []model.BatchResult {
+	results := make([]model.BatchResult, len(ops))
+	for i, op := range ops {
+		var (
+			info *model.FileInfo
+			err  error
+		)
+		switch op.Op {
+		case model.BatchOpDelete:
+			err = s.Delete(op.ObjectName)
+		case model.BatchOpCopy:
+			err = s.Copy(op.SrcObject, op.DestObject, op.Force)
+		case model.BatchOpMove:
+			err = s.Move(op.SrcObject, op.DestObject, op.Force)
+		case model.BatchOpStat:
+			info, err = s.Stat(op.ObjectName)
+		case model.BatchOpChtype:
+			err = s.ChangeType(op.ObjectName, op.Type)
+		case model.BatchOpRestore:
+			err = s.Restore(op.ObjectName, op.FreezeAfterDays)
+		case model.BatchOpLifecycle:
+			err = s.SetLifecycle(op.ObjectName, *op.Lifecycle)
+		default:
+			err = ErrOperationNotSupported
+		}
+		results[i] = batchResult(info, err)
+	}
+	return results
+}
+
+func batchResult(info *model.FileInfo, err error) model.BatchResult {
+	if err == nil {
+		return model.BatchResult{Code: http.StatusOK, Data: info}
+	}
+
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrObjectNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, ErrObjectExists):
+		code = http.StatusConflict
+	case errors.Is(err, ErrOperationNotSupported):
+		code = http.StatusBadRequest
+	}
+	return model.BatchResult{Code: code, Error: err.Error()}
+}
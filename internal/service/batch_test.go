@@ -0,0 +1,157 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"dooqiniu/internal/model"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+// partialBatchStorage 只执行前 executed 个批量操作，模拟之后的批量请求失败
+type partialBatchStorage struct {
+	*MemoryStorage
+	executed int
+}
+
+var errBatchRequest = errors.New("connection reset")
+
+func (s *partialBatchStorage) Batch(ops []model.BatchOperation) ([]model.BatchResult, error) {
+	n := min(s.executed, len(ops))
+	results, _ := s.MemoryStorage.Batch(ops[:n])
+	if n == len(ops) {
+		return results, nil
+	}
+	return notExecuted(results, len(ops), errBatchRequest), errBatchRequest
+}
+
+func newPartialBatchStorage(t *testing.T, executed int, keys ...string) *partialBatchStorage {
+	t.Helper()
+	s := &partialBatchStorage{MemoryStorage: NewMemoryStorage(), executed: executed}
+	for _, key := range keys {
+		if _, err := s.Upload(bytes.NewReader([]byte(key)), key, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestBatchFailureKeepsExecutedResults(t *testing.T) {
+	keys := []string{"a", "b", "c", "d"}
+
+	t.Run("ApplyToObjects", func(t *testing.T) {
+		s := newPartialBatchStorage(t, 2, keys...)
+		summary, err := ApplyToObjects(s, keys, "", func(key string) model.BatchOperation {
+			return model.BatchOperation{Op: model.BatchOpDelete, ObjectName: key}
+		})
+		if !errors.Is(err, errBatchRequest) {
+			t.Fatalf("err = %v, want %v", err, errBatchRequest)
+		}
+		if summary.Succeeded != 2 || summary.Failed != 2 {
+			t.Errorf("summary = %+v, want 2 succeeded and 2 failed", summary)
+		}
+		for _, e := range summary.Errors {
+			if e.Code != BatchCodeNotExecuted {
+				t.Errorf("error for %s has code %d, want %d", e.Key, e.Code, BatchCodeNotExecuted)
+			}
+		}
+	})
+
+	t.Run("DeletePrefix", func(t *testing.T) {
+		s := newPartialBatchStorage(t, 3, keys...)
+		result, err := DeletePrefix(context.Background(), s, "", nil, nil)
+		if !errors.Is(err, errBatchRequest) {
+			t.Fatalf("err = %v, want %v", err, errBatchRequest)
+		}
+		if result.Deleted != 3 || result.Failed != 1 {
+			t.Errorf("result = %+v, want 3 deleted and 1 failed", result)
+		}
+	})
+
+	t.Run("IndexedStorage", func(t *testing.T) {
+		backend := newPartialBatchStorage(t, 1, keys...)
+		idx, err := OpenMetadataIndex(filepath.Join(t.TempDir(), "index.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idx.Close()
+		for _, key := range keys {
+			info, _ := backend.Stat(key)
+			idx.Put(*info)
+		}
+
+		s := NewIndexedStorage(backend, idx)
+		results, err := s.Batch([]model.BatchOperation{
+			{Op: model.BatchOpDelete, ObjectName: "a"},
+			{Op: model.BatchOpDelete, ObjectName: "b"},
+		})
+		if !errors.Is(err, errBatchRequest) || len(results) != 2 {
+			t.Fatalf("results = %+v, err = %v", results, err)
+		}
+		if results[0].Code != http.StatusOK || results[1].Code != BatchCodeNotExecuted {
+			t.Errorf("codes = %d, %d", results[0].Code, results[1].Code)
+		}
+		if _, err := idx.Stat("a"); !errors.Is(err, ErrObjectNotFound) {
+			t.Errorf("deleted object a still indexed: %v", err)
+		}
+		if _, err := idx.Stat("b"); err != nil {
+			t.Errorf("object b removed from index although not deleted: %v", err)
+		}
+	})
+}
+
+// statWithoutMetaStorage 与七牛云的批量接口一样，批量 stat 的结果不包含自定义元数据
+type statWithoutMetaStorage struct {
+	*MemoryStorage
+}
+
+func (s *statWithoutMetaStorage) Batch(ops []model.BatchOperation) ([]model.BatchResult, error) {
+	results, err := s.MemoryStorage.Batch(ops)
+	for i := range results {
+		if results[i].Data != nil {
+			data := *results[i].Data
+			data.MetaData = nil
+			results[i].Data = &data
+		}
+	}
+	return results, err
+}
+
+func TestIndexedBatchKeepsMetaData(t *testing.T) {
+	idx, err := OpenMetadataIndex(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	s := NewIndexedStorage(&statWithoutMetaStorage{NewMemoryStorage()}, idx)
+	meta := map[string]string{"owner": "alice"}
+	for _, key := range []string{"a", "b"} {
+		if _, err := s.Upload(bytes.NewReader([]byte(key)), key, "", meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = s.Batch([]model.BatchOperation{
+		{Op: model.BatchOpStat, ObjectName: "a"},
+		{Op: model.BatchOpCopy, SrcObject: "a", DestObject: "copy"},
+		{Op: model.BatchOpMove, SrcObject: "b", DestObject: "moved"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"a", "copy", "moved"} {
+		info, err := idx.Stat(key)
+		if err != nil {
+			t.Fatalf("%s not indexed: %v", key, err)
+		}
+		if info.MetaData["owner"] != "alice" {
+			t.Errorf("%s metadata = %v, want owner=alice", key, info.MetaData)
+		}
+	}
+	if _, err := idx.Stat("b"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("moved source b still indexed: %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"dooqiniu/internal/model"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	// ErrUnknownBucket 表示请求的存储空间不在 QINIU_BUCKETS 或账号的 buckets 配置中
+	ErrUnknownBucket = errors.New("unknown bucket")
+	// ErrUnknownProfile 表示请求的账号不在 QINIU_PROFILES 配置中
+	ErrUnknownProfile = errors.New("unknown profile")
+)
+
+// storageKey 标识一个账号下的一个存储空间，默认账号和默认存储空间为空字符串
+type storageKey struct {
+	profile string
+	bucket  string
+}
+
+var (
+	// 内存后端的每个命名存储空间在进程内共用一个实例
+	memoryBuckets sync.Map
+	// 七牛云客户端按账号和存储空间缓存，配置变化时替换
+	qiniuClients sync.Map
+)
+
+// NewBucketStorage 创建默认账号下命名存储空间的存储后端，name 为空时与 NewStorage 相同
+func NewBucketStorage(cfg *model.Config, name string) (Storage, error) {
+	return NewProfileStorage(cfg, "", name)
+}
+
+// NewProfileStorage 创建 profile 账号下 bucket 存储空间的存储后端，bucket 为空时使用账号的默认存储空间，
+// 两者都为空时与 NewStorage 相同。元数据索引只覆盖默认账号的默认存储空间，其他存储空间的写操作不会更新索引
+func NewProfileStorage(cfg *model.Config, profile, bucket string) (Storage, error) {
+	if profile == "" && bucket == "" {
+		return NewStorage(cfg)
+	}
+
+	key := storageKey{profile: profile, bucket: bucket}
+	// 所有后端都只接受配置中存在的账号和存储空间
+	if _, err := resolveQiniuSettings(cfg, key); err != nil {
+		return nil, err
+	}
+
+	switch cfg.StorageBackend {
+	case "", StorageBackendQiniu:
+		return qiniuProfileClient(cfg, key)
+	case StorageBackendLocal:
+		dir := cfg.LocalStorageDir
+		if profile != "" {
+			dir = filepath.Join(dir, "profiles", profile)
+		}
+		if bucket != "" {
+			dir = filepath.Join(dir, "buckets", bucket)
+		}
+		return NewLocalStorage(dir), nil
+	case StorageBackendMemory:
+		storage, _ := memoryBuckets.LoadOrStore(key, NewMemoryStorage())
+		return storage.(*MemoryStorage), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.StorageBackend)
+	}
+}
+
+// qiniuClientSettings 创建七牛云客户端使用的账号和存储空间配置，用于判断缓存的客户端是否仍然有效
+type qiniuClientSettings struct {
+	accessKey string
+	secretKey string
+	profile   model.BucketProfile
+}
+
+// qiniuClientEntry 缓存的七牛云客户端及创建它时使用的配置
+type qiniuClientEntry struct {
+	settings qiniuClientSettings
+	client   *QiniuCommoner
+}
+
+// resolveQiniuSettings 按账号和存储空间名称查找配置，名称未配置时返回错误
+func resolveQiniuSettings(cfg *model.Config, key storageKey) (qiniuClientSettings, error) {
+	settings := qiniuClientSettings{accessKey: cfg.QiniuAccessKey, secretKey: cfg.QiniuSecretKey}
+	if key.profile == "" {
+		settings.profile = model.BucketProfile{Bucket: cfg.QiniuBucket, Endpoint: cfg.QiniuEndpoint, Region: cfg.QiniuRegion}
+		if key.bucket != "" {
+			profile, ok := cfg.Buckets[key.bucket]
+			if !ok {
+				return settings, fmt.Errorf("%w: %s", ErrUnknownBucket, key.bucket)
+			}
+			settings.profile = profile
+		}
+		return settings, nil
+	}
+
+	account, ok := cfg.Profiles[key.profile]
+	if !ok {
+		return settings, fmt.Errorf("%w: %s", ErrUnknownProfile, key.profile)
+	}
+	settings.accessKey, settings.secretKey = account.AccessKey, account.SecretKey
+	settings.profile = model.BucketProfile{Bucket: account.Bucket, Endpoint: account.Endpoint, Region: account.Region}
+	if key.bucket != "" {
+		if settings.profile, ok = account.Buckets[key.bucket]; !ok {
+			return settings, fmt.Errorf("%w: %s", ErrUnknownBucket, key.bucket)
+		}
+	}
+	return settings, nil
+}
+
+// qiniuProfileClient 先按账号和存储空间查找缓存的客户端，只在没有缓存或配置已变化时创建新的客户端
+func qiniuProfileClient(cfg *model.Config, key storageKey) (*QiniuCommoner, error) {
+	settings, err := resolveQiniuSettings(cfg, key)
+	if err != nil {
+		return nil, err
+	}
+	if cached, ok := qiniuClients.Load(key); ok && cached.(*qiniuClientEntry).settings == settings {
+		return cached.(*qiniuClientEntry).client, nil
+	}
+
+	client := NewQiniuAccountClient(settings.accessKey, settings.secretKey, settings.profile)
+	qiniuClients.Store(key, &qiniuClientEntry{settings: settings, client: client})
+	return client, nil
+}
+
+// PruneClientCache 删除在新配置 cfg 中已不存在或配置已变化的账号和存储空间的七牛云客户端，
+// 配置不变的客户端继续使用。配置切换且旧配置上的请求全部完成后调用
+func PruneClientCache(_, cfg *model.Config) {
+	qiniuClients.Range(func(key, value any) bool {
+		settings, err := resolveQiniuSettings(cfg, key.(storageKey))
+		if err != nil || settings != value.(*qiniuClientEntry).settings {
+			qiniuClients.CompareAndDelete(key, value)
+		}
+		return true
+	})
+}
+
+// TransferObject 在两个不同的存储空间之间复制或移动对象，force 为 false 时目标对象已存在会返回 ErrObjectExists。
+// 两端都是七牛云时使用服务端复制，否则读取源对象的内容写入目标存储空间
+func TransferObject(src, dest Storage, srcKey, destKey string, force, move bool) error {
+	if srcQiniu, destQiniu := qiniuBackend(src), qiniuBackend(dest); srcQiniu != nil && destQiniu != nil {
+		var err error
+		if move {
+			err = srcQiniu.MoveToBucket(srcKey, destQiniu.bucketName, destKey, force)
+		} else {
+			err = srcQiniu.CopyToBucket(srcKey, destQiniu.bucketName, destKey, force)
+		}
+		if err != nil {
+			return err
+		}
+
+		if indexed, ok := src.(*IndexedStorage); ok && move {
+			indexed.index.Delete(srcKey)
+		}
+		if indexed, ok := dest.(*IndexedStorage); ok {
+			indexed.refresh(destKey)
+		}
+		return nil
+	}
+
+	action := "copy"
+	if move {
+		action = "move"
+	}
+
+	info, err := src.Stat(srcKey)
+	if err != nil {
+		return fmt.Errorf("failed to %s file: %w", action, err)
+	}
+	if !force {
+		_, err := dest.Stat(destKey)
+		if err == nil {
+			return fmt.Errorf("failed to %s file: %w", action, ErrObjectExists)
+		}
+		if !errors.Is(err, ErrObjectNotFound) {
+			return fmt.Errorf("failed to %s file: %w", action, err)
+		}
+	}
+
+	reader, err := src.Open(srcKey, 0, -1)
+	if err != nil {
+		return fmt.Errorf("failed to %s file: %w", action, err)
+	}
+	defer reader.Close()
+
+	// 上传只能新增对象，强制覆盖时通过 PutObject 替换已存在的目标对象
+	if force {
+		_, err = PutObject(dest, reader, destKey, info.MimeType, info.MetaData)
+	} else {
+		_, err = dest.Upload(reader, destKey, info.MimeType, info.MetaData)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to %s file: %w", action, err)
+	}
+	if move {
+		if err := src.Delete(srcKey); err != nil {
+			return fmt.Errorf("failed to %s file: %w", action, err)
+		}
+	}
+	return nil
+}
+
+// qiniuBackend 返回存储后端底层的七牛云客户端，不是七牛云后端时返回 nil
+func qiniuBackend(s Storage) *QiniuCommoner {
+	if indexed, ok := s.(*IndexedStorage); ok {
+		s = indexed.Storage
+	}
+	q, _ := s.(*QiniuCommoner)
+	return q
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"compress/gzip"
+	"dooqiniu/internal/model"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	ExportFormatNDJSON = "ndjson"
+	ExportFormatCSV    = "csv"
+)
+
+// exportColumns CSV 导出的表头，与 ExportRecord 的 JSON 字段一致
+var exportColumns = []string{"key", "size", "hash", "put_time", "mime_type", "storage_type"}
+
+// ExportRecord 清单导出中的一行
+type ExportRecord struct {
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	Hash        string    `json:"hash"`
+	PutTime     time.Time `json:"put_time"`
+	MimeType    string    `json:"mime_type"`
+	StorageType int       `json:"storage_type"`
+}
+
+// ListingWriter 将对象清单按 NDJSON 或 CSV 格式写出，可选 gzip 压缩
+type ListingWriter struct {
+	gz      *gzip.Writer
+	csv     *csv.Writer
+	encoder *json.Encoder
+}
+
+// NewListingWriter 创建清单写入器，CSV 格式会先写出表头
+func NewListingWriter(w io.Writer, format string, compress bool) (*ListingWriter, error) {
+	lw := &ListingWriter{}
+	if compress {
+		lw.gz = gzip.NewWriter(w)
+		w = lw.gz
+	}
+
+	switch format {
+	case ExportFormatNDJSON:
+		lw.encoder = json.NewEncoder(w)
+	case ExportFormatCSV:
+		lw.csv = csv.NewWriter(w)
+		if err := lw.csv.Write(exportColumns); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown export format: %s", format)
+	}
+	return lw, nil
+}
+
+// Write 写出一页对象
+func (lw *ListingWriter) Write(files []model.FileInfo) error {
+	for _, file := range files {
+		record := ExportRecord{
+			Key:         file.Key,
+			Size:        file.ContentLength,
+			Hash:        file.ETag,
+			PutTime:     file.LastModified,
+			MimeType:    file.MimeType,
+			StorageType: file.StorageType,
+		}
+
+		var err error
+		if lw.csv != nil {
+			err = lw.csv.Write([]string{
+				record.Key,
+				strconv.FormatInt(record.Size, 10),
+				record.Hash,
+				record.PutTime.Format(time.RFC3339),
+				record.MimeType,
+				strconv.Itoa(record.StorageType),
+			})
+		} else {
+			err = lw.encoder.Encode(record)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush 将缓冲的数据写到底层 io.Writer，用于每页输出后及时推送给客户端
+func (lw *ListingWriter) Flush() error {
+	if lw.csv != nil {
+		lw.csv.Flush()
+		if err := lw.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if lw.gz != nil {
+		return lw.gz.Flush()
+	}
+	return nil
+}
+
+// Close 刷新缓冲并结束 gzip 流，不会关闭底层 io.Writer
+func (lw *ListingWriter) Close() error {
+	if err := lw.Flush(); err != nil {
+		return err
+	}
+	if lw.gz != nil {
+		return lw.gz.Close()
+	}
+	return nil
+}
+
+// ExportListing 遍历前缀下的所有对象并写入 lw，每写完一页调用一次 flush，返回导出的对象数量
+func ExportListing(s Storage, prefix string, lw *ListingWriter, flush func()) (int64, error) {
+	it := NewListingIterator(s, prefix)
+
+	var count int64
+	for {
+		files, err := it.Next()
+		if err == io.EOF {
+			return count, lw.Close()
+		}
+		if err != nil {
+			return count, err
+		}
+		if err := lw.Write(files); err != nil {
+			return count, err
+		}
+		if err := lw.Flush(); err != nil {
+			return count, err
+		}
+		count += int64(len(files))
+		if flush != nil {
+			flush()
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"dooqiniu/internal/model"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// imageFormats 七牛云图片处理支持的输出格式
+var imageFormats = map[string]bool{
+	"jpg": true, "jpeg": true, "png": true, "webp": true, "gif": true,
+	"bmp": true, "tiff": true, "avif": true, "heic": true,
+}
+
+var (
+	// imageMogr2 的缩放参数，例如 !50p、200x、x200、200x200!、200x200>、40000@
+	thumbnailPattern = regexp.MustCompile(`^(!?\d{1,5}p|!\d{1,5}px|!px\d{1,5}|\d{1,5}x|x\d{1,5}|!?\d{1,5}x\d{1,5}[!<>r]?|\d{1,9}@)$`)
+	// imageMogr2 的裁剪参数，例如 200x200、!200x200a10a10
+	cropPattern = regexp.MustCompile(`^!?(\d{1,5}x|x\d{1,5}|\d{1,5}x\d{1,5})(a\d{1,5}a\d{1,5})?$`)
+	blurPattern = regexp.MustCompile(`^\d{1,2}x\d{1,2}$`)
+)
+
+// 缩放参数中的 < 和 > 不能直接出现在链接中
+var fopEscaper = strings.NewReplacer("<", "%3C", ">", "%3E")
+
+var gravities = map[string]bool{
+	"NorthWest": true, "North": true, "NorthEast": true, "West": true, "Center": true,
+	"East": true, "SouthWest": true, "South": true, "SouthEast": true,
+}
+
+// BuildImageFop 校验 imageView2 和 imageMogr2 参数并生成七牛云数据处理指令，
+// 只允许白名单中的参数，避免调用方拼接任意处理指令（如 saveas）
+func BuildImageFop(imageView2, imageMogr2 string) (string, error) {
+	var fops []string
+
+	if imageView2 != "" {
+		fop, err := buildImageView2(imageView2)
+		if err != nil {
+			return "", err
+		}
+		fops = append(fops, fop)
+	}
+	if imageMogr2 != "" {
+		fop, err := buildImageMogr2(imageMogr2)
+		if err != nil {
+			return "", err
+		}
+		fops = append(fops, fop)
+	}
+	return strings.Join(fops, "|"), nil
+}
+
+// buildImageView2 参数格式为 <mode>/w/<width>/h/<height>/format/<format>/q/<quality>
+func buildImageView2(value string) (string, error) {
+	parts := strings.Split(value, "/")
+	mode, err := strconv.Atoi(parts[0])
+	if err != nil || mode < 0 || mode > 5 {
+		return "", fmt.Errorf("invalid imageView2 mode: %s", parts[0])
+	}
+	if len(parts)%2 != 1 {
+		return "", fmt.Errorf("invalid imageView2 parameters: %s", value)
+	}
+
+	for i := 1; i < len(parts); i += 2 {
+		name, arg := parts[i], parts[i+1]
+		var ok bool
+		switch name {
+		case "w", "h":
+			ok = intInRange(arg, 1, 9999)
+		case "q":
+			ok = intInRange(arg, 1, 100)
+		case "format":
+			ok = imageFormats[arg]
+		case "interlace":
+			ok = arg == "0" || arg == "1"
+		case "ignore-error":
+			ok = arg == "1"
+		}
+		if !ok {
+			return "", fmt.Errorf("invalid imageView2 parameter: %s/%s", name, arg)
+		}
+	}
+	return "imageView2/" + value, nil
+}
+
+// buildImageMogr2 参数格式为 auto-orient/thumbnail/<size>/crop/<size>/rotate/<deg>/format/<format>/quality/<q>
+func buildImageMogr2(value string) (string, error) {
+	parts := strings.Split(value, "/")
+	for i := 0; i < len(parts); i++ {
+		name := parts[i]
+		// 不带值的参数
+		if name == "auto-orient" || name == "strip" {
+			continue
+		}
+		if i+1 >= len(parts) {
+			return "", fmt.Errorf("invalid imageMogr2 parameter: %s", name)
+		}
+		i++
+		arg := parts[i]
+
+		var ok bool
+		switch name {
+		case "thumbnail":
+			ok = thumbnailPattern.MatchString(arg)
+		case "crop":
+			ok = cropPattern.MatchString(arg)
+		case "gravity":
+			ok = gravities[arg]
+		case "rotate":
+			ok = intInRange(arg, 0, 360)
+		case "blur":
+			ok = blurPattern.MatchString(arg)
+		case "quality":
+			ok = intInRange(arg, 1, 100)
+		case "format":
+			ok = imageFormats[arg]
+		case "interlace":
+			ok = arg == "0" || arg == "1"
+		}
+		if !ok {
+			return "", fmt.Errorf("invalid imageMogr2 parameter: %s/%s", name, arg)
+		}
+	}
+	return "imageMogr2/" + value, nil
+}
+
+func intInRange(value string, min, max int) bool {
+	n, err := strconv.Atoi(value)
+	return err == nil && n >= min && n <= max
+}
+
+// urlQuery 根据下载选项生成链接的查询字符串（不含 ?），数据处理指令在前，attname 在后
+func urlQuery(opts *model.URLOptions) string {
+	if opts == nil {
+		return ""
+	}
+
+	var params []string
+	if opts.Fop != "" {
+		params = append(params, fopEscaper.Replace(opts.Fop))
+	}
+	if opts.AttName != "" {
+		params = append(params, "attname="+url.QueryEscape(opts.AttName))
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return strings.Join(params, "&")
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"dooqiniu/internal/model"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// IndexedStorage 在存储后端的写操作成功后同步更新元数据索引。
+// 索引更新失败不影响操作结果，由定期对账修正
+type IndexedStorage struct {
+	Storage
+	index *MetadataIndex
+}
+
+// NewIndexedStorage 为存储后端附加索引维护
+func NewIndexedStorage(s Storage, index *MetadataIndex) *IndexedStorage {
+	return &IndexedStorage{Storage: s, index: index}
+}
+
+// refresh 从存储后端重新获取对象信息并写入索引
+func (s *IndexedStorage) refresh(objectName string) {
+	info, err := s.Storage.Stat(objectName)
+	switch {
+	case err == nil:
+		s.index.Put(*info)
+	case errors.Is(err, ErrObjectNotFound):
+		s.index.Delete(objectName)
+	}
+}
+
+func (s *IndexedStorage) Upload(file io.Reader, objectName, contentType string, metaData map[string]string) (*model.UploadResponse, error) {
+	resp, err := s.Storage.Upload(file, objectName, contentType, metaData)
+	if err == nil {
+		s.refresh(objectName)
+	}
+	return resp, err
+}
+
+// Stat 顺便用查询结果更新索引
+func (s *IndexedStorage) Stat(objectName string) (*model.FileInfo, error) {
+	info, err := s.Storage.Stat(objectName)
+	switch {
+	case err == nil:
+		s.index.Put(*info)
+	case errors.Is(err, ErrObjectNotFound):
+		s.index.Delete(objectName)
+	}
+	return info, err
+}
+
+func (s *IndexedStorage) Delete(objectName string) error {
+	err := s.Storage.Delete(objectName)
+	if err == nil || errors.Is(err, ErrObjectNotFound) {
+		s.index.Delete(objectName)
+	}
+	return err
+}
+
+func (s *IndexedStorage) Copy(srcKey, destKey string, force bool) error {
+	err := s.Storage.Copy(srcKey, destKey, force)
+	if err == nil {
+		s.refresh(destKey)
+	}
+	return err
+}
+
+func (s *IndexedStorage) Move(srcObject, destObject string, force bool) error {
+	err := s.Storage.Move(srcObject, destObject, force)
+	if err == nil {
+		s.index.Delete(srcObject)
+		s.refresh(destObject)
+	}
+	return err
+}
+
+func (s *IndexedStorage) ChangeMeta(objectName, mimeType string, metaData map[string]string, replace bool) error {
+	err := s.Storage.ChangeMeta(objectName, mimeType, metaData, replace)
+	if err == nil {
+		s.refresh(objectName)
+	}
+	return err
+}
+
+func (s *IndexedStorage) ChangeType(objectName string, storageType int) error {
+	err := s.Storage.ChangeType(objectName, storageType)
+	if err == nil {
+		s.refresh(objectName)
+	}
+	return err
+}
+
+func (s *IndexedStorage) Restore(objectName string, freezeAfterDays int) error {
+	err := s.Storage.Restore(objectName, freezeAfterDays)
+	if err == nil {
+		s.refresh(objectName)
+	}
+	return err
+}
+
+func (s *IndexedStorage) SetLifecycle(objectName string, rule model.LifecycleRule) error {
+	err := s.Storage.SetLifecycle(objectName, rule)
+	if err == nil {
+		s.refresh(objectName)
+	}
+	return err
+}
+
+// Batch 执行完成后，用一次批量 stat 获取复制、移动、修改存储类型和解冻后的对象信息
+func (s *IndexedStorage) Batch(ops []model.BatchOperation) ([]model.BatchResult, error) {
+	// 请求中途失败时，已执行的操作仍需要同步到索引
+	results, err := s.Storage.Batch(ops)
+	if len(results) != len(ops) {
+		return results, err
+	}
+
+	var (
+		stats []model.BatchOperation
+		// metaKeys 为 stats 中每个对象沿用元数据的索引条目，moved 为移动的源对象，stat 完成后再从索引中删除
+		metaKeys []string
+		moved    []string
+	)
+	for i, op := range ops {
+		result := results[i]
+		if result.Code != http.StatusOK {
+			if result.Code == http.StatusNotFound && (op.Op == model.BatchOpDelete || op.Op == model.BatchOpStat) {
+				s.index.Delete(op.ObjectName)
+			}
+			continue
+		}
+
+		switch op.Op {
+		case model.BatchOpDelete:
+			s.index.Delete(op.ObjectName)
+		case model.BatchOpStat:
+			if result.Data != nil {
+				s.putBatchStat(*result.Data, op.ObjectName)
+			}
+		case model.BatchOpCopy:
+			stats = append(stats, model.BatchOperation{Op: model.BatchOpStat, ObjectName: op.DestObject})
+			metaKeys = append(metaKeys, op.SrcObject)
+		case model.BatchOpMove:
+			stats = append(stats, model.BatchOperation{Op: model.BatchOpStat, ObjectName: op.DestObject})
+			metaKeys = append(metaKeys, op.SrcObject)
+			moved = append(moved, op.SrcObject)
+		case model.BatchOpChtype, model.BatchOpRestore:
+			stats = append(stats, model.BatchOperation{Op: model.BatchOpStat, ObjectName: op.ObjectName})
+			metaKeys = append(metaKeys, op.ObjectName)
+		}
+	}
+
+	if len(stats) > 0 {
+		statResults, err := s.Storage.Batch(stats)
+		if err == nil && len(statResults) == len(stats) {
+			for i, result := range statResults {
+				if result.Code == http.StatusOK && result.Data != nil {
+					s.putBatchStat(*result.Data, metaKeys[i])
+				}
+			}
+		}
+	}
+	for _, key := range moved {
+		s.index.Delete(key)
+	}
+	return results, err
+}
+
+// putBatchStat 将批量 stat 的结果写入索引。批量 stat 不返回自定义元数据，沿用索引中 metaKey 条目的元数据，
+// 复制、移动的目标对象沿用源对象的元数据；索引中没有 metaKey 时改为对该对象执行完整的 Stat
+func (s *IndexedStorage) putBatchStat(info model.FileInfo, metaKey string) {
+	if info.MetaData == nil {
+		existing, err := s.index.Stat(metaKey)
+		if err != nil {
+			s.refresh(info.Key)
+			return
+		}
+		info.MetaData = existing.MetaData
+	}
+	s.index.Put(info)
+}
+
+func (s *IndexedStorage) CompleteMultipartUpload(objectName, uploadID, contentType string, parts []model.UploadedPart) (*model.UploadResponse, error) {
+	resp, err := s.Storage.CompleteMultipartUpload(objectName, uploadID, contentType, parts)
+	if err == nil {
+		s.refresh(objectName)
+	}
+	return resp, err
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"dooqiniu/internal/model"
+	"fmt"
+	"net/http"
+
+	"github.com/qiniu/go-sdk/v7/storage"
+)
+
+// 七牛云单次批量请求允许的最大操作数
+const qiniuBatchLimit = 1000
+
+// BatchCodeNotExecuted 批量请求失败导致没有执行的操作的结果状态码
+const BatchCodeNotExecuted = http.StatusServiceUnavailable
+
+// Batch 使用七牛云批量接口执行操作，超过单次上限时分多次请求。
+// 某次请求失败时，之前已执行的操作仍返回实际结果，未执行的操作标记为 BatchCodeNotExecuted，同时返回错误
+func (q *QiniuCommoner) Batch(ops []model.BatchOperation) ([]model.BatchResult, error) {
+	results := make([]model.BatchResult, 0, len(ops))
+	for start := 0; start < len(ops); start += qiniuBatchLimit {
+		end := min(start+qiniuBatchLimit, len(ops))
+
+		commands := make([]string, 0, end-start)
+		for _, op := range ops[start:end] {
+			commands = append(commands, q.batchCommand(op))
+		}
+
+		rets, err := q.bucketManager.Batch(commands)
+		if err == nil && len(rets) != len(commands) {
+			err = fmt.Errorf("expected %d results, got %d", len(commands), len(rets))
+		}
+		if err != nil {
+			err = fmt.Errorf("batch operation failed: %w", err)
+			return notExecuted(results, len(ops), err), err
+		}
+
+		for i, ret := range rets {
+			results = append(results, batchOpResult(ops[start+i], ret))
+		}
+	}
+	return results, nil
+}
+
+// notExecuted 将 results 之后直到 total 个的操作标记为未执行
+func notExecuted(results []model.BatchResult, total int, err error) []model.BatchResult {
+	for len(results) < total {
+		results = append(results, model.BatchResult{Code: BatchCodeNotExecuted, Error: "not executed: " + err.Error()})
+	}
+	return results
+}
+
+func (q *QiniuCommoner) batchCommand(op model.BatchOperation) string {
+	switch op.Op {
+	case model.BatchOpDelete:
+		return storage.URIDelete(q.bucketName, op.ObjectName)
+	case model.BatchOpCopy:
+		return storage.URICopy(q.bucketName, op.SrcObject, q.bucketName, op.DestObject, op.Force)
+	case model.BatchOpMove:
+		return storage.URIMove(q.bucketName, op.SrcObject, q.bucketName, op.DestObject, op.Force)
+	case model.BatchOpStat:
+		return storage.URIStat(q.bucketName, op.ObjectName)
+	case model.BatchOpRestore:
+		return storage.URIRestoreAr(q.bucketName, op.ObjectName, op.FreezeAfterDays)
+	case model.BatchOpLifecycle:
+		return uriLifecycle(q.bucketName, op.ObjectName, *op.Lifecycle)
+	default:
+		return storage.URIChangeType(q.bucketName, op.ObjectName, op.Type)
+	}
+}
+
+// batchOpResult 将七牛云的状态码转换为与 HTTP 状态码一致的结果
+func batchOpResult(op model.BatchOperation, ret storage.BatchOpRet) model.BatchResult {
+	switch ret.Code {
+	case 0, http.StatusOK:
+	case 612:
+		return model.BatchResult{Code: http.StatusNotFound, Error: ret.Data.Error}
+	case 614:
+		return model.BatchResult{Code: http.StatusConflict, Error: ret.Data.Error}
+	default:
+		return model.BatchResult{Code: ret.Code, Error: ret.Data.Error}
+	}
+
+	result := model.BatchResult{Code: http.StatusOK}
+	if op.Op == model.BatchOpStat {
+		result.Data = &model.FileInfo{
+			Key:           op.ObjectName,
+			ContentLength: ret.Data.Fsize,
+			ETag:          ret.Data.Hash,
+			MimeType:      ret.Data.MimeType,
+			LastModified:  putTimeToTime(ret.Data.PutTime),
+			StorageType:   ret.Data.Type,
+			EndUser:       ret.Data.EndUser,
+		}
+		if ret.Data.Status != nil {
+			result.Data.Status = *ret.Data.Status
+		}
+		if ret.Data.RestoreStatus != nil {
+			result.Data.RestoreStatus = *ret.Data.RestoreStatus
+		}
+	}
+	return result
+}
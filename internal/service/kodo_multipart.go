@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"dooqiniu/internal/model"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/qiniu/go-sdk/v7/storagev2/apis"
+	"github.com/qiniu/go-sdk/v7/storagev2/apis/resumable_upload_v2_complete_multipart_upload"
+	"github.com/qiniu/go-sdk/v7/storagev2/uptoken"
+)
+
+// newStorageAPI 创建七牛云分片上传 v2 接口客户端及上传凭证
+func (q *QiniuCommoner) newStorageAPI() (*apis.Storage, uptoken.Provider, error) {
+	putPolicy, err := uptoken.NewPutPolicy(q.bucketName, time.Now().Add(time.Hour))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create put policy: %w", err)
+	}
+
+	return q.storageAPI, uptoken.NewSigner(putPolicy, q.mac), nil
+}
+
+// InitiateMultipartUpload 初始化分片上传任务，返回 uploadId 及其过期时间
+func (q *QiniuCommoner) InitiateMultipartUpload(objectName string) (string, time.Time, error) {
+	storageAPI, upToken, err := q.newStorageAPI()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	response, err := storageAPI.ResumableUploadV2InitiateMultipartUpload(context.Background(), &apis.ResumableUploadV2InitiateMultipartUploadRequest{
+		BucketName: q.bucketName,
+		ObjectName: &objectName,
+		UpToken:    upToken,
+	}, nil)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+
+	return response.UploadId, time.Unix(response.ExpiredAt, 0).UTC(), nil
+}
+
+// UploadPart 上传单个分片，partMD5 为分片内容的十六进制 MD5，用于服务端校验
+func (q *QiniuCommoner) UploadPart(objectName, uploadID string, partNumber int64, part io.ReadSeeker, partMD5 string) (string, error) {
+	storageAPI, upToken, err := q.newStorageAPI()
+	if err != nil {
+		return "", err
+	}
+
+	response, err := storageAPI.ResumableUploadV2UploadPart(context.Background(), &apis.ResumableUploadV2UploadPartRequest{
+		BucketName: q.bucketName,
+		ObjectName: &objectName,
+		UploadId:   uploadID,
+		PartNumber: partNumber,
+		Md5:        partMD5,
+		UpToken:    upToken,
+		Body:       nopCloser{part},
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+	}
+
+	return response.Etag, nil
+}
+
+// ListParts 列出分片上传任务中已经上传成功的分片，按分片编号升序排列
+func (q *QiniuCommoner) ListParts(objectName, uploadID string) ([]model.UploadedPart, error) {
+	storageAPI, upToken, err := q.newStorageAPI()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		parts  []model.UploadedPart
+		marker int64
+	)
+	for {
+		response, err := storageAPI.ResumableUploadV2ListParts(context.Background(), &apis.ResumableUploadV2ListPartsRequest{
+			BucketName:       q.bucketName,
+			ObjectName:       &objectName,
+			UploadId:         uploadID,
+			PartNumberMarker: marker,
+			UpToken:          upToken,
+		}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list parts: %w", err)
+		}
+
+		for _, part := range response.Parts {
+			parts = append(parts, model.UploadedPart{
+				PartNumber:   part.PartNumber,
+				ETag:         part.Etag,
+				Size:         part.Size,
+				LastModified: time.Unix(part.PutTime, 0).UTC(),
+			})
+		}
+
+		// marker 为 0 表示列举结束
+		if response.PartNumberMarker == 0 {
+			break
+		}
+		marker = response.PartNumberMarker
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].PartNumber < parts[j].PartNumber
+	})
+	return parts, nil
+}
+
+// CompleteMultipartUpload 使用已上传的分片合成文件，并返回文件信息
+func (q *QiniuCommoner) CompleteMultipartUpload(objectName, uploadID, contentType string, parts []model.UploadedPart) (*model.UploadResponse, error) {
+	storageAPI, upToken, err := q.newStorageAPI()
+	if err != nil {
+		return nil, err
+	}
+
+	completedParts := make(resumable_upload_v2_complete_multipart_upload.Parts, 0, len(parts))
+	for _, part := range parts {
+		completedParts = append(completedParts, resumable_upload_v2_complete_multipart_upload.PartInfo{
+			PartNumber: part.PartNumber,
+			Etag:       part.ETag,
+		})
+	}
+
+	_, err = storageAPI.ResumableUploadV2CompleteMultipartUpload(context.Background(), &apis.ResumableUploadV2CompleteMultipartUploadRequest{
+		BucketName: q.bucketName,
+		ObjectName: &objectName,
+		UploadId:   uploadID,
+		UpToken:    upToken,
+		Parts:      completedParts,
+		FileName:   path.Base(objectName),
+		MimeType:   contentType,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete multipart upload: %w", qiniuError(err))
+	}
+
+	return q.uploadedFileInfo(objectName)
+}
+
+// AbortMultipartUpload 终止分片上传任务，已上传的分片会被七牛云清理
+func (q *QiniuCommoner) AbortMultipartUpload(objectName, uploadID string) error {
+	storageAPI, upToken, err := q.newStorageAPI()
+	if err != nil {
+		return err
+	}
+
+	_, err = storageAPI.ResumableUploadV2AbortMultipartUpload(context.Background(), &apis.ResumableUploadV2AbortMultipartUploadRequest{
+		BucketName: q.bucketName,
+		ObjectName: &objectName,
+		UploadId:   uploadID,
+		UpToken:    upToken,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	return nil
+}
+
+// nopCloser 为分片数据补充 Close 方法，分片数据的生命周期由调用方管理
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
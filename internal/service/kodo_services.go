@@ -3,156 +3,358 @@ package service
 import (
 	"context"
 	"dooqiniu/internal/model"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/qiniu/go-sdk/v7/auth"
 	"github.com/qiniu/go-sdk/v7/storage"
+	"github.com/qiniu/go-sdk/v7/storagev2/apis"
 	"github.com/qiniu/go-sdk/v7/storagev2/credentials"
 	"github.com/qiniu/go-sdk/v7/storagev2/http_client"
+	"github.com/qiniu/go-sdk/v7/storagev2/region"
 	"github.com/qiniu/go-sdk/v7/storagev2/uploader"
 )
 
+var _ Storage = (*QiniuCommoner)(nil)
+
 type QiniuCommoner struct {
 	accessKey  string
 	secretKey  string
 	bucketName string
 	endpoint   string
+	// region 区域 ID，为空或无法识别时由 SDK 按存储空间自动查询
+	region string
+
+	// 创建时初始化，客户端按账号和存储空间缓存后在请求之间复用
+	mac           *auth.Credentials
+	bucketManager *storage.BucketManager
+	uploadManager *uploader.UploadManager
+	storageAPI    *apis.Storage
 }
 
-func NewQiniuClient() *QiniuCommoner {
-	return &QiniuCommoner{
-		accessKey:  os.Getenv("QINIU_ACCESSKEY"),
-		secretKey:  os.Getenv("QINIU_SECRETKEY"),
-		bucketName: os.Getenv("QINIU_BUCKET"),
-		endpoint:   os.Getenv("QINIU_ENDPOINT"),
+// NewQiniuAccountClient 使用指定的账号访问 profile 指定的存储空间
+func NewQiniuAccountClient(accessKey, secretKey string, profile model.BucketProfile) *QiniuCommoner {
+	q := &QiniuCommoner{
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+		bucketName: profile.Bucket,
+		endpoint:   profile.Endpoint,
+		region:     profile.Region,
 	}
+	q.mac = auth.New(accessKey, secretKey)
+	q.bucketManager = storage.NewBucketManager(q.mac, q.storageConfig())
+	q.uploadManager = uploader.NewUploadManager(&uploader.UploadManagerOptions{Options: *q.httpOptions()})
+	q.storageAPI = apis.NewStorage(q.httpOptions())
+	return q
 }
 
-// 上传将文件上传到七牛云
-func (q *QiniuCommoner) Upload(filePath, objectName string) (*model.UploadResponse, error) {
-	mac := credentials.NewCredentials(q.accessKey, q.secretKey)
-
-	// 创建具有凭证的上传管理器
-	options := uploader.UploadManagerOptions{
-		Options: http_client.Options{
-			Credentials: mac,
-		},
+// storageConfig 返回 BucketManager 使用的配置，指定了区域时不再查询存储空间所在的区域
+func (q *QiniuCommoner) storageConfig() *storage.Config {
+	if q.region != "" {
+		if r, ok := storage.GetRegionByID(storage.RegionID(q.region)); ok {
+			return &storage.Config{Region: &r}
+		}
 	}
-	uploadManager := uploader.NewUploadManager(&options)
+	return &storage.Config{}
+}
 
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+// httpOptions 返回 storagev2 接口使用的客户端选项
+func (q *QiniuCommoner) httpOptions() *http_client.Options {
+	options := &http_client.Options{
+		Credentials: credentials.NewCredentials(q.accessKey, q.secretKey),
 	}
-	defer file.Close()
-
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("file does not exist: %s", filePath)
+	if q.region != "" {
+		if _, ok := storage.GetRegionByID(storage.RegionID(q.region)); ok {
+			options.Regions = region.GetRegionByID(q.region, true)
+		}
 	}
+	return options
+}
 
+// Upload 将数据流上传到七牛云，数据不会整体缓存在内存中
+func (q *QiniuCommoner) Upload(file io.Reader, objectName, contentType string, metaData map[string]string) (*model.UploadResponse, error) {
 	// 使用目标路径设置对象选项
 	objectOptions := &uploader.ObjectOptions{
-		BucketName: q.bucketName,
-		ObjectName: &objectName,
-		FileName:   filepath.Base(filePath),
+		BucketName:  q.bucketName,
+		ObjectName:  &objectName,
+		FileName:    path.Base(objectName),
+		ContentType: contentType,
+		// 上传管理器会为键名补上 x-qn-meta- 前缀
+		Metadata: metaData,
 	}
 
-	// 执行上传
-	err = uploadManager.UploadFile(context.Background(), filePath, objectOptions, nil)
+	// 执行上传，超过分片阈值时上传管理器会按分片流式读取
+	err := q.uploadManager.UploadReader(context.Background(), file, objectOptions, nil)
 	if err != nil {
-		return nil, fmt.Errorf("upload failed: %w", err)
+		return nil, fmt.Errorf("upload failed: %w", qiniuError(err))
 	}
 
-	// 上传后，使用 ListFiles 获取文件信息
-	files, _, err := q.ListFiles(objectName, "", 1)
-	if err != nil || len(files) == 0 {
-		return nil, fmt.Errorf("failed to retrieve file info: %v", err)
+	return q.uploadedFileInfo(objectName)
+}
+
+// uploadedFileInfo 上传完成后，使用 Stat 获取文件信息
+func (q *QiniuCommoner) uploadedFileInfo(objectName string) (*model.UploadResponse, error) {
+	fileInfo, err := q.Stat(objectName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve file info: %w", err)
 	}
 
 	// 提取所需信息
-	fileInfo := files[0]
 	uploadResponse := &model.UploadResponse{
-		ContentLength: fileInfo.Fsize,
-		ETag:          fileInfo.Hash,
-		LastModified:  time.Unix(fileInfo.PutTime/1e7, 0).UTC(), // Convert to time
+		ContentLength: fileInfo.ContentLength,
+		ETag:          fileInfo.ETag,
+		LastModified:  fileInfo.LastModified,
 	}
 
 	return uploadResponse, nil
 }
 
-// GeneratePublicURL 生成公开访问的下载链接
-func (q *QiniuCommoner) GeneratePublicURL(objectName string) string {
-	return storage.MakePublicURL(q.endpoint, objectName)
+// Stat 获取七牛云中单个文件的信息
+func (q *QiniuCommoner) Stat(objectName string) (*model.FileInfo, error) {
+	info, err := q.bucketManager.Stat(q.bucketName, objectName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", qiniuError(err))
+	}
+
+	return &model.FileInfo{
+		Key:           objectName,
+		ContentLength: info.Fsize,
+		ETag:          info.Hash,
+		MimeType:      info.MimeType,
+		LastModified:  putTimeToTime(info.PutTime),
+		StorageType:   info.Type,
+		EndUser:       info.EndUser,
+		Status:        info.Status,
+		RestoreStatus: info.RestoreStatus,
+		MetaData:      trimMetaPrefix(info.MetaData),
+		Lifecycle:     objectLifecycle(info),
+	}, nil
+}
+
+// 七牛云自定义元数据键名的前缀
+const metaPrefix = "x-qn-meta-"
+
+// trimMetaPrefix 去掉自定义元数据键名中的 x-qn-meta- 前缀，
+// 七牛云无法删除元数据，值为空的键视为已删除
+func trimMetaPrefix(metaData map[string]string) map[string]string {
+	trimmed := make(map[string]string, len(metaData))
+	for key, value := range metaData {
+		if value != "" {
+			trimmed[strings.TrimPrefix(strings.ToLower(key), metaPrefix)] = value
+		}
+	}
+	if len(trimmed) == 0 {
+		return nil
+	}
+	return trimmed
+}
+
+// ChangeMeta 修改七牛云中文件的 MIME 类型和自定义元数据，mimeType 为空时保持不变。
+// replace 为 false 时只修改 metaData 中的键，值为空字符串表示删除；
+// replace 为 true 时原有元数据中不在 metaData 里的键都会被删除
+func (q *QiniuCommoner) ChangeMeta(objectName, mimeType string, metaData map[string]string, replace bool) error {
+	changes := make(map[string]string, len(metaData))
+	for key, value := range metaData {
+		changes[metaPrefix+key] = value
+	}
+	if replace {
+		info, err := q.Stat(objectName)
+		if err != nil {
+			return fmt.Errorf("failed to change metadata: %w", err)
+		}
+		// 七牛云不支持删除元数据，将需要删除的键置为空值
+		for key := range info.MetaData {
+			if _, ok := metaData[key]; !ok {
+				changes[metaPrefix+key] = ""
+			}
+		}
+	}
+	if mimeType == "" && len(changes) == 0 {
+		return nil
+	}
+
+	err := q.bucketManager.ChangeMimeAndMeta(q.bucketName, objectName, mimeType, changes)
+	if err != nil {
+		return fmt.Errorf("failed to change metadata: %w", qiniuError(err))
+	}
+	return nil
+}
+
+// GeneratePublicURL 生成公开访问的下载链接，对象名按路径转义，数据处理指令和 attname 作为查询字符串
+func (q *QiniuCommoner) GeneratePublicURL(objectName string, opts *model.URLOptions) string {
+	return makeObjectURL(q.endpoint, objectName, urlQuery(opts))
 }
 
 // GeneratePrivateURL 生成私有访问的下载链接
-func (q *QiniuCommoner) GeneratePrivateURL(objectName string, expiryTime int64) string {
-	mac := auth.New(q.accessKey, q.secretKey)
-	return storage.MakePrivateURL(mac, q.endpoint, objectName, expiryTime)
+// 数据处理指令和 attname 需要参与签名，与对象名分开传入，对象名按路径转义
+func (q *QiniuCommoner) GeneratePrivateURL(objectName string, expiryTime int64, opts *model.URLOptions) string {
+	return makePrivateObjectURL(q.mac, q.endpoint, objectName, urlQuery(opts), expiryTime)
 }
 
-// Delete 从七牛云中删除文件
-func (q *QiniuCommoner) Delete(objectName string) error {
-	mac := auth.New(q.accessKey, q.secretKey)
+// makeObjectURL 生成对象的下载链接，对象名的每一段按路径转义，% ? # 和空格等字符不会被当作链接的一部分解析，
+// query 为已编码的查询字符串，直接拼接在 ? 之后
+func makeObjectURL(domain, objectName, query string) string {
+	segments := strings.Split(objectName, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	objectURL := strings.TrimRight(domain, "/") + "/" + strings.Join(segments, "/")
+	if query != "" {
+		objectURL += "?" + query
+	}
+	return objectURL
+}
 
-	bucketManager := storage.NewBucketManager(mac, &storage.Config{})
+// makePrivateObjectURL 与 storage.MakePrivateURL 的签名方式相同：在链接后追加过期时间 e，对整个链接签名后追加 token
+func makePrivateObjectURL(mac *auth.Credentials, domain, objectName, query string, deadline int64) string {
+	urlToSign := makeObjectURL(domain, objectName, query)
+	if query != "" {
+		urlToSign += fmt.Sprintf("&e=%d", deadline)
+	} else {
+		urlToSign += fmt.Sprintf("?e=%d", deadline)
+	}
+	return urlToSign + "&token=" + mac.Sign([]byte(urlToSign))
+}
 
+// Open 通过私有下载链接读取七牛云中文件的内容，length 小于 0 表示读取到文件末尾
+func (q *QiniuCommoner) Open(objectName string, offset, length int64) (io.ReadCloser, error) {
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+
+	downloadURL := q.GeneratePrivateURL(objectName, time.Now().Add(time.Hour).Unix(), nil)
+	req, err := http.NewRequest(http.MethodGet, downloadURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	if offset > 0 || length > 0 {
+		req.Header.Set("Range", byteRange(offset, length))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, nil
+	case http.StatusOK:
+		// 源站忽略了 Range 请求时自行截取所需的数据
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to open file: %w", err)
+		}
+		return limitReadCloser(resp.Body, length), nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to open file: %w", ErrObjectNotFound)
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to open file: unexpected status %s", resp.Status)
+	}
+}
+
+// Delete 从七牛云中删除文件
+func (q *QiniuCommoner) Delete(objectName string) error {
 	// 执行删除操作
-	err := bucketManager.Delete(q.bucketName, objectName)
+	err := q.bucketManager.Delete(q.bucketName, objectName)
 	if err != nil {
-		return fmt.Errorf("failed to delete file: %v", err)
+		return fmt.Errorf("failed to delete file: %w", qiniuError(err))
 	}
 	return nil
 }
 
-// ListFiles 列出七牛云桶中的文件
-func (q *QiniuCommoner) ListFiles(prefix, marker string, limit int) ([]storage.ListItem, string, error) {
-	mac := auth.New(q.accessKey, q.secretKey)
-
-	bucketManager := storage.NewBucketManager(mac, &storage.Config{})
-
-	// 获取文件列表
-	entries, _, nextMarker, hasNext, err := bucketManager.ListFiles(q.bucketName, prefix, "", marker, limit)
+// ListFiles 列出七牛云桶中的文件，delimiter 不为空时同时返回公共前缀。
+// 使用 storagev2 的列举接口，结果中包含自定义元数据
+func (q *QiniuCommoner) ListFiles(prefix, delimiter, marker string, limit int) ([]model.FileInfo, []string, string, error) {
+	// 获取文件列表，没有更多数据时返回的 marker 为空
+	response, err := q.storageAPI.GetObjects(context.Background(), &apis.GetObjectsRequest{
+		Bucket:    q.bucketName,
+		Prefix:    prefix,
+		Delimiter: delimiter,
+		Marker:    marker,
+		Limit:     int64(limit),
+	}, nil)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to list files: %v", err)
+		return nil, nil, "", fmt.Errorf("failed to list files: %v", err)
 	}
 
-	// 只返回文件项列表和下一页的 marker
-	if !hasNext {
-		nextMarker = ""
+	// Map original file list to a limited field response
+	files := make([]model.FileInfo, 0, len(response.Items))
+	for _, entry := range response.Items {
+		files = append(files, model.FileInfo{
+			Key:           entry.Key,
+			ContentLength: entry.Size,
+			ETag:          entry.Hash,
+			MimeType:      entry.MimeType,
+			LastModified:  putTimeToTime(entry.PutTime),
+			StorageType:   int(entry.Type),
+			EndUser:       entry.EndUser,
+			Status:        int(entry.Status),
+			RestoreStatus: int(entry.RestoringStatus),
+			MetaData:      trimMetaPrefix(entry.Metadata),
+		})
 	}
 
-	// 返回文件列表和下一页的游标
-	return entries, nextMarker, nil
+	// 返回文件列表、公共前缀和下一页的游标
+	return files, response.CommonPrefixes, response.Marker, nil
 }
 
 // Copy 从七牛云中复制文件到新位置
 func (q *QiniuCommoner) Copy(srcKey, destKey string, force bool) error {
-	mac := auth.New(q.accessKey, q.secretKey)
-
-	bucketManager := storage.NewBucketManager(mac, &storage.Config{})
+	return q.CopyToBucket(srcKey, q.bucketName, destKey, force)
+}
 
+// CopyToBucket 将文件复制到同一账号下的另一个存储空间，两个存储空间需要位于同一区域
+func (q *QiniuCommoner) CopyToBucket(srcKey, destBucket, destKey string, force bool) error {
 	// 执行复制操作
-	err := bucketManager.Copy(q.bucketName, srcKey, q.bucketName, destKey, force)
+	err := q.bucketManager.Copy(q.bucketName, srcKey, destBucket, destKey, force)
 	if err != nil {
-		return fmt.Errorf("failed to copy file: %v", err)
+		return fmt.Errorf("failed to copy file: %w", qiniuError(err))
 	}
 	return nil
 }
 
 // Move 移动文件到七牛云存储中的新位置
 func (q *QiniuCommoner) Move(srcObject, destObject string, force bool) error {
-	mac := auth.New(q.accessKey, q.secretKey)
-
-	bucketManager := storage.NewBucketManager(mac, &storage.Config{})
+	return q.MoveToBucket(srcObject, q.bucketName, destObject, force)
+}
 
+// MoveToBucket 将文件移动到同一账号下的另一个存储空间，两个存储空间需要位于同一区域
+func (q *QiniuCommoner) MoveToBucket(srcObject, destBucket, destObject string, force bool) error {
 	// 执行移动操作
-	err := bucketManager.Move(q.bucketName, srcObject, q.bucketName, destObject, force)
+	err := q.bucketManager.Move(q.bucketName, srcObject, destBucket, destObject, force)
 	if err != nil {
-		return fmt.Errorf("failed to move file: %v", err)
+		return fmt.Errorf("failed to move file: %w", qiniuError(err))
 	}
 	return nil
 }
+
+// putTimeToTime 七牛云上传时间单位为 100 纳秒
+func putTimeToTime(putTime int64) time.Time {
+	return time.Unix(putTime/1e7, 0).UTC()
+}
+
+// qiniuError 将七牛云的错误码转换为存储后端通用的错误
+func qiniuError(err error) error {
+	var errorInfo *storage.ErrorInfo
+	if !errors.As(err, &errorInfo) {
+		return err
+	}
+
+	switch errorInfo.Code {
+	case 612:
+		return fmt.Errorf("%w: %v", ErrObjectNotFound, err)
+	case 614:
+		return fmt.Errorf("%w: %v", ErrObjectExists, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"dooqiniu/internal/model"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/qiniu/go-sdk/v7/auth"
+)
+
+// specialKeys 包含需要在链接中转义的字符的对象名
+var specialKeys = []string{
+	"plain.txt",
+	"100%.txt",
+	"a?b=c.txt",
+	"notes#1.txt",
+	"dir with space/file name.txt",
+	"%2F/encoded.txt",
+}
+
+func TestGeneratePrivateURLEscapesKey(t *testing.T) {
+	q := NewQiniuAccountClient("ak", "sk", model.BucketProfile{Bucket: "b", Endpoint: "https://cdn.example.com/"})
+	mac := auth.New("ak", "sk")
+
+	tests := []struct {
+		name  string
+		opts  *model.URLOptions
+		query map[string]string
+	}{
+		{name: "no options"},
+		{name: "attname", opts: &model.URLOptions{AttName: "report 1.pdf"}, query: map[string]string{"attname": "report 1.pdf"}},
+		{name: "fop", opts: &model.URLOptions{Fop: "imageView2/1/w/200"}, query: map[string]string{"imageView2/1/w/200": ""}},
+	}
+	for _, tt := range tests {
+		for _, key := range specialKeys {
+			t.Run(tt.name+"/"+key, func(t *testing.T) {
+				raw := q.GeneratePrivateURL(key, 1700000000, tt.opts)
+
+				u, err := url.Parse(raw)
+				if err != nil {
+					t.Fatalf("invalid url %q: %v", raw, err)
+				}
+				if u.Path != "/"+key {
+					t.Errorf("path = %q, want %q", u.Path, "/"+key)
+				}
+				if u.Fragment != "" {
+					t.Errorf("unexpected fragment %q in %q", u.Fragment, raw)
+				}
+
+				values := u.Query()
+				if values.Get("e") != "1700000000" {
+					t.Errorf("e = %q, want 1700000000", values.Get("e"))
+				}
+				for k, v := range tt.query {
+					if got, ok := values[k]; !ok || got[0] != v {
+						t.Errorf("query %s = %v, want %q", k, got, v)
+					}
+				}
+
+				// token 是对去掉 token 参数的完整链接的签名
+				i := strings.LastIndex(raw, "&token=")
+				if i < 0 {
+					t.Fatalf("missing token in %q", raw)
+				}
+				if want := mac.Sign([]byte(raw[:i])); raw[i+len("&token="):] != want {
+					t.Errorf("token = %q, want %q", raw[i+len("&token="):], want)
+				}
+			})
+		}
+	}
+}
+
+func TestOpenRequestsEscapedKey(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		if r.URL.Query().Get("token") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, "content of "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	q := NewQiniuAccountClient("ak", "sk", model.BucketProfile{Bucket: "b", Endpoint: server.URL})
+	for _, key := range specialKeys {
+		t.Run(key, func(t *testing.T) {
+			reader, err := q.Open(key, 0, -1)
+			if err != nil {
+				t.Fatalf("Open(%q): %v", key, err)
+			}
+			defer reader.Close()
+
+			data, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotPath != "/"+key {
+				t.Errorf("requested path %q, want %q", gotPath, "/"+key)
+			}
+			if string(data) != "content of /"+key {
+				t.Errorf("body = %q", data)
+			}
+		})
+	}
+}
+
+func TestGeneratePublicURLSeparatesKeyAndQuery(t *testing.T) {
+	q := NewQiniuAccountClient("ak", "sk", model.BucketProfile{Bucket: "b", Endpoint: "https://cdn.example.com"})
+	opts := &model.URLOptions{Fop: "imageView2/1/w/200", AttName: "a.png"}
+
+	for _, key := range specialKeys {
+		t.Run(key, func(t *testing.T) {
+			raw := q.GeneratePublicURL(key, opts)
+			u, err := url.Parse(raw)
+			if err != nil {
+				t.Fatalf("invalid url %q: %v", raw, err)
+			}
+			if u.Path != "/"+key {
+				t.Errorf("path = %q, want %q", u.Path, "/"+key)
+			}
+			if u.RawQuery != "imageView2/1/w/200&attname=a.png" {
+				t.Errorf("query = %q", u.RawQuery)
+			}
+		})
+	}
+}
+
+func TestQiniuProfileClientCache(t *testing.T) {
+	t.Cleanup(func() { qiniuClients.Clear() })
+	cfg := &model.Config{
+		QiniuAccessKey: "ak",
+		QiniuSecretKey: "sk",
+		QiniuBucket:    "default",
+		Buckets:        map[string]model.BucketProfile{"logs": {Bucket: "logs-bucket"}},
+	}
+
+	first, err := qiniuProfileClient(cfg, storageKey{bucket: "logs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := qiniuProfileClient(cfg, storageKey{bucket: "logs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Error("expected cached client to be reused")
+	}
+	if other, _ := qiniuProfileClient(cfg, storageKey{}); other == first || other.bucketName != "default" {
+		t.Errorf("default bucket got client for %q", other.bucketName)
+	}
+
+	// 存储空间配置变化后重新创建客户端
+	cfg.Buckets = map[string]model.BucketProfile{"logs": {Bucket: "logs-bucket", Region: "z1"}}
+	third, err := qiniuProfileClient(cfg, storageKey{bucket: "logs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third == first || third.region != "z1" {
+		t.Error("expected a new client after the bucket config changed")
+	}
+
+	if _, err := qiniuProfileClient(cfg, storageKey{bucket: "missing"}); !errors.Is(err, ErrUnknownBucket) {
+		t.Errorf("err = %v, want ErrUnknownBucket", err)
+	}
+}
+
+func TestPruneClientCache(t *testing.T) {
+	t.Cleanup(func() { qiniuClients.Clear() })
+	old := &model.Config{
+		QiniuAccessKey: "ak",
+		QiniuSecretKey: "sk",
+		QiniuBucket:    "default",
+		Buckets: map[string]model.BucketProfile{
+			"logs":    {Bucket: "logs-bucket"},
+			"backup":  {Bucket: "backup-bucket"},
+			"archive": {Bucket: "archive-bucket"},
+		},
+	}
+	clients := make(map[storageKey]*QiniuCommoner)
+	for _, key := range []storageKey{{}, {bucket: "logs"}, {bucket: "backup"}, {bucket: "archive"}} {
+		client, err := qiniuProfileClient(old, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clients[key] = client
+	}
+
+	// backup 的区域变化，archive 被删除，默认存储空间和 logs 不变
+	cfg := &model.Config{
+		QiniuAccessKey: "ak",
+		QiniuSecretKey: "sk",
+		QiniuBucket:    "default",
+		Buckets: map[string]model.BucketProfile{
+			"logs":   {Bucket: "logs-bucket"},
+			"backup": {Bucket: "backup-bucket", Region: "z1"},
+		},
+	}
+	PruneClientCache(old, cfg)
+
+	for key, want := range map[storageKey]bool{{}: true, {bucket: "logs"}: true, {bucket: "backup"}: false, {bucket: "archive"}: false} {
+		cached, ok := qiniuClients.Load(key)
+		if ok != want {
+			t.Errorf("%+v cached = %v, want %v", key, ok, want)
+			continue
+		}
+		if ok && cached.(*qiniuClientEntry).client != clients[key] {
+			t.Errorf("%+v client replaced although its config did not change", key)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"dooqiniu/internal/model"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrScanBudgetExceeded 表示排序需要扫描的对象数量超过了扫描预算
+var ErrScanBudgetExceeded = errors.New("scan budget exceeded")
+
+// 列举结果支持的排序字段
+const (
+	SortByKey          = "key"
+	SortBySize         = "size"
+	SortByLastModified = "last_modified"
+)
+
+// ListFilter 列举时在服务端执行的筛选和排序条件，零值表示不限制
+type ListFilter struct {
+	Prefix         string    `json:"prefix,omitempty"`
+	MinSize        *int64    `json:"minSize,omitempty"`
+	MaxSize        *int64    `json:"maxSize,omitempty"`
+	ModifiedAfter  time.Time `json:"modifiedAfter"`
+	ModifiedBefore time.Time `json:"modifiedBefore"`
+	// MimeType 精确匹配，以 /* 结尾时按主类型匹配，例如 image/*
+	MimeType string `json:"mimeType,omitempty"`
+	// Glob 不包含 / 时匹配文件名，否则匹配完整的对象名
+	Glob   string `json:"glob,omitempty"`
+	Regex  string `json:"regex,omitempty"`
+	Suffix string `json:"suffix,omitempty"`
+	Sort   string `json:"sort,omitempty"`
+	Desc   bool   `json:"desc,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Active 是否设置了除前缀之外的筛选或排序条件
+func (f *ListFilter) Active() bool {
+	return f.MinSize != nil || f.MaxSize != nil || !f.ModifiedAfter.IsZero() || !f.ModifiedBefore.IsZero() ||
+		f.MimeType != "" || f.Glob != "" || f.Regex != "" || f.Suffix != "" || f.Sort != ""
+}
+
+// Compile 校验筛选条件并编译正则表达式，使用 Match 之前必须调用
+func (f *ListFilter) Compile() error {
+	switch f.Sort {
+	case "", SortByKey, SortBySize, SortByLastModified:
+	default:
+		return fmt.Errorf("invalid sort field: %s", f.Sort)
+	}
+	if f.Glob != "" {
+		if _, err := path.Match(f.Glob, ""); err != nil {
+			return fmt.Errorf("invalid glob pattern: %s", f.Glob)
+		}
+	}
+	if f.Regex != "" {
+		re, err := regexp.Compile(f.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid regex: %v", err)
+		}
+		f.re = re
+	}
+	return nil
+}
+
+// Match 判断对象是否满足所有筛选条件
+func (f *ListFilter) Match(file model.FileInfo) bool {
+	if f.MinSize != nil && file.ContentLength < *f.MinSize {
+		return false
+	}
+	if f.MaxSize != nil && file.ContentLength > *f.MaxSize {
+		return false
+	}
+	if !f.ModifiedAfter.IsZero() && file.LastModified.Before(f.ModifiedAfter) {
+		return false
+	}
+	if !f.ModifiedBefore.IsZero() && !file.LastModified.Before(f.ModifiedBefore) {
+		return false
+	}
+	if f.MimeType != "" {
+		if major, ok := strings.CutSuffix(f.MimeType, "/*"); ok {
+			if !strings.HasPrefix(strings.ToLower(file.MimeType), strings.ToLower(major)+"/") {
+				return false
+			}
+		} else if !strings.EqualFold(file.MimeType, f.MimeType) {
+			return false
+		}
+	}
+	if f.Suffix != "" && !strings.HasSuffix(strings.ToLower(file.Key), strings.ToLower(f.Suffix)) {
+		return false
+	}
+	if f.Glob != "" {
+		name := file.Key
+		if !strings.Contains(f.Glob, "/") {
+			name = path.Base(file.Key)
+		}
+		if ok, _ := path.Match(f.Glob, name); !ok {
+			return false
+		}
+	}
+	if f.re != nil && !f.re.MatchString(file.Key) {
+		return false
+	}
+	return true
+}
+
+// less 按排序字段比较两个对象，字段相同时按对象名排序保证顺序稳定
+func (f *ListFilter) less(a, b model.FileInfo) bool {
+	var cmp int
+	switch f.Sort {
+	case SortBySize:
+		cmp = compareInt64(a.ContentLength, b.ContentLength)
+	case SortByLastModified:
+		cmp = a.LastModified.Compare(b.LastModified)
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(a.Key, b.Key)
+	}
+	if f.Desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// ListCursor 筛选列举的续传状态，同时保存七牛云的游标和筛选条件
+type ListCursor struct {
+	Filter ListFilter `json:"f"`
+	// Marker 当前页在存储后端的游标，After 为已扫描到的最后一个对象名
+	Marker string `json:"m,omitempty"`
+	After  string `json:"a,omitempty"`
+	// Last 排序模式下上一页返回的最后一个对象
+	Last *model.FileInfo `json:"l,omitempty"`
+}
+
+// EncodeListCursor 将续传状态编码为不透明的令牌
+func EncodeListCursor(cursor *ListCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeListCursor 解析续传令牌并重新编译其中的筛选条件
+func DecodeListCursor(token string) (*ListCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errors.New("invalid continuation token")
+	}
+	cursor := &ListCursor{}
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, errors.New("invalid continuation token")
+	}
+	if err := cursor.Filter.Compile(); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+// FilteredList 筛选列举的结果
+type FilteredList struct {
+	Files []model.FileInfo
+	// Scanned 本次请求扫描的对象数量
+	Scanned int
+	// Next 还有更多结果时的续传状态
+	Next *ListCursor
+}
+
+// FilterFiles 按筛选条件跨页扫描对象，最多返回 limit 个，最多扫描 budget 个。
+// 不排序时按对象名顺序返回，预算耗尽时返回已找到的结果和续传状态；
+// 排序需要扫描前缀下的全部对象，超过预算时返回 ErrScanBudgetExceeded
+func FilterFiles(s FileLister, cursor *ListCursor, limit, budget int) (*FilteredList, error) {
+	if cursor.Filter.Sort != "" {
+		return filterSorted(s, cursor, limit, budget)
+	}
+
+	f := &cursor.Filter
+	result := &FilteredList{Files: []model.FileInfo{}}
+	marker := cursor.Marker
+	after := cursor.After
+	for {
+		files, _, nextMarker, err := s.ListFiles(f.Prefix, "", marker, listingPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			if file.Key <= after {
+				continue
+			}
+			if len(result.Files) == limit || result.Scanned == budget {
+				result.Next = &ListCursor{Filter: *f, Marker: marker, After: after}
+				return result, nil
+			}
+
+			result.Scanned++
+			after = file.Key
+			if f.Match(file) {
+				result.Files = append(result.Files, file)
+			}
+		}
+
+		if nextMarker == "" {
+			return result, nil
+		}
+		marker = nextMarker
+	}
+}
+
+// filterSorted 扫描前缀下的全部对象，排序后返回上一页之后的 limit 个结果
+func filterSorted(s FileLister, cursor *ListCursor, limit, budget int) (*FilteredList, error) {
+	f := &cursor.Filter
+	result := &FilteredList{}
+
+	matched := []model.FileInfo{}
+	it := NewListingIterator(s, f.Prefix)
+	for {
+		files, err := it.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result.Scanned += len(files)
+		if result.Scanned > budget {
+			return nil, fmt.Errorf("sorting requires scanning more than %d objects, narrow the prefix: %w", budget, ErrScanBudgetExceeded)
+		}
+		for _, file := range files {
+			if f.Match(file) && (cursor.Last == nil || f.less(*cursor.Last, file)) {
+				matched = append(matched, file)
+			}
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return f.less(matched[i], matched[j])
+	})
+	if len(matched) > limit {
+		matched = matched[:limit]
+		result.Next = &ListCursor{Filter: *f, Last: &matched[limit-1]}
+	}
+	result.Files = matched
+	return result, nil
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"dooqiniu/internal/model"
+	"io"
+)
+
+// 遍历整个前缀时每页列举的对象数量，七牛云单次最多返回 1000 个
+const listingPageSize = 1000
+
+// ListingIterator 逐页遍历前缀下的所有对象，每次只在内存中保留一页
+type ListingIterator struct {
+	lister FileLister
+	prefix string
+	marker string
+	done   bool
+}
+
+// NewListingIterator 创建从头开始遍历前缀的迭代器
+func NewListingIterator(l FileLister, prefix string) *ListingIterator {
+	return &ListingIterator{lister: l, prefix: prefix}
+}
+
+// Next 返回下一页对象，遍历结束后返回 io.EOF
+func (it *ListingIterator) Next() ([]model.FileInfo, error) {
+	for !it.done {
+		files, _, nextMarker, err := it.lister.ListFiles(it.prefix, "", it.marker, listingPageSize)
+		if err != nil {
+			return nil, err
+		}
+		it.marker = nextMarker
+		it.done = nextMarker == ""
+		// 七牛云可能返回带游标的空页，跳过继续列举
+		if len(files) > 0 {
+			return files, nil
+		}
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,588 @@
+package service
+
+import (
+	"crypto/md5"
+	"dooqiniu/internal/model"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ Storage = (*LocalStorage)(nil)
+
+// 本地后端的写操作在进程内串行执行，避免复制、移动时的检查与写入之间被其他请求修改
+var localStorageMu sync.Mutex
+
+// localObjectMeta 本地对象的元数据，与对象文件分开保存
+type localObjectMeta struct {
+	ContentType string            `json:"content_type,omitempty"`
+	Hash        string            `json:"hash"`
+	PutTime     time.Time         `json:"put_time"`
+	MetaData    map[string]string `json:"metadata,omitempty"`
+}
+
+// localUpload 本地分片上传任务
+type localUpload struct {
+	ObjectName string    `json:"object_name"`
+	ExpireAt   time.Time `json:"expire_at"`
+}
+
+// LocalStorage 基于本地磁盘的存储后端，目录结构为：
+// objects/ 对象数据，meta/ 对象元数据，uploads/ 分片上传任务，tmp/ 写入中的临时文件
+type LocalStorage struct {
+	dir string
+}
+
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{dir: dir}
+}
+
+// Upload 将数据流写入本地磁盘，同时计算七牛云格式的 etag
+func (l *LocalStorage) Upload(file io.Reader, objectName, contentType string, metaData map[string]string) (*model.UploadResponse, error) {
+	objectPath, err := l.objectPath(objectName)
+	if err != nil {
+		return nil, fmt.Errorf("upload failed: %w", err)
+	}
+
+	tmpName, hash, err := l.writeTemp(file)
+	if err != nil {
+		return nil, fmt.Errorf("upload failed: %w", err)
+	}
+	defer os.Remove(tmpName)
+
+	localStorageMu.Lock()
+	defer localStorageMu.Unlock()
+
+	// 与七牛云相同，上传不能覆盖已存在的对象
+	if _, err := l.stat(objectName); err == nil {
+		return nil, fmt.Errorf("upload failed: %w", ErrObjectExists)
+	}
+	meta := &localObjectMeta{
+		ContentType: detectContentType(objectName, contentType),
+		Hash:        hash,
+		PutTime:     time.Now().UTC(),
+		MetaData:    mergeMetaData(nil, metaData, true),
+	}
+	if err := l.commit(tmpName, objectPath, objectName, meta); err != nil {
+		return nil, fmt.Errorf("upload failed: %w", err)
+	}
+
+	info, err := l.stat(objectName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve file info: %w", err)
+	}
+	return &model.UploadResponse{
+		ContentLength: info.ContentLength,
+		ETag:          info.ETag,
+		LastModified:  info.LastModified,
+	}, nil
+}
+
+// Stat 获取单个对象的信息
+func (l *LocalStorage) Stat(objectName string) (*model.FileInfo, error) {
+	info, err := l.stat(objectName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return info, nil
+}
+
+// ListFiles 按 key 的字典序列举对象，marker 为上一页最后一个 key 或公共前缀
+func (l *LocalStorage) ListFiles(prefix, delimiter, marker string, limit int) ([]model.FileInfo, []string, string, error) {
+	root := filepath.Join(l.dir, "objects")
+
+	var keys []string
+	err := filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) && key > marker {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to list files: %v", err)
+	}
+	sort.Strings(keys)
+
+	keys, commonPrefixes, nextMarker := pageKeys(keys, prefix, delimiter, marker, limit)
+
+	files := make([]model.FileInfo, 0, len(keys))
+	for _, key := range keys {
+		info, err := l.stat(key)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("failed to list files: %v", err)
+		}
+		files = append(files, *info)
+	}
+	return files, commonPrefixes, nextMarker, nil
+}
+
+// Open 读取对象内容
+func (l *LocalStorage) Open(objectName string, offset, length int64) (io.ReadCloser, error) {
+	objectPath, err := l.objectPath(objectName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	file, err := os.Open(objectPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to open file: %w", ErrObjectNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return limitReadCloser(file, length), nil
+}
+
+// Delete 删除对象及其元数据
+func (l *LocalStorage) Delete(objectName string) error {
+	objectPath, err := l.objectPath(objectName)
+	if err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	localStorageMu.Lock()
+	defer localStorageMu.Unlock()
+
+	if err := os.Remove(objectPath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to delete file: %w", ErrObjectNotFound)
+		}
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	os.Remove(l.metaPath(objectName))
+	l.pruneDirs(objectName)
+	return nil
+}
+
+// Copy 复制对象，force 为 false 时目标对象已存在会返回 ErrObjectExists
+func (l *LocalStorage) Copy(srcKey, destKey string, force bool) error {
+	if err := l.transfer(srcKey, destKey, force, false); err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	return nil
+}
+
+// Move 移动对象，force 为 false 时目标对象已存在会返回 ErrObjectExists
+func (l *LocalStorage) Move(srcObject, destObject string, force bool) error {
+	if err := l.transfer(srcObject, destObject, force, true); err != nil {
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+	return nil
+}
+
+func (l *LocalStorage) transfer(srcKey, destKey string, force, move bool) error {
+	srcPath, err := l.objectPath(srcKey)
+	if err != nil {
+		return err
+	}
+	destPath, err := l.objectPath(destKey)
+	if err != nil {
+		return err
+	}
+
+	localStorageMu.Lock()
+	defer localStorageMu.Unlock()
+
+	meta, err := l.meta(srcKey)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(destPath); err == nil && !force {
+		return ErrObjectExists
+	}
+	if srcKey == destKey {
+		return nil
+	}
+
+	if move {
+		if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+			return err
+		}
+		if err := os.Rename(srcPath, destPath); err != nil {
+			return err
+		}
+		os.Remove(l.metaPath(srcKey))
+		l.pruneDirs(srcKey)
+		return l.saveMeta(destKey, meta)
+	}
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmpName, _, err := l.writeTemp(src)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpName)
+
+	meta.PutTime = time.Now().UTC()
+	return l.commit(tmpName, destPath, destKey, meta)
+}
+
+// ChangeMeta 修改对象元数据文件中的 MIME 类型和自定义元数据
+func (l *LocalStorage) ChangeMeta(objectName, mimeType string, metaData map[string]string, replace bool) error {
+	if _, err := l.objectPath(objectName); err != nil {
+		return fmt.Errorf("failed to change metadata: %w", err)
+	}
+
+	localStorageMu.Lock()
+	defer localStorageMu.Unlock()
+
+	meta, err := l.meta(objectName)
+	if err != nil {
+		return fmt.Errorf("failed to change metadata: %w", err)
+	}
+	if mimeType != "" {
+		meta.ContentType = mimeType
+	}
+	meta.MetaData = mergeMetaData(meta.MetaData, metaData, replace)
+	if err := l.saveMeta(objectName, meta); err != nil {
+		return fmt.Errorf("failed to change metadata: %w", err)
+	}
+	return nil
+}
+
+// ChangeType 本地后端没有存储类型，不支持修改
+func (l *LocalStorage) ChangeType(objectName string, storageType int) error {
+	return fmt.Errorf("failed to change storage type: %w", ErrOperationNotSupported)
+}
+
+// Restore 本地后端没有归档存储，不支持解冻
+func (l *LocalStorage) Restore(objectName string, freezeAfterDays int) error {
+	return fmt.Errorf("failed to restore file: %w", ErrOperationNotSupported)
+}
+
+// SetLifecycle 本地后端不支持生命周期规则
+func (l *LocalStorage) SetLifecycle(objectName string, rule model.LifecycleRule) error {
+	return fmt.Errorf("failed to set lifecycle: %w", ErrOperationNotSupported)
+}
+
+// Batch 逐个执行批量操作，不支持存储类型和生命周期相关的操作
+func (l *LocalStorage) Batch(ops []model.BatchOperation) ([]model.BatchResult, error) {
+	return batchEach(l, ops), nil
+}
+
+// GeneratePublicURL 返回对象文件的 file:// 地址
+func (l *LocalStorage) GeneratePublicURL(objectName string, opts *model.URLOptions) string {
+	objectPath, err := l.objectPath(objectName)
+	if err != nil {
+		return ""
+	}
+	if abs, err := filepath.Abs(objectPath); err == nil {
+		objectPath = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(objectPath)}).String()
+}
+
+// GeneratePrivateURL 本地后端没有鉴权，只在链接中附带过期时间
+func (l *LocalStorage) GeneratePrivateURL(objectName string, expiryTime int64, opts *model.URLOptions) string {
+	return fmt.Sprintf("%s?e=%d", l.GeneratePublicURL(objectName, nil), expiryTime)
+}
+
+// InitiateMultipartUpload 初始化分片上传任务
+func (l *LocalStorage) InitiateMultipartUpload(objectName string) (string, time.Time, error) {
+	if _, err := l.objectPath(objectName); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+
+	uploadID, err := newRandomID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	upload := localUpload{ObjectName: objectName, ExpireAt: time.Now().Add(multipartUploadTTL).UTC()}
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if err := writeFileAtomic(filepath.Join(l.uploadDir(uploadID), "upload.json"), data); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+	return uploadID, upload.ExpireAt, nil
+}
+
+// UploadPart 保存单个分片，partMD5 不为空时校验分片内容
+func (l *LocalStorage) UploadPart(objectName, uploadID string, partNumber int64, part io.ReadSeeker, partMD5 string) (string, error) {
+	if err := l.checkUpload(objectName, uploadID); err != nil {
+		return "", fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+	}
+
+	hash := md5.New()
+	tmpName, etag, err := l.writeTemp(io.TeeReader(part, hash))
+	if err != nil {
+		return "", fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+	}
+	defer os.Remove(tmpName)
+
+	if partMD5 != "" && hex.EncodeToString(hash.Sum(nil)) != partMD5 {
+		return "", fmt.Errorf("failed to upload part %d: part md5 mismatch", partNumber)
+	}
+
+	info, err := os.Stat(tmpName)
+	if err != nil {
+		return "", err
+	}
+	uploaded := model.UploadedPart{
+		PartNumber:   partNumber,
+		ETag:         etag,
+		Size:         info.Size(),
+		LastModified: time.Now().UTC(),
+	}
+	data, err := json.Marshal(uploaded)
+	if err != nil {
+		return "", err
+	}
+
+	partPath := filepath.Join(l.uploadDir(uploadID), strconv.FormatInt(partNumber, 10))
+	if err := os.Rename(tmpName, partPath+".part"); err != nil {
+		return "", fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+	}
+	if err := writeFileAtomic(partPath+".json", data); err != nil {
+		return "", fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+	}
+	return etag, nil
+}
+
+// ListParts 列出已上传的分片
+func (l *LocalStorage) ListParts(objectName, uploadID string) ([]model.UploadedPart, error) {
+	if err := l.checkUpload(objectName, uploadID); err != nil {
+		return nil, fmt.Errorf("failed to list parts: %w", err)
+	}
+
+	names, err := filepath.Glob(filepath.Join(l.uploadDir(uploadID), "*.part"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list parts: %w", err)
+	}
+
+	parts := make([]model.UploadedPart, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(strings.TrimSuffix(name, ".part") + ".json")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list parts: %w", err)
+		}
+		var part model.UploadedPart
+		if err := json.Unmarshal(data, &part); err != nil {
+			return nil, fmt.Errorf("failed to list parts: %w", err)
+		}
+		parts = append(parts, part)
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].PartNumber < parts[j].PartNumber
+	})
+	return parts, nil
+}
+
+// CompleteMultipartUpload 按 parts 的顺序合成对象
+func (l *LocalStorage) CompleteMultipartUpload(objectName, uploadID, contentType string, parts []model.UploadedPart) (*model.UploadResponse, error) {
+	if err := l.checkUpload(objectName, uploadID); err != nil {
+		return nil, fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+
+	readers := make([]io.Reader, 0, len(parts))
+	for _, part := range parts {
+		file, err := os.Open(filepath.Join(l.uploadDir(uploadID), strconv.FormatInt(part.PartNumber, 10)+".part"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to complete multipart upload: invalid part %d", part.PartNumber)
+		}
+		defer file.Close()
+		readers = append(readers, file)
+	}
+
+	uploadResponse, err := l.Upload(io.MultiReader(readers...), objectName, contentType, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	os.RemoveAll(l.uploadDir(uploadID))
+	return uploadResponse, nil
+}
+
+// AbortMultipartUpload 终止分片上传任务并删除已上传的分片
+func (l *LocalStorage) AbortMultipartUpload(objectName, uploadID string) error {
+	if err := l.checkUpload(objectName, uploadID); err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	if err := os.RemoveAll(l.uploadDir(uploadID)); err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	return nil
+}
+
+func (l *LocalStorage) checkUpload(objectName, uploadID string) error {
+	if !uploadSessionIDPattern.MatchString(uploadID) {
+		return ErrMultipartUploadNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(l.uploadDir(uploadID), "upload.json"))
+	if err != nil {
+		return ErrMultipartUploadNotFound
+	}
+
+	var upload localUpload
+	if err := json.Unmarshal(data, &upload); err != nil {
+		return err
+	}
+	if upload.ObjectName != objectName || time.Now().After(upload.ExpireAt) {
+		return ErrMultipartUploadNotFound
+	}
+	return nil
+}
+
+// objectPath 将对象名称转换为本地路径，拒绝无法安全映射到文件的名称
+func (l *LocalStorage) objectPath(objectName string) (string, error) {
+	if objectName == "" || path.Clean("/"+objectName) != "/"+objectName {
+		return "", fmt.Errorf("object name %q is not supported by the local storage backend", objectName)
+	}
+	return filepath.Join(l.dir, "objects", filepath.FromSlash(objectName)), nil
+}
+
+func (l *LocalStorage) metaPath(objectName string) string {
+	return filepath.Join(l.dir, "meta", filepath.FromSlash(objectName)+".json")
+}
+
+func (l *LocalStorage) uploadDir(uploadID string) string {
+	return filepath.Join(l.dir, "uploads", uploadID)
+}
+
+func (l *LocalStorage) stat(objectName string) (*model.FileInfo, error) {
+	objectPath, err := l.objectPath(objectName)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(objectPath)
+	if err != nil || info.IsDir() {
+		return nil, ErrObjectNotFound
+	}
+
+	meta, err := l.meta(objectName)
+	if err != nil {
+		return nil, err
+	}
+	return &model.FileInfo{
+		Key:           objectName,
+		ContentLength: info.Size(),
+		ETag:          meta.Hash,
+		MimeType:      meta.ContentType,
+		LastModified:  meta.PutTime.Truncate(time.Second),
+		MetaData:      meta.MetaData,
+	}, nil
+}
+
+// meta 读取对象元数据，直接放入 objects 目录的文件没有元数据，按需补齐
+func (l *LocalStorage) meta(objectName string) (*localObjectMeta, error) {
+	data, err := os.ReadFile(l.metaPath(objectName))
+	if err == nil {
+		var meta localObjectMeta
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, err
+		}
+		return &meta, nil
+	}
+
+	objectPath, err := l.objectPath(objectName)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(objectPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := ComputeEtag(file)
+	if err != nil {
+		return nil, err
+	}
+	return &localObjectMeta{ContentType: detectContentType(objectName, ""), Hash: hash, PutTime: info.ModTime().UTC()}, nil
+}
+
+func (l *LocalStorage) saveMeta(objectName string, meta *localObjectMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(l.metaPath(objectName), data)
+}
+
+// writeTemp 将数据写入临时文件，返回文件名和 etag
+func (l *LocalStorage) writeTemp(r io.Reader) (string, string, error) {
+	dir := filepath.Join(l.dir, "tmp")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", "", err
+	}
+	tmp, err := os.CreateTemp(dir, "object-*")
+	if err != nil {
+		return "", "", err
+	}
+
+	hash := newQetagHasher()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", "", err
+	}
+	return tmp.Name(), hash.Sum(), nil
+}
+
+// commit 将临时文件重命名为对象文件并保存元数据，调用方需持有 localStorageMu
+func (l *LocalStorage) commit(tmpName, objectPath, objectName string, meta *localObjectMeta) error {
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, objectPath); err != nil {
+		return err
+	}
+	return l.saveMeta(objectName, meta)
+}
+
+// pruneDirs 删除对象后清理空的上级目录，避免目录与同名对象冲突
+func (l *LocalStorage) pruneDirs(objectName string) {
+	for dir := path.Dir(objectName); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if os.Remove(filepath.Join(l.dir, "objects", filepath.FromSlash(dir))) != nil {
+			break
+		}
+		os.Remove(filepath.Join(l.dir, "meta", filepath.FromSlash(dir)))
+	}
+}
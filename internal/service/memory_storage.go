@@ -0,0 +1,379 @@
+package service
+
+import (
+	"bytes"
+	"crypto/md5"
+	"dooqiniu/internal/model"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ Storage = (*MemoryStorage)(nil)
+
+// 本地后端分片上传任务的有效期，与七牛云保持一致
+const multipartUploadTTL = 7 * 24 * time.Hour
+
+type memoryObject struct {
+	data        []byte
+	contentType string
+	hash        string
+	putTime     time.Time
+	metaData    map[string]string
+}
+
+type memoryUpload struct {
+	objectName string
+	expireAt   time.Time
+	parts      map[int64]*memoryObject
+}
+
+// MemoryStorage 基于内存的存储后端，进程退出后数据丢失，用于测试和演示
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string]*memoryObject
+	uploads map[string]*memoryUpload
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		objects: make(map[string]*memoryObject),
+		uploads: make(map[string]*memoryUpload),
+	}
+}
+
+// Upload 将数据流保存到内存中
+func (m *MemoryStorage) Upload(file io.Reader, objectName, contentType string, metaData map[string]string) (*model.UploadResponse, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("upload failed: %w", err)
+	}
+
+	object := newMemoryObject(data, detectContentType(objectName, contentType))
+	object.metaData = mergeMetaData(nil, metaData, true)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// 与七牛云相同，上传不能覆盖已存在的对象
+	if _, ok := m.objects[objectName]; ok {
+		return nil, fmt.Errorf("upload failed: %w", ErrObjectExists)
+	}
+	m.objects[objectName] = object
+
+	return object.uploadResponse(), nil
+}
+
+// Stat 获取单个对象的信息
+func (m *MemoryStorage) Stat(objectName string) (*model.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	object, ok := m.objects[objectName]
+	if !ok {
+		return nil, fmt.Errorf("failed to stat file: %w", ErrObjectNotFound)
+	}
+	info := object.fileInfo(objectName)
+	return &info, nil
+}
+
+// ListFiles 按 key 的字典序列举对象，marker 为上一页最后一个 key 或公共前缀
+func (m *MemoryStorage) ListFiles(prefix, delimiter, marker string, limit int) ([]model.FileInfo, []string, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]string, 0, len(m.objects))
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) && key > marker {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	keys, commonPrefixes, nextMarker := pageKeys(keys, prefix, delimiter, marker, limit)
+
+	files := make([]model.FileInfo, 0, len(keys))
+	for _, key := range keys {
+		files = append(files, m.objects[key].fileInfo(key))
+	}
+	return files, commonPrefixes, nextMarker, nil
+}
+
+// Open 读取对象内容，返回的数据是上传时内容的快照
+func (m *MemoryStorage) Open(objectName string, offset, length int64) (io.ReadCloser, error) {
+	m.mu.RLock()
+	object, ok := m.objects[objectName]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("failed to open file: %w", ErrObjectNotFound)
+	}
+
+	data := object.data
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	data = data[offset:]
+	if length >= 0 && length < int64(len(data)) {
+		data = data[:length]
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Delete 删除对象
+func (m *MemoryStorage) Delete(objectName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.objects[objectName]; !ok {
+		return fmt.Errorf("failed to delete file: %w", ErrObjectNotFound)
+	}
+	delete(m.objects, objectName)
+	return nil
+}
+
+// Copy 复制对象，force 为 false 时目标对象已存在会返回 ErrObjectExists
+func (m *MemoryStorage) Copy(srcKey, destKey string, force bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkTransfer(srcKey, destKey, force); err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	object := *m.objects[srcKey]
+	object.putTime = time.Now().UTC()
+	m.objects[destKey] = &object
+	return nil
+}
+
+// Move 移动对象，force 为 false 时目标对象已存在会返回 ErrObjectExists
+func (m *MemoryStorage) Move(srcObject, destObject string, force bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkTransfer(srcObject, destObject, force); err != nil {
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+	m.objects[destObject] = m.objects[srcObject]
+	if srcObject != destObject {
+		delete(m.objects, srcObject)
+	}
+	return nil
+}
+
+func (m *MemoryStorage) checkTransfer(src, dest string, force bool) error {
+	if _, ok := m.objects[src]; !ok {
+		return ErrObjectNotFound
+	}
+	if _, ok := m.objects[dest]; ok && !force {
+		return ErrObjectExists
+	}
+	return nil
+}
+
+// ChangeMeta 修改对象的 MIME 类型和自定义元数据，
+// 移动后的对象与原对象共用同一个 memoryObject，因此替换而不是原地修改
+func (m *MemoryStorage) ChangeMeta(objectName, mimeType string, metaData map[string]string, replace bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.objects[objectName]
+	if !ok {
+		return fmt.Errorf("failed to change metadata: %w", ErrObjectNotFound)
+	}
+	object := *current
+	if mimeType != "" {
+		object.contentType = mimeType
+	}
+	object.metaData = mergeMetaData(current.metaData, metaData, replace)
+	m.objects[objectName] = &object
+	return nil
+}
+
+// ChangeType 内存后端没有存储类型，不支持修改
+func (m *MemoryStorage) ChangeType(objectName string, storageType int) error {
+	return fmt.Errorf("failed to change storage type: %w", ErrOperationNotSupported)
+}
+
+// Restore 内存后端没有归档存储，不支持解冻
+func (m *MemoryStorage) Restore(objectName string, freezeAfterDays int) error {
+	return fmt.Errorf("failed to restore file: %w", ErrOperationNotSupported)
+}
+
+// SetLifecycle 内存后端不支持生命周期规则
+func (m *MemoryStorage) SetLifecycle(objectName string, rule model.LifecycleRule) error {
+	return fmt.Errorf("failed to set lifecycle: %w", ErrOperationNotSupported)
+}
+
+// Batch 逐个执行批量操作，不支持存储类型和生命周期相关的操作
+func (m *MemoryStorage) Batch(ops []model.BatchOperation) ([]model.BatchResult, error) {
+	return batchEach(m, ops), nil
+}
+
+// GeneratePublicURL 内存后端没有可访问的域名，返回 memory:// 形式的标识
+func (m *MemoryStorage) GeneratePublicURL(objectName string, opts *model.URLOptions) string {
+	return "memory:///" + url.PathEscape(objectName)
+}
+
+// GeneratePrivateURL 内存后端没有鉴权，只在链接中附带过期时间
+func (m *MemoryStorage) GeneratePrivateURL(objectName string, expiryTime int64, opts *model.URLOptions) string {
+	return fmt.Sprintf("%s?e=%d", m.GeneratePublicURL(objectName, nil), expiryTime)
+}
+
+// InitiateMultipartUpload 初始化分片上传任务
+func (m *MemoryStorage) InitiateMultipartUpload(objectName string) (string, time.Time, error) {
+	uploadID, err := newRandomID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expireAt := time.Now().Add(multipartUploadTTL).UTC()
+
+	m.mu.Lock()
+	m.uploads[uploadID] = &memoryUpload{
+		objectName: objectName,
+		expireAt:   expireAt,
+		parts:      make(map[int64]*memoryObject),
+	}
+	m.mu.Unlock()
+
+	return uploadID, expireAt, nil
+}
+
+// UploadPart 保存单个分片，partMD5 不为空时校验分片内容
+func (m *MemoryStorage) UploadPart(objectName, uploadID string, partNumber int64, part io.ReadSeeker, partMD5 string) (string, error) {
+	data, err := io.ReadAll(part)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+	}
+	if err := checkPartMD5(data, partMD5); err != nil {
+		return "", fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	upload, err := m.upload(objectName, uploadID)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+	}
+	object := newMemoryObject(data, "")
+	upload.parts[partNumber] = object
+	return object.hash, nil
+}
+
+// ListParts 列出已上传的分片
+func (m *MemoryStorage) ListParts(objectName, uploadID string) ([]model.UploadedPart, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	upload, err := m.upload(objectName, uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list parts: %w", err)
+	}
+
+	parts := make([]model.UploadedPart, 0, len(upload.parts))
+	for partNumber, object := range upload.parts {
+		parts = append(parts, model.UploadedPart{
+			PartNumber:   partNumber,
+			ETag:         object.hash,
+			Size:         int64(len(object.data)),
+			LastModified: object.putTime,
+		})
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].PartNumber < parts[j].PartNumber
+	})
+	return parts, nil
+}
+
+// CompleteMultipartUpload 按 parts 的顺序合成对象
+func (m *MemoryStorage) CompleteMultipartUpload(objectName, uploadID, contentType string, parts []model.UploadedPart) (*model.UploadResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	upload, err := m.upload(objectName, uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	if _, ok := m.objects[objectName]; ok {
+		return nil, fmt.Errorf("failed to complete multipart upload: %w", ErrObjectExists)
+	}
+
+	var buf bytes.Buffer
+	for _, part := range parts {
+		object, ok := upload.parts[part.PartNumber]
+		if !ok || object.hash != part.ETag {
+			return nil, fmt.Errorf("failed to complete multipart upload: invalid part %d", part.PartNumber)
+		}
+		buf.Write(object.data)
+	}
+
+	object := newMemoryObject(buf.Bytes(), detectContentType(objectName, contentType))
+	m.objects[objectName] = object
+	delete(m.uploads, uploadID)
+	return object.uploadResponse(), nil
+}
+
+// AbortMultipartUpload 终止分片上传任务并丢弃已上传的分片
+func (m *MemoryStorage) AbortMultipartUpload(objectName, uploadID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.upload(objectName, uploadID); err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	delete(m.uploads, uploadID)
+	return nil
+}
+
+func (m *MemoryStorage) upload(objectName, uploadID string) (*memoryUpload, error) {
+	upload, ok := m.uploads[uploadID]
+	if !ok || upload.objectName != objectName || time.Now().After(upload.expireAt) {
+		return nil, ErrMultipartUploadNotFound
+	}
+	return upload, nil
+}
+
+func newMemoryObject(data []byte, contentType string) *memoryObject {
+	hash, _ := ComputeEtag(bytes.NewReader(data))
+	return &memoryObject{
+		data:        data,
+		contentType: contentType,
+		hash:        hash,
+		putTime:     time.Now().UTC(),
+	}
+}
+
+func (o *memoryObject) fileInfo(key string) model.FileInfo {
+	return model.FileInfo{
+		Key:           key,
+		ContentLength: int64(len(o.data)),
+		ETag:          o.hash,
+		MimeType:      o.contentType,
+		LastModified:  o.putTime.Truncate(time.Second),
+		MetaData:      o.metaData,
+	}
+}
+
+func (o *memoryObject) uploadResponse() *model.UploadResponse {
+	return &model.UploadResponse{
+		ContentLength: int64(len(o.data)),
+		ETag:          o.hash,
+		LastModified:  o.putTime.Truncate(time.Second),
+	}
+}
+
+// checkPartMD5 校验分片内容的十六进制 MD5，expected 为空时跳过
+func checkPartMD5(data []byte, expected string) error {
+	if expected == "" {
+		return nil
+	}
+	sum := md5.Sum(data)
+	if hex.EncodeToString(sum[:]) != expected {
+		return fmt.Errorf("part md5 mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,479 @@
+package service
+
+import (
+	"bytes"
+	"dooqiniu/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// ErrIndexNotEnabled 表示未配置 INDEX_PATH
+	ErrIndexNotEnabled = errors.New("metadata index is not enabled")
+	// ErrIndexNotReady 表示索引尚未完成第一次全量同步
+	ErrIndexNotReady = errors.New("metadata index is not ready, initial crawl in progress")
+	// ErrIndexReconciling 表示已有对账任务正在执行
+	ErrIndexReconciling = errors.New("metadata index reconciliation already running")
+)
+
+var (
+	indexObjectsBucket = []byte("objects")
+	indexMetaBucket    = []byte("meta")
+	lastReconcileKey   = []byte("lastReconcile")
+)
+
+// MetadataIndex 保存在本地 bbolt 文件中的对象元数据索引，
+// 用于在不调用七牛云接口的情况下列举、搜索和统计
+type MetadataIndex struct {
+	db   *bolt.DB
+	path string
+
+	mu sync.Mutex
+	// storage 定期对账使用的存储后端，配置切换后替换
+	storage     Storage
+	reconciling bool
+	lastError   string
+}
+
+// IndexStatus 索引的状态
+type IndexStatus struct {
+	Path          string    `json:"path"`
+	Ready         bool      `json:"ready"`
+	Objects       int64     `json:"objects"`
+	TotalSize     int64     `json:"totalSize"`
+	LastReconcile time.Time `json:"lastReconcile"`
+	Reconciling   bool      `json:"reconciling"`
+	LastError     string    `json:"lastError,omitempty"`
+}
+
+// ReconcileResult 一次对账的结果
+type ReconcileResult struct {
+	Scanned int64 `json:"scanned"`
+	Added   int64 `json:"added"`
+	Updated int64 `json:"updated"`
+	Removed int64 `json:"removed"`
+}
+
+// 进程内只打开一个索引，bbolt 文件同一时间只能被一个进程打开
+var metadataIndex *MetadataIndex
+
+// OpenMetadataIndex 打开或创建索引文件
+func OpenMetadataIndex(path string) (*MetadataIndex, error) {
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metadata index: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(indexObjectsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(indexMetaBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize metadata index: %w", err)
+	}
+	return &MetadataIndex{db: db, path: path}, nil
+}
+
+// EnableMetadataIndex 打开 cfg.IndexPath 的索引并启用，之后 NewStorage 创建的存储后端的写操作都会同步更新索引。
+// 索引从未同步过时立即进行全量同步，之后每隔 cfg.IndexReconcileInterval 使用 cfg 的默认存储空间对账一次
+func EnableMetadataIndex(cfg *model.Config) (*MetadataIndex, error) {
+	idx, err := OpenMetadataIndex(cfg.IndexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// 对账直接使用存储后端，不经过索引
+	storage, err := newBackend(cfg)
+	if err != nil {
+		idx.Close()
+		return nil, err
+	}
+	idx.storage = storage
+	metadataIndex = idx
+
+	go func() {
+		if !idx.Ready() {
+			idx.logReconcile(idx.Reconcile(idx.reconcileStorage()))
+		}
+		ticker := time.NewTicker(cfg.IndexReconcileInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			idx.logReconcile(idx.Reconcile(idx.reconcileStorage()))
+		}
+	}()
+	return idx, nil
+}
+
+// ReloadMetadataIndexStorage 配置切换后按新配置重新创建对账使用的存储后端，
+// 之后的对账使用新的账号和存储空间。未启用索引时不做任何操作
+func ReloadMetadataIndexStorage(old, cfg *model.Config) {
+	idx := metadataIndex
+	if idx == nil {
+		return
+	}
+	storage, err := newBackend(cfg)
+	if err != nil {
+		fmt.Println("Error recreating metadata index storage, keeping the previous one:", err)
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.storage = storage
+}
+
+// reconcileStorage 返回对账使用的存储后端
+func (idx *MetadataIndex) reconcileStorage() Storage {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.storage
+}
+
+// DefaultMetadataIndex 返回已启用的索引，未启用时返回 nil
+func DefaultMetadataIndex() *MetadataIndex {
+	return metadataIndex
+}
+
+// Close 关闭索引文件
+func (idx *MetadataIndex) Close() error {
+	return idx.db.Close()
+}
+
+// Ready 索引是否已完成过全量同步
+func (idx *MetadataIndex) Ready() bool {
+	return !idx.LastReconcile().IsZero()
+}
+
+// LastReconcile 最近一次完成对账的时间
+func (idx *MetadataIndex) LastReconcile() time.Time {
+	var t time.Time
+	idx.db.View(func(tx *bolt.Tx) error {
+		return t.UnmarshalText(tx.Bucket(indexMetaBucket).Get(lastReconcileKey))
+	})
+	return t
+}
+
+// Put 写入或更新对象的元数据
+func (idx *MetadataIndex) Put(info model.FileInfo) error {
+	value, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return idx.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(indexObjectsBucket).Put([]byte(info.Key), value)
+	})
+}
+
+// Delete 删除对象的元数据，对象不存在时不返回错误
+func (idx *MetadataIndex) Delete(objectName string) error {
+	return idx.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(indexObjectsBucket).Delete([]byte(objectName))
+	})
+}
+
+// Stat 从索引中获取单个对象的信息
+func (idx *MetadataIndex) Stat(objectName string) (*model.FileInfo, error) {
+	var info *model.FileInfo
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(indexObjectsBucket).Get([]byte(objectName))
+		if value == nil {
+			return ErrObjectNotFound
+		}
+		info = &model.FileInfo{}
+		return json.Unmarshal(value, info)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ListFiles 从索引中列举对象，参数和返回值与 Storage.ListFiles 一致
+func (idx *MetadataIndex) ListFiles(prefix, delimiter, marker string, limit int) ([]model.FileInfo, []string, string, error) {
+	files := []model.FileInfo{}
+	var commonPrefixes []string
+	nextMarker := ""
+
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(indexObjectsBucket).Cursor()
+
+		start := prefix
+		if marker > start {
+			start = marker
+		}
+		last := ""
+		for k, v := c.Seek([]byte(start)); k != nil && bytes.HasPrefix(k, []byte(prefix)); {
+			key := string(k)
+			if key <= marker {
+				k, v = c.Next()
+				continue
+			}
+			if limit > 0 && len(files)+len(commonPrefixes) >= limit {
+				nextMarker = last
+				return nil
+			}
+
+			if delimiter != "" {
+				if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+					// 公共前缀下的对象只返回一次，直接跳到该前缀之后
+					commonPrefix := key[:len(prefix)+i+len(delimiter)]
+					if commonPrefix > marker {
+						commonPrefixes = append(commonPrefixes, commonPrefix)
+						last = commonPrefix
+					}
+					next := prefixSuccessor(commonPrefix)
+					if next == nil {
+						break
+					}
+					k, v = c.Seek(next)
+					continue
+				}
+			}
+
+			var info model.FileInfo
+			if err := json.Unmarshal(v, &info); err != nil {
+				return err
+			}
+			files = append(files, info)
+			last = key
+			k, v = c.Next()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to list files from index: %w", err)
+	}
+	return files, commonPrefixes, nextMarker, nil
+}
+
+// Walk 按对象名顺序遍历前缀下的所有对象
+func (idx *MetadataIndex) Walk(prefix string, fn func(model.FileInfo) error) error {
+	return idx.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(indexObjectsBucket).Cursor()
+		for k, v := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+			var info model.FileInfo
+			if err := json.Unmarshal(v, &info); err != nil {
+				return err
+			}
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Status 统计索引中的对象数量和总大小
+func (idx *MetadataIndex) Status() (*IndexStatus, error) {
+	status := &IndexStatus{Path: idx.path, LastReconcile: idx.LastReconcile()}
+	status.Ready = !status.LastReconcile.IsZero()
+
+	idx.mu.Lock()
+	status.Reconciling = idx.reconciling
+	status.LastError = idx.lastError
+	idx.mu.Unlock()
+
+	err := idx.Walk("", func(info model.FileInfo) error {
+		status.Objects++
+		status.TotalSize += info.ContentLength
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Reconcile 全量列举存储后端并与索引逐页比对，补充缺失、更新变化并删除多余的条目。
+// 列举结果和索引都按对象名排序，只需在内存中保留一页
+func (idx *MetadataIndex) Reconcile(s Storage) (*ReconcileResult, error) {
+	if err := idx.beginReconcile(); err != nil {
+		return nil, err
+	}
+	result, err := idx.reconcile(s)
+	idx.endReconcile(err)
+	return result, err
+}
+
+// StartReconcile 在后台使用启用索引时的存储后端立即对账一次
+func (idx *MetadataIndex) StartReconcile() error {
+	storage := idx.reconcileStorage()
+	if storage == nil {
+		return ErrIndexNotEnabled
+	}
+	if err := idx.beginReconcile(); err != nil {
+		return err
+	}
+	go func() {
+		result, err := idx.reconcile(storage)
+		idx.endReconcile(err)
+		idx.logReconcile(result, err)
+	}()
+	return nil
+}
+
+// Reconciling 是否正在对账
+func (idx *MetadataIndex) Reconciling() bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.reconciling
+}
+
+func (idx *MetadataIndex) beginReconcile() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.reconciling {
+		return ErrIndexReconciling
+	}
+	idx.reconciling = true
+	return nil
+}
+
+func (idx *MetadataIndex) endReconcile(err error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.reconciling = false
+	idx.lastError = ""
+	if err != nil {
+		idx.lastError = err.Error()
+	}
+}
+
+func (idx *MetadataIndex) logReconcile(result *ReconcileResult, err error) {
+	switch {
+	case errors.Is(err, ErrIndexReconciling):
+	case err != nil:
+		fmt.Println("Error reconciling metadata index:", err)
+	default:
+		fmt.Printf("Metadata index reconciled: scanned %d, added %d, updated %d, removed %d\n",
+			result.Scanned, result.Added, result.Updated, result.Removed)
+	}
+}
+
+func (idx *MetadataIndex) reconcile(s Storage) (*ReconcileResult, error) {
+	result := &ReconcileResult{}
+	it := NewListingIterator(s, "")
+
+	// after 之前（含）的条目已经比对过
+	after := ""
+	for {
+		files, err := it.Next()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return result, err
+		}
+		done := errors.Is(err, io.EOF)
+
+		err = idx.db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(indexObjectsBucket)
+
+			listed := make(map[string]bool, len(files))
+			for _, file := range files {
+				listed[file.Key] = true
+			}
+
+			// 删除索引中位于本页范围内但存储后端已不存在的条目，最后一页之后的条目全部删除
+			var stale [][]byte
+			c := b.Cursor()
+			for k, _ := c.Seek([]byte(after)); k != nil; k, _ = c.Next() {
+				key := string(k)
+				if key <= after {
+					continue
+				}
+				if !done && key > files[len(files)-1].Key {
+					break
+				}
+				if !listed[key] {
+					stale = append(stale, append([]byte{}, k...))
+				}
+			}
+			for _, k := range stale {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+			result.Removed += int64(len(stale))
+
+			for _, file := range files {
+				existing := b.Get([]byte(file.Key))
+				if existing == nil {
+					result.Added++
+				} else {
+					var indexed model.FileInfo
+					if err := json.Unmarshal(existing, &indexed); err == nil {
+						same, err := sameListingFields(indexed, file)
+						if err != nil {
+							return err
+						}
+						if same {
+							continue
+						}
+						// 对象内容没有变化时保留列举不返回的生命周期
+						if indexed.ETag == file.ETag && indexed.LastModified.Equal(file.LastModified) {
+							file.Lifecycle = indexed.Lifecycle
+						}
+					}
+					result.Updated++
+				}
+				value, err := json.Marshal(file)
+				if err != nil {
+					return err
+				}
+				if err := b.Put([]byte(file.Key), value); err != nil {
+					return err
+				}
+			}
+
+			if done {
+				now, _ := time.Now().UTC().MarshalText()
+				return tx.Bucket(indexMetaBucket).Put(lastReconcileKey, now)
+			}
+			return nil
+		})
+		if err != nil {
+			return result, fmt.Errorf("failed to update metadata index: %w", err)
+		}
+
+		result.Scanned += int64(len(files))
+		if done {
+			return result, nil
+		}
+		after = files[len(files)-1].Key
+	}
+}
+
+// sameListingFields 只比较列举结果中包含的字段。Stat 写入的条目还包含生命周期，
+// 直接比较会把这些条目都当作已修改，并用列举结果覆盖掉生命周期
+func sameListingFields(indexed, listed model.FileInfo) (bool, error) {
+	indexed.Lifecycle = nil
+	listed.Lifecycle = nil
+	a, err := json.Marshal(indexed)
+	if err != nil {
+		return false, err
+	}
+	b, err := json.Marshal(listed)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(a, b), nil
+}
+
+// prefixSuccessor 返回大于所有以 prefix 开头的字符串的最小值，不存在时返回 nil
+func prefixSuccessor(prefix string) []byte {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return b[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"bytes"
+	"dooqiniu/internal/model"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// lifecycleStorage 与七牛云相同，Stat 返回对象的生命周期，列举结果不包含生命周期
+type lifecycleStorage struct {
+	*MemoryStorage
+	lifecycles map[string]*model.ObjectLifecycle
+}
+
+func (s *lifecycleStorage) Stat(objectName string) (*model.FileInfo, error) {
+	info, err := s.MemoryStorage.Stat(objectName)
+	if err == nil {
+		info.Lifecycle = s.lifecycles[objectName]
+	}
+	return info, err
+}
+
+func (s *lifecycleStorage) SetLifecycle(objectName string, rule model.LifecycleRule) error {
+	info, err := s.MemoryStorage.Stat(objectName)
+	if err != nil {
+		return err
+	}
+	expiration := info.LastModified.AddDate(0, 0, rule.DeleteAfterDays)
+	s.lifecycles[objectName] = &model.ObjectLifecycle{Expiration: &expiration}
+	return nil
+}
+
+func newLifecycleIndex(t *testing.T) (*lifecycleStorage, *MetadataIndex) {
+	t.Helper()
+	backend := &lifecycleStorage{MemoryStorage: NewMemoryStorage(), lifecycles: make(map[string]*model.ObjectLifecycle)}
+	idx, err := OpenMetadataIndex(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { idx.Close() })
+	return backend, idx
+}
+
+func indexedExpiration(t *testing.T, idx *MetadataIndex, key string) *time.Time {
+	t.Helper()
+	info, err := idx.Stat(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Lifecycle == nil {
+		return nil
+	}
+	return info.Lifecycle.Expiration
+}
+
+func TestIndexedSetLifecycle(t *testing.T) {
+	backend, idx := newLifecycleIndex(t)
+	s := NewIndexedStorage(backend, idx)
+	if _, err := s.Upload(bytes.NewReader([]byte("a")), "a", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := indexedExpiration(t, idx, "a"); got != nil {
+		t.Fatalf("expiration = %v before setting a lifecycle", got)
+	}
+
+	if err := s.SetLifecycle("a", model.LifecycleRule{DeleteAfterDays: 7}); err != nil {
+		t.Fatal(err)
+	}
+	if got := indexedExpiration(t, idx, "a"); got == nil {
+		t.Error("index not updated after SetLifecycle")
+	}
+}
+
+func TestReconcileKeepsStatFields(t *testing.T) {
+	backend, idx := newLifecycleIndex(t)
+	s := NewIndexedStorage(backend, idx)
+	for _, key := range []string{"a", "b"} {
+		if _, err := s.Upload(bytes.NewReader([]byte(key)), key, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SetLifecycle("a", model.LifecycleRule{DeleteAfterDays: 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := idx.Reconcile(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Scanned != 2 || result.Added != 0 || result.Updated != 0 || result.Removed != 0 {
+		t.Errorf("result = %+v, want 2 scanned and nothing changed", result)
+	}
+	if got := indexedExpiration(t, idx, "a"); got == nil {
+		t.Error("reconcile dropped the lifecycle written by Stat")
+	}
+
+	// 对象被替换后列举结果变化，旧的生命周期不再适用
+	if _, err := ReplaceObject(backend, bytes.NewReader([]byte("new content")), "a", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	result, err = idx.Reconcile(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Updated != 1 {
+		t.Errorf("result = %+v, want 1 updated", result)
+	}
+	info, err := idx.Stat("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ContentLength != int64(len("new content")) || info.Lifecycle != nil {
+		t.Errorf("indexed = %+v, want the replaced object without lifecycle", info)
+	}
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 七牛云对自定义元数据的限制：键名最长 50 个字符，所有键值总长度不超过 1024 字节
+const (
+	maxMetaKeyLength = 50
+	maxMetaDataSize  = 1024
+)
+
+var metaKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// NormalizeMetaData 校验自定义元数据并返回键名统一为小写的副本，
+// 键名可以带 x-qn-meta- 前缀，返回结果中会去掉前缀
+func NormalizeMetaData(metaData map[string]string) (map[string]string, error) {
+	if len(metaData) == 0 {
+		return nil, nil
+	}
+
+	normalized := make(map[string]string, len(metaData))
+	size := 0
+	for key, value := range metaData {
+		key = strings.TrimPrefix(strings.ToLower(key), metaPrefix)
+		if len(key) > maxMetaKeyLength || !metaKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid metadata key %q: must be 1 to %d letters, digits, '-' or '_'", key, maxMetaKeyLength)
+		}
+		size += len(metaPrefix) + len(key) + len(value)
+		normalized[key] = value
+	}
+	if size > maxMetaDataSize {
+		return nil, fmt.Errorf("metadata too large: %d bytes, at most %d bytes allowed", size, maxMetaDataSize)
+	}
+	return normalized, nil
+}
+
+// mergeMetaData 计算修改后的自定义元数据，replace 为 false 时在原有元数据上合并，
+// 值为空字符串的键表示删除
+func mergeMetaData(current, changes map[string]string, replace bool) map[string]string {
+	merged := make(map[string]string, len(current)+len(changes))
+	if !replace {
+		for key, value := range current {
+			merged[key] = value
+		}
+	}
+	for key, value := range changes {
+		if value == "" {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = value
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"io"
+	"os"
+)
+
+// ObjectReader 以 io.ReadSeeker 的方式读取对象，按需从存储后端打开数据流，
+// Seek 后从新的偏移量重新打开，可直接交给 http.ServeContent 处理 Range 请求
+type ObjectReader struct {
+	storage Storage
+	key     string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+	bodyPos int64
+}
+
+// NewObjectReader 创建对象读取器，size 为对象大小，通常来自 Stat 的结果
+func NewObjectReader(storage Storage, key string, size int64) *ObjectReader {
+	return &ObjectReader{storage: storage, key: key, size: size}
+}
+
+func (r *ObjectReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body != nil && r.bodyPos != r.offset {
+		r.body.Close()
+		r.body = nil
+	}
+	if r.body == nil {
+		body, err := r.storage.Open(r.key, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+		r.bodyPos = r.offset
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	r.bodyPos += int64(n)
+	return n, err
+}
+
+func (r *ObjectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, os.ErrInvalid
+	}
+	if offset < 0 {
+		return 0, os.ErrInvalid
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *ObjectReader) Close() error {
+	if r.body != nil {
+		err := r.body.Close()
+		r.body = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"dooqiniu/internal/model"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrPrefixDeleteTokenInvalid 表示确认令牌不存在、已过期或与账号、存储空间、前缀不匹配
+	ErrPrefixDeleteTokenInvalid = errors.New("confirm token is invalid or expired")
+	// ErrPrefixDeleteChanged 表示删除时前缀下的对象数量或总大小超过了预览时的统计
+	ErrPrefixDeleteChanged = errors.New("objects under the prefix exceed the dry run, run the dry run again")
+)
+
+const (
+	// 确认令牌的有效期，超时后需要重新预览
+	prefixDeleteTokenTTL = 10 * time.Minute
+	// 每次列举并删除的对象数量
+	prefixDeletePageSize = 1000
+	// 结果中最多保留的失败明细数量
+	maxPrefixDeleteErrors = 100
+)
+
+// 确认令牌适用的操作，立即删除和设置过期删除规则的令牌不能互相使用
+const (
+	PrefixDeleteOpDelete    = "delete"
+	PrefixDeleteOpLifecycle = "lifecycle"
+)
+
+// PrefixDeleteTarget 按前缀删除的对象范围，确认令牌只能用于预览时的操作、账号、存储空间和前缀。
+// 默认账号和默认存储空间为空字符串
+type PrefixDeleteTarget struct {
+	Operation string `json:"operation"`
+	Profile   string `json:"profile,omitempty"`
+	Bucket    string `json:"bucket,omitempty"`
+	Prefix    string `json:"prefix"`
+}
+
+// PrefixDeletePlan 按前缀删除的预览结果
+type PrefixDeletePlan struct {
+	PrefixDeleteTarget
+	Count        int64     `json:"count"`
+	TotalSize    int64     `json:"totalSize"`
+	ConfirmToken string    `json:"confirmToken"`
+	ExpireAt     time.Time `json:"expireAt"`
+}
+
+// PrefixDeleteLimit 预览时统计的对象数量和总大小，确认删除时不会删除超出这个范围的对象
+type PrefixDeleteLimit struct {
+	Count     int64
+	TotalSize int64
+}
+
+// PrefixDeleteProgress 删除进度，每删除一批对象报告一次
+type PrefixDeleteProgress struct {
+	Deleted int64               `json:"deleted"`
+	Failed  int64               `json:"failed"`
+	Errors  []PrefixDeleteError `json:"errors,omitempty"`
+}
+
+// PrefixDeleteError 删除失败的对象
+type PrefixDeleteError struct {
+	Key   string `json:"key"`
+	Code  int    `json:"code"`
+	Error string `json:"error"`
+}
+
+type prefixDeleteToken struct {
+	target   PrefixDeleteTarget
+	limit    PrefixDeleteLimit
+	expireAt time.Time
+}
+
+// 确认令牌只保存在内存中，服务重启后需要重新预览
+var prefixDeleteTokens sync.Map
+
+// PlanPrefixDelete 统计 s 中 target 前缀下的对象数量和总大小，并生成执行删除所需的确认令牌。
+// s 需要是 target 中的账号和存储空间对应的存储后端
+func PlanPrefixDelete(s Storage, target PrefixDeleteTarget) (*PrefixDeletePlan, error) {
+	plan := &PrefixDeletePlan{PrefixDeleteTarget: target}
+	prefix := target.Prefix
+
+	marker := ""
+	for {
+		files, _, nextMarker, err := s.ListFiles(prefix, "", marker, prefixDeletePageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			plan.Count++
+			plan.TotalSize += file.ContentLength
+		}
+		if nextMarker == "" {
+			break
+		}
+		marker = nextMarker
+	}
+
+	token, err := newRandomID()
+	if err != nil {
+		return nil, err
+	}
+	plan.ConfirmToken = token
+	plan.ExpireAt = time.Now().Add(prefixDeleteTokenTTL).UTC()
+
+	// 顺便清理未使用的过期令牌
+	prefixDeleteTokens.Range(func(key, value any) bool {
+		if time.Now().After(value.(prefixDeleteToken).expireAt) {
+			prefixDeleteTokens.Delete(key)
+		}
+		return true
+	})
+	prefixDeleteTokens.Store(token, prefixDeleteToken{
+		target:   target,
+		limit:    PrefixDeleteLimit{Count: plan.Count, TotalSize: plan.TotalSize},
+		expireAt: plan.ExpireAt,
+	})
+	return plan, nil
+}
+
+// ConsumePrefixDeleteToken 校验并作废确认令牌，每个令牌只能使用一次，且只能用于预览时的操作、账号、存储空间和前缀。
+// 返回预览时统计的对象数量和总大小
+func ConsumePrefixDeleteToken(target PrefixDeleteTarget, token string) (*PrefixDeleteLimit, error) {
+	value, ok := prefixDeleteTokens.LoadAndDelete(token)
+	if !ok {
+		return nil, ErrPrefixDeleteTokenInvalid
+	}
+	entry := value.(prefixDeleteToken)
+	if entry.target != target || time.Now().After(entry.expireAt) {
+		return nil, ErrPrefixDeleteTokenInvalid
+	}
+	return &entry.limit, nil
+}
+
+// DeletePrefix 分页列举前缀下的对象并批量删除，每删除一批调用一次 progress。
+// limit 不为空时，列举到的对象数量或总大小超过 limit 则在删除该批对象前停止并返回 ErrPrefixDeleteChanged，
+// 预览之后新上传的对象不会被删除。ctx 取消后不再删除之后的批次
+func DeletePrefix(ctx context.Context, s Storage, prefix string, limit *PrefixDeleteLimit, progress func(PrefixDeleteProgress)) (*PrefixDeleteProgress, error) {
+	result := &PrefixDeleteProgress{}
+	var scannedCount, scannedSize int64
+
+	marker := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		files, _, nextMarker, err := s.ListFiles(prefix, "", marker, prefixDeletePageSize)
+		if err != nil {
+			return result, err
+		}
+
+		for _, file := range files {
+			scannedCount++
+			scannedSize += file.ContentLength
+		}
+		if limit != nil && (scannedCount > limit.Count || scannedSize > limit.TotalSize) {
+			return result, ErrPrefixDeleteChanged
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		if len(files) > 0 {
+			ops := make([]model.BatchOperation, 0, len(files))
+			for _, file := range files {
+				ops = append(ops, model.BatchOperation{Op: model.BatchOpDelete, ObjectName: file.Key})
+			}
+			// 请求中途失败时仍统计已删除的对象，未执行的删除计为失败
+			results, err := s.Batch(ops)
+			if len(results) != len(ops) {
+				return result, err
+			}
+
+			for i, r := range results {
+				// 并发删除导致的对象不存在同样视为删除成功
+				if r.Code == http.StatusOK || r.Code == http.StatusNotFound {
+					result.Deleted++
+					continue
+				}
+				result.Failed++
+				if len(result.Errors) < maxPrefixDeleteErrors {
+					result.Errors = append(result.Errors, PrefixDeleteError{Key: ops[i].ObjectName, Code: r.Code, Error: r.Error})
+				}
+			}
+			if progress != nil {
+				progress(PrefixDeleteProgress{Deleted: result.Deleted, Failed: result.Failed})
+			}
+			if err != nil {
+				return result, err
+			}
+		}
+
+		if nextMarker == "" {
+			return result, nil
+		}
+		marker = nextMarker
+	}
+}
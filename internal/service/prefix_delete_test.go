@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPrefixDeleteTokenBoundToTarget(t *testing.T) {
+	s := NewMemoryStorage()
+	if _, err := s.Upload(strings.NewReader("data"), "tmp/a.txt", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	target := PrefixDeleteTarget{Operation: PrefixDeleteOpDelete, Profile: "acme", Bucket: "backup", Prefix: "tmp/"}
+
+	tests := []struct {
+		name   string
+		target PrefixDeleteTarget
+	}{
+		{"other profile", PrefixDeleteTarget{Operation: PrefixDeleteOpDelete, Profile: "other", Bucket: "backup", Prefix: "tmp/"}},
+		{"default profile", PrefixDeleteTarget{Operation: PrefixDeleteOpDelete, Bucket: "backup", Prefix: "tmp/"}},
+		{"other bucket", PrefixDeleteTarget{Operation: PrefixDeleteOpDelete, Profile: "acme", Bucket: "logs", Prefix: "tmp/"}},
+		{"default bucket", PrefixDeleteTarget{Operation: PrefixDeleteOpDelete, Profile: "acme", Prefix: "tmp/"}},
+		{"other prefix", PrefixDeleteTarget{Operation: PrefixDeleteOpDelete, Profile: "acme", Bucket: "backup", Prefix: "tmp"}},
+		{"other operation", PrefixDeleteTarget{Operation: PrefixDeleteOpLifecycle, Profile: "acme", Bucket: "backup", Prefix: "tmp/"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := PlanPrefixDelete(s, target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plan.Count != 1 || plan.Profile != "acme" || plan.Bucket != "backup" {
+				t.Fatalf("unexpected plan %+v", plan)
+			}
+			if _, err := ConsumePrefixDeleteToken(tt.target, plan.ConfirmToken); !errors.Is(err, ErrPrefixDeleteTokenInvalid) {
+				t.Errorf("err = %v, want ErrPrefixDeleteTokenInvalid", err)
+			}
+		})
+	}
+
+	plan, err := PlanPrefixDelete(s, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ConsumePrefixDeleteToken(target, plan.ConfirmToken); err != nil {
+		t.Fatalf("token rejected for its own target: %v", err)
+	}
+	if _, err := ConsumePrefixDeleteToken(target, plan.ConfirmToken); !errors.Is(err, ErrPrefixDeleteTokenInvalid) {
+		t.Errorf("token accepted twice: %v", err)
+	}
+}
+
+func TestDeletePrefixStopsBeyondDryRun(t *testing.T) {
+	s := NewMemoryStorage()
+	if _, err := s.Upload(strings.NewReader("data"), "tmp/a.txt", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	target := PrefixDeleteTarget{Operation: PrefixDeleteOpDelete, Prefix: "tmp/"}
+	plan, err := PlanPrefixDelete(s, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 预览之后上传的对象没有经过确认，不能被删除
+	if _, err := s.Upload(strings.NewReader("new"), "tmp/b.txt", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	limit, err := ConsumePrefixDeleteToken(target, plan.ConfirmToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := DeletePrefix(context.Background(), s, "tmp/", limit, nil)
+	if !errors.Is(err, ErrPrefixDeleteChanged) {
+		t.Fatalf("err = %v, want ErrPrefixDeleteChanged", err)
+	}
+	if result.Deleted != 0 {
+		t.Errorf("deleted %d objects, want 0", result.Deleted)
+	}
+	if _, err := s.Stat("tmp/b.txt"); err != nil {
+		t.Errorf("object uploaded after the dry run was deleted: %v", err)
+	}
+
+	result, err = DeletePrefix(context.Background(), s, "tmp/", &PrefixDeleteLimit{Count: 2, TotalSize: 7}, nil)
+	if err != nil || result.Deleted != 2 {
+		t.Errorf("result = %+v, err = %v, want 2 deleted", result, err)
+	}
+}
+
+func TestDeletePrefixStopsWhenCancelled(t *testing.T) {
+	s := NewMemoryStorage()
+	if _, err := s.Upload(strings.NewReader("data"), "tmp/a.txt", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := DeletePrefix(ctx, s, "tmp/", nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if result.Deleted != 0 {
+		t.Errorf("deleted %d objects after cancellation", result.Deleted)
+	}
+	if _, err := s.Stat("tmp/a.txt"); err != nil {
+		t.Errorf("object deleted after cancellation: %v", err)
+	}
+}
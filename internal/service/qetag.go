@@ -0,0 +1,69 @@
+package service
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"hash"
+	"io"
+)
+
+// 七牛云 etag 按 4 MB 分块计算
+const qetagBlockSize = 1 << 22
+
+// qetagHasher 以流式方式计算七牛云 etag（qetag），本地存储后端用它生成与七牛云一致的文件 hash
+type qetagHasher struct {
+	block       hash.Hash
+	blockLen    int
+	blockHashes []byte
+}
+
+func newQetagHasher() *qetagHasher {
+	return &qetagHasher{block: sha1.New()}
+}
+
+func (h *qetagHasher) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := qetagBlockSize - h.blockLen
+		if n > len(p) {
+			n = len(p)
+		}
+		h.block.Write(p[:n])
+		h.blockLen += n
+		written += n
+		p = p[n:]
+
+		if h.blockLen == qetagBlockSize {
+			h.blockHashes = h.block.Sum(h.blockHashes)
+			h.block.Reset()
+			h.blockLen = 0
+		}
+	}
+	return written, nil
+}
+
+// Sum 返回 etag：单块文件为 0x16 加块 SHA1，多块文件为 0x96 加各块 SHA1 拼接后的 SHA1
+func (h *qetagHasher) Sum() string {
+	blockHashes := h.blockHashes
+	if h.blockLen > 0 || len(blockHashes) == 0 {
+		blockHashes = h.block.Sum(blockHashes)
+	}
+
+	var etag []byte
+	if len(blockHashes) == sha1.Size {
+		etag = append([]byte{0x16}, blockHashes...)
+	} else {
+		sum := sha1.Sum(blockHashes)
+		etag = append([]byte{0x96}, sum[:]...)
+	}
+	return base64.URLEncoding.EncodeToString(etag)
+}
+
+// ComputeEtag 计算数据流的七牛云 etag
+func ComputeEtag(r io.Reader) (string, error) {
+	h := newQetagHasher()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return h.Sum(), nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"bytes"
+	"testing"
+)
+
+// qetagTestData 返回 n 字节的确定性测试数据
+func qetagTestData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+func TestComputeEtag(t *testing.T) {
+	// 期望值按七牛云文档的算法独立计算：不超过 4 MB 时为 0x16 加 SHA1，
+	// 超过 4 MB 时为 0x96 加各 4 MB 分块 SHA1 拼接后的 SHA1，再做 URL 安全的 base64 编码
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, "Fto5o-5ea0sNMlW_75VgGJCv2AcJ"},
+		{"small", []byte("hello world"), "FiqubDXJT8-0FdvpX0CLnOke6Ebt"},
+		{"exactly one block", qetagTestData(qetagBlockSize), "Fgd8eREZ4FXnoK5eUHCJo_kRSDb1"},
+		{"one byte over one block", qetagTestData(qetagBlockSize + 1), "lgV4TNEnA2AXSRVyDqVW4bohMKad"},
+		{"multiple blocks", qetagTestData(2*qetagBlockSize + 100), "lsRQ8qpKv5o9hnpjLdh5Ie8j2naP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ComputeEtag(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("etag = %s, want %s", got, tt.want)
+			}
+
+			// 写入的分段与 4 MB 分块边界不对齐时结果相同
+			h := newQetagHasher()
+			for data := tt.data; len(data) > 0; {
+				n := min(len(data), 1<<20+7)
+				h.Write(data[:n])
+				data = data[n:]
+			}
+			if got := h.Sum(); got != tt.want {
+				t.Errorf("streamed etag = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"bufio"
+	"dooqiniu/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 不在任何子目录下的对象归入该前缀
+const rootPrefixLabel = "(root)"
+
+// storageTypeNames 七牛云存储类型编号对应的名称
+var storageTypeNames = map[int]string{
+	0: "standard",
+	1: "ia",
+	2: "archive",
+	3: "deep_archive",
+	4: "archive_ir",
+}
+
+// ageBuckets 按最后修改时间划分的年龄区间，从新到旧排列
+var ageBuckets = []struct {
+	name   string
+	maxAge time.Duration
+}{
+	{"<7d", 7 * 24 * time.Hour},
+	{"7d-30d", 30 * 24 * time.Hour},
+	{"30d-90d", 90 * 24 * time.Hour},
+	{"90d-1y", 365 * 24 * time.Hour},
+}
+
+// UsageCount 一组对象的数量和总大小
+type UsageCount struct {
+	Objects   int64 `json:"objects"`
+	TotalSize int64 `json:"totalSize"`
+}
+
+func (u *UsageCount) add(size int64) {
+	u.Objects++
+	u.TotalSize += size
+}
+
+// StatsScope 统计范围，快照按统计范围区分
+type StatsScope struct {
+	// Profile 命名账号的名称，默认账号为空
+	Profile string `json:"profile,omitempty"`
+	// Bucket 命名存储空间的名称，默认存储空间为空
+	Bucket string `json:"bucket,omitempty"`
+	Prefix string `json:"prefix"`
+}
+
+// UsageStats 存储空间用量统计
+type UsageStats struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	Source      string    `json:"source"`
+	StatsScope
+	UsageCount
+	// ByPrefix 按 prefix 之后的第一级目录分组
+	ByPrefix      map[string]*UsageCount `json:"byPrefix"`
+	ByMimeType    map[string]*UsageCount `json:"byMimeType"`
+	ByStorageType map[string]*UsageCount `json:"byStorageType"`
+	ByAge         map[string]*UsageCount `json:"byAge"`
+}
+
+// ComputeUsageStats 遍历 scope.Prefix 下的所有对象并分组统计，scope 中的账号和存储空间只用于标记快照
+func ComputeUsageStats(l FileLister, source string, scope StatsScope) (*UsageStats, error) {
+	now := time.Now().UTC()
+	stats := &UsageStats{
+		GeneratedAt:   now,
+		Source:        source,
+		StatsScope:    scope,
+		ByPrefix:      make(map[string]*UsageCount),
+		ByMimeType:    make(map[string]*UsageCount),
+		ByStorageType: make(map[string]*UsageCount),
+		ByAge:         make(map[string]*UsageCount),
+	}
+
+	it := NewListingIterator(l, scope.Prefix)
+	for {
+		files, err := it.Next()
+		if errors.Is(err, io.EOF) {
+			return stats, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			stats.add(file.ContentLength)
+			usageGroup(stats.ByPrefix, topLevelPrefix(scope.Prefix, file.Key)).add(file.ContentLength)
+			usageGroup(stats.ByMimeType, file.MimeType).add(file.ContentLength)
+			usageGroup(stats.ByStorageType, StorageTypeName(file.StorageType)).add(file.ContentLength)
+			usageGroup(stats.ByAge, ageBucket(now.Sub(file.LastModified))).add(file.ContentLength)
+		}
+	}
+}
+
+func usageGroup(groups map[string]*UsageCount, name string) *UsageCount {
+	if name == "" {
+		name = "unknown"
+	}
+	group, ok := groups[name]
+	if !ok {
+		group = &UsageCount{}
+		groups[name] = group
+	}
+	return group
+}
+
+// topLevelPrefix 返回对象名在 prefix 之后的第一级目录，例如 prefix 为空时 a/b/c 返回 a/
+func topLevelPrefix(prefix, key string) string {
+	rest := strings.TrimPrefix(key, prefix)
+	if i := strings.Index(rest, "/"); i >= 0 {
+		return prefix + rest[:i+1]
+	}
+	return rootPrefixLabel
+}
+
+// StorageTypeName 返回存储类型编号对应的名称，例如 0 返回 standard，未知类型返回 type_<编号>
+func StorageTypeName(storageType int) string {
+	if name, ok := storageTypeNames[storageType]; ok {
+		return name
+	}
+	return fmt.Sprintf("type_%d", storageType)
+}
+
+func ageBucket(age time.Duration) string {
+	for _, bucket := range ageBuckets {
+		if age < bucket.maxAge {
+			return bucket.name
+		}
+	}
+	return ">1y"
+}
+
+// StatsStore 将用量统计快照按时间顺序追加保存到本地的 JSON Lines 文件，用于绘制增长趋势
+type StatsStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// 同一个快照文件在进程内共用一个 StatsStore，保证追加和读取互斥
+var statsStores sync.Map
+
+// NewStatsStore 返回在 dir 目录下保存快照的 StatsStore
+func NewStatsStore(dir string) *StatsStore {
+	path := filepath.Join(dir, "snapshots.jsonl")
+	store, _ := statsStores.LoadOrStore(path, &StatsStore{path: path})
+	return store.(*StatsStore)
+}
+
+// StartStatsSnapshots 每隔 interval 统计一次整个存储空间并保存快照，
+// 索引可用时从索引统计，否则直接列举 currentConfig 返回的配置中的默认存储空间。
+// 每次统计时调用 currentConfig，重新加载配置后使用新的账号和存储空间
+func StartStatsSnapshots(store *StatsStore, interval time.Duration, currentConfig func() *model.Config) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := takeStatsSnapshot(store, currentConfig()); err != nil {
+				fmt.Println("Error taking stats snapshot:", err)
+			}
+		}
+	}()
+}
+
+func takeStatsSnapshot(store *StatsStore, cfg *model.Config) error {
+	var (
+		lister FileLister
+		source = "index"
+	)
+	if idx := DefaultMetadataIndex(); idx != nil && idx.Ready() {
+		lister = idx
+	} else {
+		storage, err := newBackend(cfg)
+		if err != nil {
+			return err
+		}
+		lister, source = storage, "live"
+	}
+
+	stats, err := ComputeUsageStats(lister, source, StatsScope{})
+	if err != nil {
+		return err
+	}
+	return store.Append(stats)
+}
+
+// Append 追加一个快照
+func (s *StatsStore) Append(stats *UsageStats) error {
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("failed to encode stats snapshot: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to save stats snapshot: %w", err)
+	}
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to save stats snapshot: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to save stats snapshot: %w", err)
+	}
+	return nil
+}
+
+// History 按时间顺序返回统计范围在 [since, until) 范围内的快照，时间为零值表示不限制
+func (s *StatsStore) History(scope StatsScope, since, until time.Time) ([]UsageStats, error) {
+	snapshots := []UsageStats{}
+	err := s.each(func(stats UsageStats) {
+		if stats.StatsScope != scope {
+			return
+		}
+		if !since.IsZero() && stats.GeneratedAt.Before(since) {
+			return
+		}
+		if !until.IsZero() && !stats.GeneratedAt.Before(until) {
+			return
+		}
+		snapshots = append(snapshots, stats)
+	})
+	return snapshots, err
+}
+
+// Latest 返回统计范围最近的快照，没有快照时返回 nil
+func (s *StatsStore) Latest(scope StatsScope) (*UsageStats, error) {
+	var latest *UsageStats
+	err := s.each(func(stats UsageStats) {
+		if stats.StatsScope == scope && (latest == nil || stats.GeneratedAt.After(latest.GeneratedAt)) {
+			latest = &stats
+		}
+	})
+	return latest, err
+}
+
+func (s *StatsStore) each(fn func(UsageStats)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read stats snapshots: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	// 分组较多时单个快照可能超过默认的 64 KB 行长度限制
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var stats UsageStats
+		// 跳过写入中断产生的不完整行
+		if err := json.Unmarshal(scanner.Bytes(), &stats); err != nil {
+			continue
+		}
+		fn(stats)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stats snapshots: %w", err)
+	}
+	return nil
+}
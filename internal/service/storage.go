@@ -0,0 +1,220 @@
+package service
+
+import (
+	"dooqiniu/internal/model"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrObjectNotFound 表示对象不存在
+	ErrObjectNotFound = errors.New("object not found")
+	// ErrObjectExists 表示目标对象已存在且未指定强制覆盖
+	ErrObjectExists = errors.New("object already exists")
+	// ErrMultipartUploadNotFound 表示分片上传任务不存在或已过期
+	ErrMultipartUploadNotFound = errors.New("multipart upload not found")
+	// ErrOperationNotSupported 表示存储后端不支持该操作
+	ErrOperationNotSupported = errors.New("operation not supported by storage backend")
+)
+
+// FileLister 列举对象的接口，存储后端和元数据索引都实现了该接口
+type FileLister interface {
+	// ListFiles 按前缀列举对象，返回下一页的游标，没有更多数据时游标为空。
+	// delimiter 不为空时，key 在前缀之后包含 delimiter 的对象按目录归并到公共前缀中返回
+	ListFiles(prefix, delimiter, marker string, limit int) ([]model.FileInfo, []string, string, error)
+}
+
+// Storage 对象存储后端接口，HTTP 接口只依赖该接口，
+// 七牛云之外还提供本地磁盘和内存实现，便于离线开发和测试
+type Storage interface {
+	// Upload 只能新增对象，与七牛云的上传凭证一致，目标对象已存在时返回 ErrObjectExists，需要覆盖时使用 PutObject
+	model.Uploader
+	FileLister
+
+	// Stat 获取单个对象的信息，对象不存在时返回 ErrObjectNotFound
+	Stat(objectName string) (*model.FileInfo, error)
+	// Open 从 offset 处读取对象内容，length 小于 0 表示读取到对象末尾
+	Open(objectName string, offset, length int64) (io.ReadCloser, error)
+	Delete(objectName string) error
+	Copy(srcKey, destKey string, force bool) error
+	Move(srcObject, destObject string, force bool) error
+	// ChangeMeta 修改对象的 MIME 类型和自定义元数据，mimeType 为空时保持不变。
+	// replace 为 false 时在原有元数据上合并，值为空字符串的键表示删除；replace 为 true 时整体替换
+	ChangeMeta(objectName, mimeType string, metaData map[string]string, replace bool) error
+	// ChangeType 修改对象的存储类型，本地和内存后端返回 ErrOperationNotSupported
+	ChangeType(objectName string, storageType int) error
+	// Restore 解冻归档存储或深度归档存储的对象，freezeAfterDays 为解冻后的有效天数
+	Restore(objectName string, freezeAfterDays int) error
+	// SetLifecycle 修改对象的生命周期规则
+	SetLifecycle(objectName string, rule model.LifecycleRule) error
+	// Batch 批量执行操作，返回与 ops 一一对应的结果，单个操作失败不影响其他操作。
+	// 请求失败时仍返回与 ops 一一对应的结果，已执行的操作为实际结果，未执行的操作为 BatchCodeNotExecuted
+	Batch(ops []model.BatchOperation) ([]model.BatchResult, error)
+
+	// opts 为 nil 表示不附加参数，本地和内存后端不支持数据处理，忽略 opts
+	GeneratePublicURL(objectName string, opts *model.URLOptions) string
+	GeneratePrivateURL(objectName string, expiryTime int64, opts *model.URLOptions) string
+
+	// 分片上传
+	InitiateMultipartUpload(objectName string) (string, time.Time, error)
+	UploadPart(objectName, uploadID string, partNumber int64, part io.ReadSeeker, partMD5 string) (string, error)
+	ListParts(objectName, uploadID string) ([]model.UploadedPart, error)
+	// CompleteMultipartUpload 与 Upload 相同，目标对象已存在时返回 ErrObjectExists
+	CompleteMultipartUpload(objectName, uploadID, contentType string, parts []model.UploadedPart) (*model.UploadResponse, error)
+	AbortMultipartUpload(objectName, uploadID string) error
+}
+
+const (
+	StorageBackendQiniu  = "qiniu"
+	StorageBackendLocal  = "local"
+	StorageBackendMemory = "memory"
+)
+
+var (
+	// 内存后端需要在请求之间共享数据，整个进程只创建一个实例
+	memoryStorage     *MemoryStorage
+	memoryStorageOnce sync.Once
+)
+
+// NewStorage 根据配置中的 STORAGE_BACKEND 创建存储后端，默认使用七牛云。
+// 启用了元数据索引时，返回的存储后端会在写操作后同步更新索引
+func NewStorage(cfg *model.Config) (Storage, error) {
+	storage, err := newBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if metadataIndex != nil {
+		return NewIndexedStorage(storage, metadataIndex), nil
+	}
+	return storage, nil
+}
+
+func newBackend(cfg *model.Config) (Storage, error) {
+	switch cfg.StorageBackend {
+	case "", StorageBackendQiniu:
+		return qiniuProfileClient(cfg, storageKey{})
+	case StorageBackendLocal:
+		return NewLocalStorage(cfg.LocalStorageDir), nil
+	case StorageBackendMemory:
+		memoryStorageOnce.Do(func() {
+			memoryStorage = NewMemoryStorage()
+		})
+		return memoryStorage, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.StorageBackend)
+	}
+}
+
+// tempObjectSuffix 覆盖已存在的对象时，先写入带该后缀的临时 key 再强制移动到目标 key
+const tempObjectSuffix = ".dooqiniu-tmp-"
+
+// TempObjectKey 返回 key 对应的随机临时 key，用于先上传再移动到 key 以覆盖已存在的对象
+func TempObjectKey(key string) (string, error) {
+	id, err := newRandomID()
+	if err != nil {
+		return "", err
+	}
+	return key + tempObjectSuffix + id[:16], nil
+}
+
+// PutObject 上传对象，目标对象已存在时覆盖。对象不存在时直接上传，
+// 已存在时通过 ReplaceObject 先上传到临时 key 再移动到目标 key
+func PutObject(s Storage, file io.Reader, objectName, contentType string, metaData map[string]string) (*model.UploadResponse, error) {
+	_, err := s.Stat(objectName)
+	if errors.Is(err, ErrObjectNotFound) {
+		return s.Upload(file, objectName, contentType, metaData)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ReplaceObject(s, file, objectName, contentType, metaData)
+}
+
+// ReplaceObject 覆盖已存在的对象。七牛云的上传不能覆盖同名对象，先上传到临时 key，再强制移动到目标 key，
+// 上传失败时原对象不受影响
+func ReplaceObject(s Storage, file io.Reader, objectName, contentType string, metaData map[string]string) (*model.UploadResponse, error) {
+	tmpKey, err := TempObjectKey(objectName)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.Upload(file, tmpKey, contentType, metaData)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Move(tmpKey, objectName, true); err != nil {
+		s.Delete(tmpKey)
+		return nil, err
+	}
+	return result, nil
+}
+
+// pageKeys 对已按字典序排序、且都大于 marker 的 key 进行分页，
+// delimiter 不为空时将同一公共前缀下的 key 归并为一项，公共前缀与对象一起计入 limit
+func pageKeys(keys []string, prefix, delimiter, marker string, limit int) ([]string, []string, string) {
+	var (
+		objects  []string
+		prefixes []string
+		last     string
+	)
+	for _, key := range keys {
+		commonPrefix := ""
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				commonPrefix = key[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		// 游标为公共前缀时，跳过该前缀下的其余 key
+		if commonPrefix != "" && (commonPrefix <= marker || commonPrefix == last) {
+			continue
+		}
+
+		if limit > 0 && len(objects)+len(prefixes) >= limit {
+			return objects, prefixes, last
+		}
+		if commonPrefix != "" {
+			prefixes = append(prefixes, commonPrefix)
+			last = commonPrefix
+		} else {
+			objects = append(objects, key)
+			last = key
+		}
+	}
+	return objects, prefixes, ""
+}
+
+// byteRange 生成 HTTP Range 请求头，length 小于 0 表示到末尾
+func byteRange(offset, length int64) string {
+	if length < 0 {
+		return "bytes=" + strconv.FormatInt(offset, 10) + "-"
+	}
+	return "bytes=" + strconv.FormatInt(offset, 10) + "-" + strconv.FormatInt(offset+length-1, 10)
+}
+
+// limitReadCloser 限制读取的长度，length 小于 0 时不限制
+func limitReadCloser(rc io.ReadCloser, length int64) io.ReadCloser {
+	if length < 0 {
+		return rc
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(rc, length), rc}
+}
+
+// detectContentType 未指定 MIME 类型时按扩展名推断，与七牛云的默认行为保持一致
+func detectContentType(objectName, contentType string) string {
+	if contentType != "" {
+		return contentType
+	}
+	if byExt := mime.TypeByExtension(path.Ext(objectName)); byExt != "" {
+		return byExt
+	}
+	return "application/octet-stream"
+}
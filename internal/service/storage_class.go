@@ -0,0 +1,146 @@
+package service
+
+import (
+	"dooqiniu/internal/model"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const (
+	// 解冻后有效天数的范围
+	minFreezeAfterDays = 1
+	maxFreezeAfterDays = 7
+	// 批量修改结果中最多保留的失败明细数量
+	maxObjectErrors = 100
+)
+
+// 归档对象的解冻状态
+const (
+	RestoreStateNotArchived = "not_archived"
+	RestoreStateFrozen      = "frozen"
+	RestoreStateRestoring   = "restoring"
+	RestoreStateRestored    = "restored"
+)
+
+// ValidateStorageType 校验存储类型编号
+func ValidateStorageType(storageType int) error {
+	if _, ok := storageTypeNames[storageType]; !ok {
+		return fmt.Errorf("invalid storage type: %d", storageType)
+	}
+	return nil
+}
+
+// ValidateFreezeAfterDays 校验解冻后的有效天数
+func ValidateFreezeAfterDays(days int) error {
+	if days < minFreezeAfterDays || days > maxFreezeAfterDays {
+		return fmt.Errorf("freezeAfterDays must be between %d and %d", minFreezeAfterDays, maxFreezeAfterDays)
+	}
+	return nil
+}
+
+// ValidateLifecycleRule 校验生命周期规则，至少需要修改一项
+func ValidateLifecycleRule(rule model.LifecycleRule) error {
+	days := []int{rule.ToIAAfterDays, rule.ToArchiveIRAfterDays, rule.ToArchiveAfterDays, rule.ToDeepArchiveAfterDays, rule.DeleteAfterDays}
+	changed := false
+	for _, d := range days {
+		if d < -1 {
+			return fmt.Errorf("invalid lifecycle days: %d, must be positive or -1 to cancel", d)
+		}
+		changed = changed || d != 0
+	}
+	if !changed {
+		return fmt.Errorf("lifecycle rule is empty")
+	}
+	return nil
+}
+
+// RestoreState 根据存储类型和解冻状态返回归档对象当前的解冻状态
+func RestoreState(info *model.FileInfo) string {
+	if info.StorageType != 2 && info.StorageType != 3 {
+		return RestoreStateNotArchived
+	}
+	switch info.RestoreStatus {
+	case 1:
+		return RestoreStateRestoring
+	case 2:
+		return RestoreStateRestored
+	default:
+		return RestoreStateFrozen
+	}
+}
+
+// ObjectError 批量修改中失败的对象
+type ObjectError struct {
+	Key   string `json:"key"`
+	Code  int    `json:"code"`
+	Error string `json:"error"`
+}
+
+// BatchSummary 对多个对象执行同一操作的汇总结果
+type BatchSummary struct {
+	Matched   int64         `json:"matched"`
+	Succeeded int64         `json:"succeeded"`
+	Failed    int64         `json:"failed"`
+	Errors    []ObjectError `json:"errors,omitempty"`
+}
+
+func (b *BatchSummary) add(ops []model.BatchOperation, results []model.BatchResult) {
+	for i, r := range results {
+		b.Matched++
+		if r.Code == http.StatusOK {
+			b.Succeeded++
+			continue
+		}
+		b.Failed++
+		if len(b.Errors) < maxObjectErrors {
+			b.Errors = append(b.Errors, ObjectError{Key: ops[i].ObjectName, Code: r.Code, Error: r.Error})
+		}
+	}
+}
+
+// ApplyToObjects 对 objectNames 中的对象逐批执行 newOp 生成的操作，
+// objectNames 为空时分页列举 prefix 下的所有对象执行
+func ApplyToObjects(s Storage, objectNames []string, prefix string, newOp func(objectName string) model.BatchOperation) (*BatchSummary, error) {
+	summary := &BatchSummary{}
+	apply := func(keys []string) error {
+		ops := make([]model.BatchOperation, 0, len(keys))
+		for _, key := range keys {
+			ops = append(ops, newOp(key))
+		}
+		// 请求中途失败时仍统计已执行的操作
+		results, err := s.Batch(ops)
+		if len(results) == len(ops) {
+			summary.add(ops, results)
+		}
+		return err
+	}
+
+	if len(objectNames) > 0 {
+		for start := 0; start < len(objectNames); start += listingPageSize {
+			if err := apply(objectNames[start:min(start+listingPageSize, len(objectNames))]); err != nil {
+				return summary, err
+			}
+		}
+		return summary, nil
+	}
+
+	it := NewListingIterator(s, prefix)
+	for {
+		files, err := it.Next()
+		if errors.Is(err, io.EOF) {
+			return summary, nil
+		}
+		if err != nil {
+			return summary, err
+		}
+		keys := make([]string, 0, len(files))
+		for _, file := range files {
+			keys = append(keys, file.Key)
+		}
+		if err := apply(keys); err != nil {
+			return summary, err
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package service
+
+import (
+	"dooqiniu/internal/model"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 同步方向
+const (
+	SyncUpload   = "upload"
+	SyncDownload = "download"
+)
+
+// 同步中对单个文件执行的操作
+const (
+	SyncActionUpload   = "upload"
+	SyncActionDownload = "download"
+	SyncActionDelete   = "delete"
+)
+
+// 执行操作的原因
+const (
+	SyncReasonNew        = "new"
+	SyncReasonChanged    = "changed"
+	SyncReasonExtraneous = "extraneous"
+)
+
+// SyncOptions 同步选项
+type SyncOptions struct {
+	// Delete 删除目标端存在而源端不存在的文件，被 Include、Exclude 排除的文件不会删除
+	Delete bool
+	// DryRun 只比较并报告需要执行的操作，不修改任何文件
+	DryRun bool
+	// Workers 并发比较和传输的文件数
+	Workers int
+	// Include 不为空时只同步匹配其中任一模式的文件，Exclude 排除匹配任一模式的文件。
+	// 模式规则与 path.Match 相同，不包含 / 的模式匹配文件名，包含 / 的模式匹配相对路径
+	Include []string
+	Exclude []string
+}
+
+// SyncAction 同步中执行（或 DryRun 时将要执行）的一个操作
+type SyncAction struct {
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+	Key    string `json:"key"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Error  string `json:"error,omitempty"`
+}
+
+// SyncSummary 同步结果
+type SyncSummary struct {
+	Direction string `json:"direction"`
+	DryRun    bool   `json:"dryRun,omitempty"`
+	// Transferred 上传或下载的文件数，Bytes 为传输的字节数
+	Transferred int   `json:"transferred"`
+	Bytes       int64 `json:"bytes"`
+	Deleted     int   `json:"deleted"`
+	// Unchanged 大小和 etag 都相同而跳过的文件数，Excluded 为被 Include、Exclude 排除的文件数
+	Unchanged int           `json:"unchanged"`
+	Excluded  int           `json:"excluded"`
+	Failed    int           `json:"failed"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// syncItem 相对路径相同的本地文件和对象，其中一端可能不存在
+type syncItem struct {
+	rel    string
+	local  *localFile
+	remote *model.FileInfo
+}
+
+type localFile struct {
+	path string
+	size int64
+}
+
+// Sync 比较本地目录 dir 与存储空间中 prefix 下的对象，按 direction 将源端新增或修改的文件上传或下载到目标端。
+// 大小相同时在本地计算七牛云 etag 比较内容，只传输有变化的文件。每个操作完成后调用 report，
+// 单个文件失败不影响其他文件，计入 Failed；列举对象或遍历目录失败时返回错误
+func Sync(s Storage, direction, dir, prefix string, opts SyncOptions, report func(SyncAction)) (*SyncSummary, error) {
+	started := time.Now()
+	if direction != SyncUpload && direction != SyncDownload {
+		return nil, fmt.Errorf("unknown sync direction: %s", direction)
+	}
+	for _, pattern := range append(slices.Clone(opts.Include), opts.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+
+	summary := &SyncSummary{Direction: direction, DryRun: opts.DryRun}
+	locals, localExcluded, err := walkLocal(dir, direction == SyncDownload, opts)
+	if err != nil {
+		return nil, err
+	}
+	remotes, remoteExcluded, err := listRemote(s, prefix, opts)
+	if err != nil {
+		return nil, err
+	}
+	summary.Excluded = localExcluded
+	if direction == SyncDownload {
+		summary.Excluded = remoteExcluded
+	}
+
+	items := make(map[string]*syncItem, len(locals))
+	for rel, file := range locals {
+		items[rel] = &syncItem{rel: rel, local: file}
+	}
+	for rel, info := range remotes {
+		if item, ok := items[rel]; ok {
+			item.remote = info
+		} else {
+			items[rel] = &syncItem{rel: rel, remote: info}
+		}
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		jobs = make(chan *syncItem)
+	)
+	for range opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range jobs {
+				action, err := syncOne(s, direction, dir, prefix, item, opts)
+				mu.Lock()
+				switch {
+				case action == nil:
+					summary.Unchanged++
+				case err != nil:
+					summary.Failed++
+					action.Error = err.Error()
+				case action.Action == SyncActionDelete:
+					summary.Deleted++
+				default:
+					summary.Transferred++
+					summary.Bytes += action.Size
+				}
+				if action != nil && report != nil {
+					report(*action)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, rel := range slices.Sorted(maps.Keys(items)) {
+		jobs <- items[rel]
+	}
+	close(jobs)
+	wg.Wait()
+
+	summary.Duration = time.Since(started)
+	return summary, nil
+}
+
+// syncOne 比较并同步一个文件，不需要操作时返回 nil，也不返回错误
+func syncOne(s Storage, direction, dir, prefix string, item *syncItem, opts SyncOptions) (*SyncAction, error) {
+	action := &SyncAction{Key: prefix + item.rel, Path: filepath.Join(dir, filepath.FromSlash(item.rel))}
+	srcExists, destExists := item.local != nil, item.remote != nil
+	if direction == SyncDownload {
+		srcExists, destExists = destExists, srcExists
+	}
+
+	// 只在目标端存在的文件
+	if !srcExists {
+		if !opts.Delete {
+			return nil, nil
+		}
+		action.Action, action.Reason = SyncActionDelete, SyncReasonExtraneous
+		if direction == SyncUpload {
+			action.Size = item.remote.ContentLength
+		} else {
+			action.Size = item.local.size
+		}
+		switch {
+		case opts.DryRun:
+			return action, nil
+		case direction == SyncUpload:
+			return action, s.Delete(action.Key)
+		default:
+			return action, os.Remove(action.Path)
+		}
+	}
+
+	action.Action, action.Reason = SyncActionUpload, SyncReasonNew
+	if direction == SyncUpload {
+		action.Size = item.local.size
+	} else {
+		action.Action, action.Size = SyncActionDownload, item.remote.ContentLength
+	}
+	if destExists {
+		action.Reason = SyncReasonChanged
+		// 大小相同时计算本地文件的 etag 与对象的 hash 比较
+		if item.local.size == item.remote.ContentLength {
+			etag, err := fileEtag(item.local.path)
+			if err != nil {
+				return action, err
+			}
+			if etag == item.remote.ETag {
+				return nil, nil
+			}
+		}
+	}
+	if opts.DryRun {
+		return action, nil
+	}
+
+	switch {
+	case direction == SyncDownload:
+		_, err := DownloadFile(s, action.Key, action.Path)
+		return action, err
+	case destExists:
+		return action, replaceObject(s, item.local.path, action.Key)
+	default:
+		return action, uploadLocalFile(s, item.local.path, action.Key)
+	}
+}
+
+// replaceObject 通过 ReplaceObject 用本地文件覆盖已存在的对象
+func replaceObject(s Storage, file, key string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = ReplaceObject(s, f, key, "", nil)
+	return err
+}
+
+func uploadLocalFile(s Storage, file, key string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = s.Upload(f, key, "", nil)
+	return err
+}
+
+func fileEtag(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return ComputeEtag(f)
+}
+
+// DownloadFile 将对象下载到本地文件，先写入同一目录下的临时文件，完成后再重命名，失败时不留下不完整的文件
+func DownloadFile(s Storage, key, file string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".dooqiniu-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	reader, err := s.Open(key, 0, -1)
+	if err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	defer reader.Close()
+
+	size, err := io.Copy(tmp, reader)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return 0, err
+	}
+	return size, os.Rename(tmp.Name(), file)
+}
+
+// walkLocal 遍历本地目录中的普通文件，返回以 / 分隔的相对路径。allowMissing 为 true 时目录不存在视为空目录
+func walkLocal(dir string, allowMissing bool, opts SyncOptions) (map[string]*localFile, int, error) {
+	files := make(map[string]*localFile)
+	excluded := 0
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) && allowMissing {
+		return files, 0, nil
+	}
+
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// 跟随指向普通文件的符号链接，跳过其他特殊文件
+		info, err := os.Stat(file)
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !syncIncluded(rel, opts) {
+			excluded++
+			return nil
+		}
+		files[rel] = &localFile{path: file, size: info.Size()}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to walk %s: %w", dir, err)
+	}
+	return files, excluded, nil
+}
+
+// listRemote 列举 prefix 下的对象，返回去掉 prefix 后的相对路径。
+// 以 / 结尾的目录占位对象、未完成的临时对象以及无法作为本地路径的 key 被跳过
+func listRemote(s Storage, prefix string, opts SyncOptions) (map[string]*model.FileInfo, int, error) {
+	objects := make(map[string]*model.FileInfo)
+	excluded := 0
+	it := NewListingIterator(s, prefix)
+	for {
+		files, err := it.Next()
+		if err == io.EOF {
+			return objects, excluded, nil
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+		for i := range files {
+			rel := strings.TrimPrefix(files[i].Key, prefix)
+			if rel == "" || strings.HasSuffix(rel, "/") || strings.Contains(rel, tempObjectSuffix) || !filepath.IsLocal(filepath.FromSlash(rel)) {
+				continue
+			}
+			if !syncIncluded(rel, opts) {
+				excluded++
+				continue
+			}
+			objects[rel] = &files[i]
+		}
+	}
+}
+
+// syncIncluded 判断相对路径是否需要同步
+func syncIncluded(rel string, opts SyncOptions) bool {
+	if len(opts.Include) > 0 && !syncMatchAny(opts.Include, rel) {
+		return false
+	}
+	return !syncMatchAny(opts.Exclude, rel)
+}
+
+func syncMatchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSyncFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func uploadSyncObjects(t *testing.T, s Storage, objects map[string]string) {
+	t.Helper()
+	for key, content := range objects {
+		if _, err := s.Upload(strings.NewReader(content), key, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// syncObjects 返回存储空间中所有对象的内容
+func syncObjects(t *testing.T, s Storage) map[string]string {
+	t.Helper()
+	objects := make(map[string]string)
+	files, _, _, err := s.ListFiles("", "", "", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		reader, err := s.Open(file.Key, 0, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		objects[file.Key] = string(data)
+	}
+	return objects
+}
+
+func assertSyncObjects(t *testing.T, s Storage, want map[string]string) {
+	t.Helper()
+	got := syncObjects(t, s)
+	if len(got) != len(want) {
+		t.Errorf("objects = %v, want %v", got, want)
+		return
+	}
+	for key, content := range want {
+		if got[key] != content {
+			t.Errorf("object %s = %q, want %q", key, got[key], content)
+		}
+	}
+}
+
+// syncAndCollect 执行同步并按 key 返回报告的操作
+func syncAndCollect(t *testing.T, s Storage, direction, dir string, opts SyncOptions) (*SyncSummary, map[string]SyncAction) {
+	t.Helper()
+	actions := make(map[string]SyncAction)
+	summary, err := Sync(s, direction, dir, "backup", opts, func(action SyncAction) {
+		actions[action.Key] = action
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summary, actions
+}
+
+func TestSyncUploadDeleteWithExclude(t *testing.T) {
+	s := NewMemoryStorage()
+	dir := t.TempDir()
+	writeSyncFiles(t, dir, map[string]string{
+		"same.txt":     "same",
+		"changed.txt":  "new!",
+		"docs/new.txt": "new file",
+		"debug.log":    "excluded local file",
+	})
+	remote := map[string]string{
+		"backup/same.txt":    "same",
+		"backup/changed.txt": "old!",
+		"backup/gone.txt":    "removed locally",
+		"backup/keep.log":    "excluded remote file",
+		"other/gone.txt":     "outside the prefix",
+	}
+	uploadSyncObjects(t, s, remote)
+	opts := SyncOptions{Delete: true, DryRun: true, Exclude: []string{"*.log"}}
+
+	// DryRun 报告与实际同步相同的操作，但不修改存储空间
+	summary, actions := syncAndCollect(t, s, SyncUpload, dir, opts)
+	if summary.Transferred != 2 || summary.Deleted != 1 || summary.Unchanged != 1 || summary.Excluded != 1 || summary.Failed != 0 {
+		t.Errorf("dry run summary = %+v", summary)
+	}
+	want := map[string]string{
+		"backup/changed.txt":  SyncReasonChanged,
+		"backup/docs/new.txt": SyncReasonNew,
+		"backup/gone.txt":     SyncReasonExtraneous,
+	}
+	if len(actions) != len(want) {
+		t.Errorf("actions = %v", actions)
+	}
+	for key, reason := range want {
+		if actions[key].Reason != reason {
+			t.Errorf("action for %s = %+v, want reason %s", key, actions[key], reason)
+		}
+	}
+	assertSyncObjects(t, s, remote)
+
+	opts.DryRun = false
+	summary, _ = syncAndCollect(t, s, SyncUpload, dir, opts)
+	if summary.Transferred != 2 || summary.Deleted != 1 || summary.Failed != 0 {
+		t.Errorf("summary = %+v", summary)
+	}
+	// 被排除的 keep.log 和前缀之外的对象不会删除，覆盖不留下临时对象
+	assertSyncObjects(t, s, map[string]string{
+		"backup/same.txt":     "same",
+		"backup/changed.txt":  "new!",
+		"backup/docs/new.txt": "new file",
+		"backup/keep.log":     "excluded remote file",
+		"other/gone.txt":      "outside the prefix",
+	})
+
+	summary, actions = syncAndCollect(t, s, SyncUpload, dir, opts)
+	if len(actions) != 0 || summary.Unchanged != 3 {
+		t.Errorf("second sync summary = %+v, actions = %v, want everything unchanged", summary, actions)
+	}
+}
+
+func TestSyncDownloadDeleteWithExclude(t *testing.T) {
+	s := NewMemoryStorage()
+	dir := t.TempDir()
+	uploadSyncObjects(t, s, map[string]string{
+		"backup/a.txt":     "remote",
+		"backup/debug.log": "excluded remote file",
+	})
+	writeSyncFiles(t, dir, map[string]string{
+		"gone.txt": "removed remotely",
+		"keep.log": "excluded local file",
+	})
+	opts := SyncOptions{Delete: true, DryRun: true, Exclude: []string{"*.log"}}
+
+	summary, actions := syncAndCollect(t, s, SyncDownload, dir, opts)
+	if summary.Transferred != 1 || summary.Deleted != 1 || summary.Excluded != 1 {
+		t.Errorf("dry run summary = %+v", summary)
+	}
+	if actions["backup/gone.txt"].Action != SyncActionDelete {
+		t.Errorf("actions = %v, want gone.txt deleted", actions)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gone.txt")); err != nil {
+		t.Errorf("dry run removed a local file: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("dry run downloaded a file: %v", err)
+	}
+
+	opts.DryRun = false
+	if summary, _ = syncAndCollect(t, s, SyncDownload, dir, opts); summary.Failed != 0 {
+		t.Errorf("summary = %+v", summary)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gone.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("extraneous local file not deleted: %v", err)
+	}
+	for rel, want := range map[string]string{"a.txt": "remote", "keep.log": "excluded local file"} {
+		data, err := os.ReadFile(filepath.Join(dir, rel))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v, want %q", rel, data, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "debug.log")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("excluded object downloaded: %v", err)
+	}
+}
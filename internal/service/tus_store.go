@@ -0,0 +1,204 @@
+package service
+
+import (
+	"bytes"
+	"dooqiniu/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTusUploadNotFound 表示 tus 上传任务不存在或已终止
+	ErrTusUploadNotFound = errors.New("tus upload not found")
+	// ErrTusChecksumMismatch 表示分块数据与 Upload-Checksum 不一致，分块已被丢弃
+	ErrTusChecksumMismatch = errors.New("tus checksum mismatch")
+	// ErrTusUploadLocked 表示同一上传任务正在被其他请求写入
+	ErrTusUploadLocked = errors.New("tus upload is locked")
+)
+
+// TusUploadTTL 上传任务从创建起的有效期，过期后任务信息和暂存的数据都会被删除
+const TusUploadTTL = 24 * time.Hour
+
+// 正在被写入的上传任务，同一任务同时只允许一个请求写入，释放锁时删除
+var tusLocks sync.Map
+
+// TusStore 将 tus 上传任务的信息和数据暂存在本地目录，
+// 已接收的偏移量即数据文件的大小，服务重启后可继续上传
+type TusStore struct {
+	dir string
+}
+
+func NewTusStore(cfg *model.Config) *TusStore {
+	return &TusStore{dir: cfg.TusUploadDir}
+}
+
+// Create 创建上传任务及空的数据文件
+func (s *TusStore) Create(upload *model.TusUpload) error {
+	id, err := newRandomID()
+	if err != nil {
+		return err
+	}
+	upload.ID = id
+
+	if err := s.Save(upload); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.dataPath(id), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create upload data: %w", err)
+	}
+	return file.Close()
+}
+
+// Save 保存上传任务信息
+func (s *TusStore) Save(upload *model.TusUpload) error {
+	if !uploadSessionIDPattern.MatchString(upload.ID) {
+		return fmt.Errorf("invalid upload id: %s", upload.ID)
+	}
+
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return fmt.Errorf("failed to encode upload: %w", err)
+	}
+	if err := writeFileAtomic(s.infoPath(upload.ID), data); err != nil {
+		return fmt.Errorf("failed to save upload: %w", err)
+	}
+	return nil
+}
+
+// Get 读取上传任务信息及当前偏移量
+func (s *TusStore) Get(id string) (*model.TusUpload, int64, error) {
+	if !uploadSessionIDPattern.MatchString(id) {
+		return nil, 0, ErrTusUploadNotFound
+	}
+
+	data, err := os.ReadFile(s.infoPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, ErrTusUploadNotFound
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read upload: %w", err)
+	}
+
+	var upload model.TusUpload
+	if err := json.Unmarshal(data, &upload); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode upload: %w", err)
+	}
+	if time.Now().After(upload.CreatedAt.Add(TusUploadTTL)) {
+		s.Delete(id)
+		return nil, 0, ErrTusUploadNotFound
+	}
+
+	// 写入七牛云后数据文件已删除，偏移量即总长度
+	if upload.Result != nil {
+		return &upload, upload.Length, nil
+	}
+
+	info, err := os.Stat(s.dataPath(id))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read upload data: %w", err)
+	}
+	return &upload, info.Size(), nil
+}
+
+// Lock 获取上传任务的写锁，返回的函数用于释放锁。只有持有锁期间才占用 tusLocks 中的记录
+func (s *TusStore) Lock(id string) (func(), error) {
+	if _, locked := tusLocks.LoadOrStore(id, struct{}{}); locked {
+		return nil, ErrTusUploadLocked
+	}
+	return func() { tusLocks.Delete(id) }, nil
+}
+
+// RemoveExpired 删除超过 TusUploadTTL 的上传任务，包括已完成但客户端未再查询的任务
+func (s *TusStore) RemoveExpired() error {
+	names, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		id := strings.TrimSuffix(filepath.Base(name), ".json")
+		unlock, err := s.Lock(id)
+		if err != nil {
+			continue
+		}
+		// Get 发现任务过期时会将其删除
+		s.Get(id)
+		unlock()
+	}
+	return nil
+}
+
+// Append 从 offset 处追加分块数据，最多写入 limit 字节。
+// checksum 不为空时校验分块摘要，不一致则丢弃本次写入的数据
+func (s *TusStore) Append(id string, offset, limit int64, r io.Reader, checksum hash.Hash, expected []byte) (int64, error) {
+	file, err := os.OpenFile(s.dataPath(id), os.O_WRONLY, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open upload data: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to seek upload data: %w", err)
+	}
+
+	var w io.Writer = file
+	if checksum != nil {
+		w = io.MultiWriter(file, checksum)
+	}
+
+	// 客户端中断连接时保留已接收的数据，便于从新的偏移量继续上传
+	written, copyErr := io.Copy(w, io.LimitReader(r, limit))
+	if checksum != nil && (copyErr != nil || !bytes.Equal(checksum.Sum(nil), expected)) {
+		if err := file.Truncate(offset); err != nil {
+			return 0, fmt.Errorf("failed to discard chunk: %w", err)
+		}
+		if copyErr != nil {
+			return 0, copyErr
+		}
+		return 0, ErrTusChecksumMismatch
+	}
+	return written, copyErr
+}
+
+// Open 打开已接收完整的数据文件
+func (s *TusStore) Open(id string) (*os.File, error) {
+	return os.Open(s.dataPath(id))
+}
+
+// RemoveData 删除数据文件，保留任务信息供客户端查询结果
+func (s *TusStore) RemoveData(id string) error {
+	if err := os.Remove(s.dataPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove upload data: %w", err)
+	}
+	return nil
+}
+
+// Delete 删除上传任务的信息和数据
+func (s *TusStore) Delete(id string) error {
+	if !uploadSessionIDPattern.MatchString(id) {
+		return ErrTusUploadNotFound
+	}
+	if err := s.RemoveData(id); err != nil {
+		return err
+	}
+	if err := os.Remove(s.infoPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete upload: %w", err)
+	}
+	return nil
+}
+
+func (s *TusStore) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func (s *TusStore) dataPath(id string) string {
+	return filepath.Join(s.dir, id+".bin")
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"dooqiniu/internal/model"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTusStoreLockIsReleased(t *testing.T) {
+	store := NewTusStore(&model.Config{TusUploadDir: t.TempDir()})
+
+	unlock, err := store.Lock("upload-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Lock("upload-a"); !errors.Is(err, ErrTusUploadLocked) {
+		t.Errorf("second Lock: err = %v, want ErrTusUploadLocked", err)
+	}
+	unlock()
+
+	// 释放后不再占用 tusLocks 中的记录
+	if _, ok := tusLocks.Load("upload-a"); ok {
+		t.Error("lock entry left after unlock")
+	}
+	unlock, err = store.Lock("upload-a")
+	if err != nil {
+		t.Fatalf("Lock after unlock: %v", err)
+	}
+	unlock()
+}
+
+func TestTusStoreRemovesExpiredUploads(t *testing.T) {
+	dir := t.TempDir()
+	store := NewTusStore(&model.Config{TusUploadDir: dir})
+
+	expired := &model.TusUpload{ObjectName: "old.bin", Length: 10, CreatedAt: time.Now().Add(-TusUploadTTL - time.Minute)}
+	active := &model.TusUpload{ObjectName: "new.bin", Length: 10, CreatedAt: time.Now()}
+	for _, upload := range []*model.TusUpload{expired, active} {
+		if err := store.Create(upload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.RemoveExpired(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Get(expired.ID); !errors.Is(err, ErrTusUploadNotFound) {
+		t.Errorf("expired upload: err = %v, want ErrTusUploadNotFound", err)
+	}
+	if _, err := os.Stat(store.dataPath(expired.ID)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expired upload data was not removed: %v", err)
+	}
+	if _, _, err := store.Get(active.ID); err != nil {
+		t.Errorf("active upload: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"crypto/rand"
+	"dooqiniu/internal/model"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// ErrUploadSessionNotFound 表示上传会话不存在或已结束
+var ErrUploadSessionNotFound = errors.New("upload session not found")
+
+// 会话 ID 为 32 位十六进制字符串，校验后才会拼接为文件路径
+var uploadSessionIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// UploadSessionStore 将分片上传会话持久化到本地目录，服务重启后客户端仍可继续上传。
+// 分片本身的状态以七牛云为准，会话文件只记录对象名称与 uploadId 的映射
+type UploadSessionStore struct {
+	dir string
+}
+
+func NewUploadSessionStore(cfg *model.Config) *UploadSessionStore {
+	return &UploadSessionStore{dir: cfg.UploadSessionDir}
+}
+
+// NewUploadSessionID 生成随机的会话 ID
+func NewUploadSessionID() (string, error) {
+	return newRandomID()
+}
+
+// Save 保存上传会话
+func (s *UploadSessionStore) Save(session *model.UploadSession) error {
+	if !uploadSessionIDPattern.MatchString(session.ID) {
+		return fmt.Errorf("invalid session id: %s", session.ID)
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	if err := writeFileAtomic(s.path(session.ID), data); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// Get 读取上传会话，会话不存在时返回 ErrUploadSessionNotFound
+func (s *UploadSessionStore) Get(id string) (*model.UploadSession, error) {
+	if !uploadSessionIDPattern.MatchString(id) {
+		return nil, ErrUploadSessionNotFound
+	}
+
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrUploadSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+
+	var session model.UploadSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return &session, nil
+}
+
+// Delete 删除上传会话
+func (s *UploadSessionStore) Delete(id string) error {
+	if !uploadSessionIDPattern.MatchString(id) {
+		return ErrUploadSessionNotFound
+	}
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+func (s *UploadSessionStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// newRandomID 生成 32 位十六进制随机 ID
+func newRandomID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免留下不完整的文件
+func writeFileAtomic(name string, data []byte) error {
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
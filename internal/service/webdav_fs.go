@@ -0,0 +1,480 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"dooqiniu/internal/model"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+var _ webdav.FileSystem = (*WebDAVFileSystem)(nil)
+
+// 列举目录时每次向存储后端请求的对象数量
+const webdavListLimit = 1000
+
+// WebDAVFileSystem 将对象存储适配为 WebDAV 文件系统。对象存储没有目录的概念，
+// 以 "/" 分隔的前缀视为目录，MKCOL 创建的空目录以 "目录名/" 的空对象占位
+type WebDAVFileSystem struct {
+	storage Storage
+}
+
+func NewWebDAVFileSystem(storage Storage) *WebDAVFileSystem {
+	return &WebDAVFileSystem{storage: storage}
+}
+
+// Mkdir 创建目录占位对象
+func (fs *WebDAVFileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	key := webdavKey(name)
+	if key == "" {
+		return os.ErrExist
+	}
+	if _, err := fs.Stat(ctx, name); err == nil {
+		return os.ErrExist
+	}
+	if _, err := fs.storage.Upload(bytes.NewReader(nil), key+"/", "", nil); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	return nil
+}
+
+// OpenFile 打开文件或目录，带 O_CREATE 等写入标志时返回上传用的文件
+func (fs *WebDAVFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	key := webdavKey(name)
+
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		if key == "" || flag&os.O_APPEND != 0 {
+			return nil, os.ErrPermission
+		}
+		info, err := fs.Stat(ctx, name)
+		if err == nil && info.IsDir() {
+			return nil, os.ErrPermission
+		}
+		if err != nil && flag&os.O_CREATE == 0 {
+			return nil, err
+		}
+		// 保存已存在的文件时需要覆盖原对象
+		return newWebDAVWriter(ctx, fs.storage, key, err == nil), nil
+	}
+
+	info, err := fs.Stat(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &webdavDir{fs: fs, key: key, info: info}, nil
+	}
+	fileInfo := info.(*webdavFileInfo)
+	return &webdavReader{
+		ObjectReader: NewObjectReader(fs.storage, key, fileInfo.size),
+		storage:      fs.storage,
+		key:          key,
+		info:         fileInfo,
+	}, nil
+}
+
+// RemoveAll 删除文件，或删除目录前缀下的所有对象
+func (fs *WebDAVFileSystem) RemoveAll(ctx context.Context, name string) error {
+	key := webdavKey(name)
+	if key == "" {
+		return os.ErrPermission
+	}
+
+	info, err := fs.Stat(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		if err := fs.storage.Delete(key); err != nil {
+			return webdavError(err)
+		}
+		return nil
+	}
+
+	keys, err := fs.listKeys(key + "/")
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := fs.storage.Delete(k); err != nil && !errors.Is(err, ErrObjectNotFound) {
+			return webdavError(err)
+		}
+	}
+	return nil
+}
+
+// Rename 移动文件，或逐个移动目录前缀下的所有对象
+func (fs *WebDAVFileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	oldKey, newKey := webdavKey(oldName), webdavKey(newName)
+	if oldKey == "" || newKey == "" {
+		return os.ErrPermission
+	}
+
+	info, err := fs.Stat(ctx, oldName)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		if err := fs.storage.Move(oldKey, newKey, true); err != nil {
+			return webdavError(err)
+		}
+		return nil
+	}
+
+	if strings.HasPrefix(newKey+"/", oldKey+"/") {
+		return os.ErrInvalid
+	}
+	keys, err := fs.listKeys(oldKey + "/")
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := fs.storage.Move(k, newKey+strings.TrimPrefix(k, oldKey), true); err != nil {
+			return webdavError(err)
+		}
+	}
+	return nil
+}
+
+// Stat 先按对象查找，对象不存在时若存在以 name/ 为前缀的对象则视为目录
+func (fs *WebDAVFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	key := webdavKey(name)
+	if key == "" {
+		return &webdavFileInfo{name: "/", dir: true}, nil
+	}
+
+	info, err := fs.storage.Stat(key)
+	if err == nil {
+		return newWebDAVFileInfo(*info), nil
+	}
+	if !errors.Is(err, ErrObjectNotFound) {
+		return nil, err
+	}
+
+	files, _, _, err := fs.storage.ListFiles(key+"/", "", "", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, os.ErrNotExist
+	}
+	return &webdavFileInfo{name: path.Base(key), dir: true, modTime: files[0].LastModified}, nil
+}
+
+// listKeys 列举前缀下的所有对象名，先全部列出再操作，避免边列举边修改影响分页
+func (fs *WebDAVFileSystem) listKeys(prefix string) ([]string, error) {
+	var keys []string
+	marker := ""
+	for {
+		files, _, nextMarker, err := fs.storage.ListFiles(prefix, "", marker, webdavListLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			keys = append(keys, file.Key)
+		}
+		if nextMarker == "" {
+			return keys, nil
+		}
+		marker = nextMarker
+	}
+}
+
+// webdavKey 将 WebDAV 路径转换为对象名，根目录对应空字符串
+func webdavKey(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// webdavError 将存储后端的错误转换为 webdav 包能识别的错误
+func webdavError(err error) error {
+	switch {
+	case errors.Is(err, ErrObjectNotFound):
+		return os.ErrNotExist
+	case errors.Is(err, ErrObjectExists):
+		return os.ErrExist
+	default:
+		return err
+	}
+}
+
+// webdavFileInfo 实现 os.FileInfo，同时提供 ETag 和 Content-Type 供 webdav 包使用
+type webdavFileInfo struct {
+	name     string
+	size     int64
+	modTime  time.Time
+	dir      bool
+	etag     string
+	mimeType string
+}
+
+func newWebDAVFileInfo(info model.FileInfo) *webdavFileInfo {
+	return &webdavFileInfo{
+		name:     path.Base(info.Key),
+		size:     info.ContentLength,
+		modTime:  info.LastModified,
+		etag:     info.ETag,
+		mimeType: info.MimeType,
+	}
+}
+
+func (fi *webdavFileInfo) Name() string       { return fi.name }
+func (fi *webdavFileInfo) Size() int64        { return fi.size }
+func (fi *webdavFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *webdavFileInfo) IsDir() bool        { return fi.dir }
+func (fi *webdavFileInfo) Sys() interface{}   { return nil }
+
+func (fi *webdavFileInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+// ETag 使用对象的七牛 etag，没有 etag 时由 webdav 包按修改时间和大小生成
+func (fi *webdavFileInfo) ETag(ctx context.Context) (string, error) {
+	if fi.etag == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return `"` + fi.etag + `"`, nil
+}
+
+func (fi *webdavFileInfo) ContentType(ctx context.Context) (string, error) {
+	if fi.mimeType == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return fi.mimeType, nil
+}
+
+// webdavDir 目录，Readdir 返回前缀下的直接子文件和子目录
+type webdavDir struct {
+	fs       *WebDAVFileSystem
+	key      string
+	info     os.FileInfo
+	children []os.FileInfo
+	listed   bool
+}
+
+func (d *webdavDir) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.listed {
+		children, err := d.list()
+		if err != nil {
+			return nil, err
+		}
+		d.children = children
+		d.listed = true
+	}
+
+	if count <= 0 {
+		children := d.children
+		d.children = nil
+		return children, nil
+	}
+	if len(d.children) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(d.children) {
+		count = len(d.children)
+	}
+	children := d.children[:count]
+	d.children = d.children[count:]
+	return children, nil
+}
+
+func (d *webdavDir) list() ([]os.FileInfo, error) {
+	prefix := ""
+	if d.key != "" {
+		prefix = d.key + "/"
+	}
+
+	dirs := make(map[string]*webdavFileInfo)
+	var children []os.FileInfo
+	marker := ""
+	for {
+		files, _, nextMarker, err := d.fs.storage.ListFiles(prefix, "", marker, webdavListLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			rest := strings.TrimPrefix(file.Key, prefix)
+			if rest == "" {
+				// 当前目录的占位对象
+				continue
+			}
+			name, _, isDir := strings.Cut(rest, "/")
+			if !isDir {
+				children = append(children, newWebDAVFileInfo(file))
+				continue
+			}
+			if dir, ok := dirs[name]; ok {
+				if file.LastModified.After(dir.modTime) {
+					dir.modTime = file.LastModified
+				}
+				continue
+			}
+			dirs[name] = &webdavFileInfo{name: name, dir: true, modTime: file.LastModified}
+			children = append(children, dirs[name])
+		}
+		if nextMarker == "" {
+			break
+		}
+		marker = nextMarker
+	}
+
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Name() < children[j].Name()
+	})
+	return children, nil
+}
+
+func (d *webdavDir) Stat() (os.FileInfo, error)                   { return d.info, nil }
+func (d *webdavDir) Close() error                                 { return nil }
+func (d *webdavDir) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (d *webdavDir) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+func (d *webdavDir) Write(p []byte) (int, error)                  { return 0, os.ErrPermission }
+
+// webdavReader 只读文件，数据读取由 ObjectReader 完成
+type webdavReader struct {
+	*ObjectReader
+	storage Storage
+	key     string
+	info    *webdavFileInfo
+}
+
+func (f *webdavReader) Read(p []byte) (int, error) {
+	n, err := f.ObjectReader.Read(p)
+	if err != nil && err != io.EOF {
+		err = webdavError(err)
+	}
+	return n, err
+}
+
+// WriteTo 目标同样是本文件系统中的文件时（COPY 请求）直接在存储端复制，不经过本服务中转数据
+func (f *webdavReader) WriteTo(w io.Writer) (int64, error) {
+	if dst, ok := w.(*webdavWriter); ok && dst.storage == f.storage && f.offset == 0 && dst.size == 0 {
+		dst.abort(nil)
+		if err := f.storage.Copy(f.key, dst.key, true); err != nil {
+			return 0, webdavError(err)
+		}
+		f.offset = f.info.size
+		return f.info.size, nil
+	}
+	return io.Copy(w, struct{ io.Reader }{f})
+}
+
+func (f *webdavReader) Stat() (os.FileInfo, error)               { return f.info, nil }
+func (f *webdavReader) Readdir(count int) ([]os.FileInfo, error) { return nil, os.ErrInvalid }
+func (f *webdavReader) Write(p []byte) (int, error)              { return 0, os.ErrPermission }
+
+// webdavWriter 写入的数据通过管道流式上传，Close 时等待上传完成
+type webdavWriter struct {
+	ctx     context.Context
+	storage Storage
+	key     string
+	size    int64
+	pw      *io.PipeWriter
+	done    chan error
+	aborted bool
+}
+
+// newWebDAVWriter 创建上传用的文件，replace 为 true 时通过 ReplaceObject 覆盖已存在的对象
+func newWebDAVWriter(ctx context.Context, storage Storage, key string, replace bool) *webdavWriter {
+	pr, pw := io.Pipe()
+	w := &webdavWriter{
+		ctx:     ctx,
+		storage: storage,
+		key:     key,
+		pw:      pw,
+		done:    make(chan error, 1),
+	}
+	go func() {
+		var err error
+		if replace {
+			_, err = ReplaceObject(storage, pr, key, "", nil)
+		} else {
+			_, err = storage.Upload(pr, key, "", nil)
+		}
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w
+}
+
+func (w *webdavWriter) Write(p []byte) (int, error) {
+	if w.aborted {
+		return 0, os.ErrClosed
+	}
+	n, err := w.pw.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// abort 放弃上传，err 为空表示由其他方式完成写入
+func (w *webdavWriter) abort(err error) {
+	if err == nil {
+		err = errWebDAVUploadAborted
+	}
+	w.aborted = true
+	w.pw.CloseWithError(err)
+	<-w.done
+}
+
+var errWebDAVUploadAborted = errors.New("webdav upload aborted")
+
+// Close 请求体未完整读取时放弃上传，避免留下截断的文件
+func (w *webdavWriter) Close() error {
+	if w.aborted {
+		return nil
+	}
+	if body, ok := w.ctx.Value(webdavBodyKey{}).(*webdavBody); ok && !body.complete {
+		w.abort(io.ErrUnexpectedEOF)
+		return io.ErrUnexpectedEOF
+	}
+
+	w.pw.Close()
+	if err := <-w.done; err != nil {
+		return fmt.Errorf("upload failed: %w", err)
+	}
+	return nil
+}
+
+func (w *webdavWriter) Stat() (os.FileInfo, error) {
+	return &webdavFileInfo{name: path.Base(w.key), size: w.size, modTime: time.Now().UTC()}, nil
+}
+
+func (w *webdavWriter) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (w *webdavWriter) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+func (w *webdavWriter) Readdir(count int) ([]os.FileInfo, error)     { return nil, os.ErrInvalid }
+
+type webdavBodyKey struct{}
+
+// webdavBody 记录请求体是否已读到末尾
+type webdavBody struct {
+	io.ReadCloser
+	complete bool
+}
+
+func (b *webdavBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.complete = true
+	}
+	return n, err
+}
+
+// TrackWebDAVUpload 记录 PUT 请求体是否完整，webdav 包在请求体中断时仍会关闭文件，
+// 文件系统据此判断是提交上传还是放弃上传
+func TrackWebDAVUpload(r *http.Request) *http.Request {
+	body := &webdavBody{ReadCloser: r.Body}
+	r = r.WithContext(context.WithValue(r.Context(), webdavBodyKey{}, body))
+	r.Body = body
+	return r
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestWebDAVSaveOverwritesFile(t *testing.T) {
+	backends := map[string]Storage{
+		"memory": NewMemoryStorage(),
+		"local":  NewLocalStorage(t.TempDir()),
+	}
+	for name, s := range backends {
+		t.Run(name, func(t *testing.T) {
+			fs := NewWebDAVFileSystem(s)
+			ctx := context.Background()
+
+			// 第二次保存覆盖已存在的文件，与七牛云相同，后端的上传本身不能覆盖
+			for _, content := range []string{"first version", "second"} {
+				f, err := fs.OpenFile(ctx, "/docs/note.txt", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := io.WriteString(f, content); err != nil {
+					t.Fatal(err)
+				}
+				if err := f.Close(); err != nil {
+					t.Fatalf("save %q: %v", content, err)
+				}
+			}
+
+			f, err := fs.OpenFile(ctx, "/docs/note.txt", os.O_RDONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			data, err := io.ReadAll(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "second" {
+				t.Errorf("content = %q, want second", data)
+			}
+
+			files, _, _, err := s.ListFiles("docs/", "", "", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 1 {
+				t.Errorf("objects = %v, want only docs/note.txt", files)
+			}
+		})
+	}
+}
@@ -2,19 +2,81 @@ package router
 
 import (
 	"dooqiniu/internal/api"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SetupRoutes 设置 Gin 路由
 func SetupRoutes(r *gin.Engine) {
+	// 记录每个配置版本上处理中的请求，重新加载配置时等待旧版本上的请求完成
+	r.Use(api.TrackConfig)
+
 	v1 := r.Group("/api/v1")
 	{
-		v1.POST("/upload", api.UploadHandler)
-		v1.GET("/download", api.DownloadFileHandler)
-		v1.DELETE("/delete", api.DeleteFileHandler)
-		v1.GET("/list", api.ListFilesHandler)
-		v1.POST("/copy", api.CopyFileHandler)
-		v1.POST("/move", api.MoveFileHandler)
+		setupStorageRoutes(v1)
+
+		v1.GET("/config", api.ConfigStatusHandler)
+		v1.POST("/config/reload", api.ReloadConfigHandler)
+
+		// 本地元数据索引只覆盖默认账号
+		v1.GET("/index", api.IndexStatusHandler)
+		v1.POST("/index/reconcile", api.ReconcileIndexHandler)
+
+		// 通过路由前缀选择命名账号，与 X-Qiniu-Profile 请求头等价
+		setupStorageRoutes(v1.Group("/profiles/:profile"))
+	}
+
+	// WebDAV 与 JSON 接口共用同一个存储后端
+	for _, method := range api.WebDAVMethods {
+		r.Handle(method, api.WebDAVPrefix, api.WebDAVHandler)
+		r.Handle(method, api.WebDAVPrefix+"/*path", api.WebDAVHandler)
+	}
+}
+
+// setupStorageRoutes 设置按请求选择账号的存储接口
+func setupStorageRoutes(g *gin.RouterGroup) {
+	g.POST("/upload", api.UploadHandler)
+	g.GET("/download", api.DownloadFileHandler)
+	g.DELETE("/delete", api.DeleteFileHandler)
+	g.DELETE("/prefix", api.DeletePrefixHandler)
+	g.GET("/list", api.ListFilesHandler)
+	g.GET("/export", api.ExportListHandler)
+	g.GET("/stats", api.StatsHandler)
+	g.GET("/stats/history", api.StatsHistoryHandler)
+	g.POST("/copy", api.CopyFileHandler)
+	g.POST("/move", api.MoveFileHandler)
+	g.GET("/objects/*key", api.GetObjectHandler)
+	g.HEAD("/objects/*key", api.HeadObjectHandler)
+	g.GET("/stat", api.StatHandler)
+	g.POST("/meta", api.ChangeMetaHandler)
+	g.POST("/chtype", api.ChangeTypeHandler)
+	g.POST("/restore", api.RestoreHandler)
+	g.GET("/restore", api.RestoreStatusHandler)
+	g.POST("/lifecycle", api.LifecycleHandler)
+	g.POST("/batch", api.BatchHandler)
+
+	// 分片上传会话，账号和存储空间在创建时选择并记录在会话中，之后的请求需要选择同一个账号
+	g.POST("/uploads", api.InitiateUploadHandler)
+	g.GET("/uploads/:id", api.ListUploadPartsHandler)
+	g.PUT("/uploads/:id/parts/:n", api.UploadPartHandler)
+	g.POST("/uploads/:id/complete", api.CompleteUploadHandler)
+	g.DELETE("/uploads/:id", api.AbortUploadHandler)
+
+	// tus 1.0 断点续传协议，账号和存储空间在创建时选择并记录在上传任务中
+	tus := g.Group("/tus", api.TusHeaders)
+	{
+		tus.OPTIONS("", api.TusOptionsHandler)
+		tus.OPTIONS("/:id", api.TusOptionsHandler)
+		tus.POST("", api.TusCreateHandler)
+		tus.HEAD("/:id", api.TusHeadHandler)
+		tus.PATCH("/:id", api.TusPatchHandler)
+		tus.DELETE("/:id", api.TusDeleteHandler)
 	}
 }
+
+// SetupS3Routes 将所有请求交给 S3 网关处理，S3 的路径即 bucket 和对象名，不能挂在路由分组下
+func SetupS3Routes(r *gin.Engine, gateway http.Handler) {
+	r.Use(api.TrackConfig)
+	r.Any("/*path", gin.WrapH(gateway))
+}